@@ -1,7 +1,10 @@
-package main
+// Package board holds pieces, moves and the board, with move generation and attack detection.
+package board
 
 import (
 	"fmt"
+	"io"
+	"strings"
 )
 
 // Pieces
@@ -27,7 +30,15 @@ type Piece struct {
 }
 
 type Board struct {
-	squares [8][8]Piece
+	squares     [8][8]Piece
+	accumulator Accumulator // Kept in step with the squares, nil unless an evaluator has attached one
+}
+
+// Accumulator is incremental evaluation state, such as an NNUE hidden layer,
+// that a board keeps in step with its pieces
+type Accumulator interface {
+	Update(old, piece Piece, row, col int) // A square changes from old to piece
+	Clone() Accumulator                    // Copy for a copy of the board
 }
 
 // New board function
@@ -72,12 +83,25 @@ func (b *Board) GetPiece(row, col int) Piece {
 // Set Piece sets the piece at the given position
 func (b *Board) SetPiece(row, col int, piece Piece) {
 	if row >= 0 && row < 8 && col >= 0 && col < 8 {
+		if b.accumulator != nil {
+			b.accumulator.Update(b.squares[row][col], piece, row, col)
+		}
 		b.squares[row][col] = piece
 	}
 }
 
-// Display board to print board to terminal
-func (b *Board) Display() {
+// Accumulator returns the board's incremental evaluation state, or nil
+func (b *Board) Accumulator() Accumulator {
+	return b.accumulator
+}
+
+// SetAccumulator attaches incremental evaluation state, nil to detach it
+func (b *Board) SetAccumulator(acc Accumulator) {
+	b.accumulator = acc
+}
+
+// Display board to print board to w
+func (b *Board) Display(w io.Writer) {
 	pieceSymbols := map[int]map[int]string{
 		White: {
 			Empty: ".", Pawn: "P", Rook: "R", Knight: "N", Bishop: "B", Queen: "Q", King: "K",
@@ -86,18 +110,18 @@ func (b *Board) Display() {
 			Empty: ".", Pawn: "p", Rook: "r", Knight: "n", Bishop: "b", Queen: "q", King: "k",
 		},
 	}
-	fmt.Println(" a b c d e f g h")
+	fmt.Fprintln(w, " a b c d e f g h")
 	for row := 0; row < 8; row++ {
-		fmt.Printf("%d", 8-row)
+		fmt.Fprintf(w, "%d", 8-row)
 		for col := 0; col < 8; col++ {
 			piece := b.squares[row][col]
 			symbol := pieceSymbols[piece.Color][piece.Type]
-			fmt.Printf("%s ", symbol)
+			fmt.Fprintf(w, "%s ", symbol)
 		}
-		fmt.Printf("%d\n", 8-row)
+		fmt.Fprintf(w, "%d\n", 8-row)
 	}
 
-	fmt.Println(" a b c d e f g h")
+	fmt.Fprintln(w, " a b c d e f g h")
 
 }
 
@@ -175,7 +199,7 @@ func (b *Board) GeneratePawnMoves(row, col int, moves *[]Move) {
 
 			// if starting can double move
 			if row == startRow {
-				doubleRow := 2 * direction
+				doubleRow := row + 2*direction
 				if IsValidSquare(doubleRow, col) && b.GetPiece(doubleRow, col).Type == Empty {
 					doubleMove := Move{
 						FromRow: row, FromCol: col,
@@ -311,8 +335,14 @@ func (b *Board) GenerateBishopMoves(row, col int, moves *[]Move) {
 // Queen moves
 
 func (b *Board) GenerateQueenMoves(row, col int, moves *[]Move) {
+	start := len(*moves)
 	b.GenerateBishopMoves(row, col, moves) // Queen moves like both a rook and bishop
 	b.GenerateRookMoves(row, col, moves)
+
+	// The rook and bishop generators label their moves with their own piece type
+	for i := start; i < len(*moves); i++ {
+		(*moves)[i].PieceType = Queen
+	}
 }
 
 // Knight moves
@@ -364,7 +394,7 @@ func (b *Board) GenerateKingMoves(row, col int, moves *[]Move) {
 		newRow := row + delta[0]
 		newCol := col + delta[1]
 
-		if IsValidSquare(row, col) {
+		if IsValidSquare(newRow, newCol) {
 			target := b.GetPiece(newRow, newCol)
 			if target.Type == Empty {
 				move := Move{
@@ -474,10 +504,10 @@ func (b *Board) IsSquareAttacked(row, col, attackingColor int) bool {
 
 	for i, dir := range directions {
 		for distance := 1; distance < 8; distance++ {
-			checkRow := row + dir[0]
-			checkCol := col + dir[1]
+			checkRow := row + dir[0]*distance
+			checkCol := col + dir[1]*distance
 
-			if IsValidSquare(checkRow, checkCol) {
+			if !IsValidSquare(checkRow, checkCol) {
 				break
 			}
 
@@ -487,15 +517,14 @@ func (b *Board) IsSquareAttacked(row, col, attackingColor int) bool {
 					if i < 4 { // rook directions
 						if piece.Type == Rook || piece.Type == Queen {
 							return true
-						} else { // bishop directions
-							if piece.Type == Bishop || piece.Type == Queen {
-								return true
-							}
+						}
+					} else { // bishop directions
+						if piece.Type == Bishop || piece.Type == Queen {
+							return true
 						}
 					}
-					break // piece blocks further movement in direction
 				}
-
+				break // piece blocks further movement in direction
 			}
 		}
 	}
@@ -538,17 +567,24 @@ func (b *Board) IsInCheck(color int) bool {
 // Copy board - deep copy of game
 
 func (b *Board) Copy() *Board {
-	newBoard := &Board{}
-	for row := 0; row < 8; row++ {
-		for col := 0; col < 8; col++ {
-			newBoard.squares[row][col] = b.squares[row][col]
-		}
+	newBoard := b.DetachedCopy()
+	if b.accumulator != nil {
+		newBoard.accumulator = b.accumulator.Clone()
 	}
 	return newBoard
 }
 
+// DetachedCopy copies the pieces but not the accumulator, for a board that is
+// only looked at, such as one made to see whether a move leaves a king in
+// check. Moves made on it don't pay for keeping evaluation state up to date
+func (b *Board) DetachedCopy() *Board {
+	newBoard := &Board{}
+	newBoard.squares = b.squares
+	return newBoard
+}
+
 func (b *Board) IsLegalMove(move Move, color int) bool {
-	testBoard := b.Copy()
+	testBoard := b.DetachedCopy()
 	testBoard.MakeMove(move)
 
 	return !testBoard.IsInCheck(color)
@@ -607,3 +643,59 @@ func (b *Board) GetGameResult(currentPlayer int) string {
 
 	return "Game continues!"
 }
+
+// HasMatingMaterial reports whether color has enough pieces that it could ever
+// deliver checkmate. A lone king, or a king with a single minor piece, cannot
+func (b *Board) HasMatingMaterial(color int) bool {
+	minorPieces := 0
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			piece := b.GetPiece(row, col)
+			if piece.Type == Empty || piece.Color != color {
+				continue
+			}
+			switch piece.Type {
+			case Pawn, Rook, Queen:
+				return true
+			case Knight, Bishop:
+				minorPieces++
+			}
+		}
+	}
+	return minorPieces >= 2
+}
+
+// ColorName names a side
+func ColorName(color int) string {
+	if color == White {
+		return "White"
+	}
+	return "Black"
+}
+
+// signatureLetters are the piece letters used in material signatures
+var signatureLetters = map[int]string{King: "K", Queen: "Q", Rook: "R", Bishop: "B", Knight: "N", Pawn: "P"}
+
+// MaterialSignature names the material on the board the way table files are named, e.g. KRPvKR
+func (b *Board) MaterialSignature() string {
+	var counts [2][7]int
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			piece := b.GetPiece(row, col)
+			if piece.Type != Empty {
+				counts[piece.Color][piece.Type]++
+			}
+		}
+	}
+
+	var sb strings.Builder
+	for color := White; color <= Black; color++ {
+		if color == Black {
+			sb.WriteByte('v')
+		}
+		for _, pieceType := range []int{King, Queen, Rook, Bishop, Knight, Pawn} {
+			sb.WriteString(strings.Repeat(signatureLetters[pieceType], counts[color][pieceType]))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,225 @@
+package book
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+// BookBuilderOptions control which games and moves go into a built book
+type BookBuilderOptions struct {
+	MaxPly    int // Only record moves up to this ply of each game
+	MinGames  int // Drop moves played in fewer games than this
+	MinRating int // Only count moves by players rated at least this, 0 to count everyone
+}
+
+// bookMoveStats counts results for one move from one position, from the mover's side
+type bookMoveStats struct {
+	Wins   int
+	Draws  int
+	Losses int
+}
+
+func (s *bookMoveStats) games() int {
+	return s.Wins + s.Draws + s.Losses
+}
+
+// BookBuilder aggregates game results per position to build a Polyglot book
+type BookBuilder struct {
+	Options      BookBuilderOptions
+	GamesAdded   int
+	GamesSkipped int // Malformed or unfinished games
+
+	positions map[uint64]map[uint16]*bookMoveStats
+}
+
+// NewBookBuilder creates an empty book builder
+func NewBookBuilder(options BookBuilderOptions) *BookBuilder {
+	return &BookBuilder{
+		Options:   options,
+		positions: make(map[uint64]map[uint16]*bookMoveStats),
+	}
+}
+
+// AddGame replays a game's main line and records its moves
+func (bb *BookBuilder) AddGame(pg *game.PGNGame) error {
+	var whiteScore int
+	switch pg.Result {
+	case "1-0":
+		whiteScore = 1
+	case "0-1":
+		whiteScore = -1
+	case "1/2-1/2":
+		whiteScore = 0
+	default:
+		return fmt.Errorf("game has no result")
+	}
+
+	var counted [2]bool
+	for color, tag := range []string{"WhiteElo", "BlackElo"} {
+		rating, _ := strconv.Atoi(pg.Tag(tag))
+		counted[color] = bb.Options.MinRating == 0 || rating >= bb.Options.MinRating
+	}
+
+	game, err := pg.StartingPosition()
+	if err != nil {
+		return err
+	}
+
+	for ply, pm := range pg.Moves {
+		if bb.Options.MaxPly > 0 && ply >= bb.Options.MaxPly {
+			break
+		}
+
+		mover := game.CurrentPlayer
+		if counted[mover] {
+			key := PolyglotKey(game)
+			moves := bb.positions[key]
+			if moves == nil {
+				moves = make(map[uint16]*bookMoveStats)
+				bb.positions[key] = moves
+			}
+			code := encodePolyglotMove(pm.Move)
+			stats := moves[code]
+			if stats == nil {
+				stats = &bookMoveStats{}
+				moves[code] = stats
+			}
+
+			score := whiteScore
+			if mover == board.Black {
+				score = -score
+			}
+			switch score {
+			case 1:
+				stats.Wins++
+			case 0:
+				stats.Draws++
+			default:
+				stats.Losses++
+			}
+		}
+
+		if !game.MakeMove(pm.Move) {
+			return fmt.Errorf("illegal move %s", pm.SAN)
+		}
+	}
+
+	bb.GamesAdded++
+	return nil
+}
+
+// AddPGN adds every finished game from a PGN stream, skipping games that can't be used
+func (bb *BookBuilder) AddPGN(r io.Reader) error {
+	reader := game.NewPGNReader(r)
+	for {
+		pg, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var pgnErr *game.PGNError
+			if !errors.As(err, &pgnErr) {
+				return err
+			}
+			bb.GamesSkipped++
+			continue
+		}
+		if err := bb.AddGame(pg); err != nil {
+			bb.GamesSkipped++
+		}
+	}
+}
+
+// Write writes the book in Polyglot format and returns the number of entries.
+// Each move is weighted by its score for the mover, two points for a win and one for a draw
+func (bb *BookBuilder) Write(w io.Writer) (int, error) {
+	type bookEntry struct {
+		key    uint64
+		move   uint16
+		weight int
+	}
+
+	var entries []bookEntry
+	maxWeight := 0
+	for key, moves := range bb.positions {
+		for code, stats := range moves {
+			if stats.games() < bb.Options.MinGames {
+				continue
+			}
+			weight := 2*stats.Wins + stats.Draws
+			entries = append(entries, bookEntry{key: key, move: code, weight: weight})
+			if weight > maxWeight {
+				maxWeight = weight
+			}
+		}
+	}
+
+	// Polyglot weights are 16 bit, so scale down big collections
+	if maxWeight > 0xFFFF {
+		for i := range entries {
+			entries[i].weight = entries[i].weight * 0xFFFF / maxWeight
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].key != entries[j].key {
+			return entries[i].key < entries[j].key
+		}
+		if entries[i].weight != entries[j].weight {
+			return entries[i].weight > entries[j].weight
+		}
+		return entries[i].move < entries[j].move
+	})
+
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, polyglotEntrySize)
+	for _, entry := range entries {
+		binary.BigEndian.PutUint64(buf[0:8], entry.key)
+		binary.BigEndian.PutUint16(buf[8:10], entry.move)
+		binary.BigEndian.PutUint16(buf[10:12], uint16(entry.weight))
+		binary.BigEndian.PutUint32(buf[12:16], 0)
+		if _, err := bw.Write(buf); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), bw.Flush()
+}
+
+// BuildPolyglotBook builds a book from PGN files and writes it to outPath
+func BuildPolyglotBook(outPath string, pgnPaths []string, options BookBuilderOptions) (*BookBuilder, int, error) {
+	if !polyglotKeysValid() {
+		return nil, 0, errors.New("polyglot key table does not match the reference start position key")
+	}
+
+	bb := NewBookBuilder(options)
+	for _, path := range pgnPaths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		err = bb.AddPGN(f)
+		f.Close()
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	entries, err := bb.Write(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return bb, entries, err
+}
@@ -0,0 +1,117 @@
+package book
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"chess-engine/board"
+)
+
+const builderPGN = `[White "A"]
+[Black "B"]
+[WhiteElo "2400"]
+[BlackElo "2300"]
+[Result "1-0"]
+
+1. e4 e5 2. Nf3 1-0
+
+[WhiteElo "2500"]
+[BlackElo "2450"]
+[Result "1/2-1/2"]
+
+1. e4 c5 1/2-1/2
+
+[WhiteElo "2300"]
+[BlackElo "2300"]
+[Result "0-1"]
+
+1. e4 c5 0-1
+
+[WhiteElo "2450"]
+[BlackElo "2400"]
+[Result "0-1"]
+
+1. d4 d5 0-1
+
+[WhiteElo "1500"]
+[BlackElo "1600"]
+[Result "1-0"]
+
+1. d4 Nf6 1-0
+
+[WhiteElo "2600"]
+[BlackElo "1500"]
+[Result "1-0"]
+
+1. d4 e5 1-0
+
+[WhiteElo "2600"]
+[BlackElo "2600"]
+[Result "*"]
+
+1. c4 *
+`
+
+func TestBuildPolyglotBook(t *testing.T) {
+	dir := t.TempDir()
+	pgnPath := filepath.Join(dir, "games.pgn")
+	if err := os.WriteFile(pgnPath, []byte(builderPGN), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bookPath := filepath.Join(dir, "book.bin")
+
+	bb, entries, err := BuildPolyglotBook(bookPath, []string{pgnPath}, BookBuilderOptions{MinGames: 2, MinRating: 2000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bb.GamesAdded != 6 || bb.GamesSkipped != 1 {
+		t.Errorf("added %d and skipped %d games, want 6 and 1", bb.GamesAdded, bb.GamesSkipped)
+	}
+	if entries != 3 {
+		t.Errorf("wrote %d entries, want 3", entries)
+	}
+
+	book, err := OpenPolyglotBook(bookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Size() != entries {
+		t.Errorf("book has %d entries, built %d", book.Size(), entries)
+	}
+
+	// Weights are two points per win and one per draw for the mover. The 1500
+	// rated players' moves don't count, and moves from a single game are dropped
+	tests := []struct {
+		moves string
+		want  []BookMove
+	}{
+		{"", []BookMove{{Move: moveOf(t, "", "e2e4"), Weight: 3}, {Move: moveOf(t, "", "d2d4"), Weight: 2}}},
+		{"e2e4", []BookMove{{Move: moveOf(t, "e2e4", "c7c5"), Weight: 3}}},
+		{"d2d4", nil},
+		{"e2e4 e7e5", nil},
+	}
+	for _, test := range tests {
+		got := book.Moves(playMoves(t, test.moves))
+		if len(got) != len(test.want) {
+			t.Errorf("after %q: got %d book moves, want %d", test.moves, len(got), len(test.want))
+			continue
+		}
+		for i := range got {
+			if got[i].Move.String() != test.want[i].Move.String() || got[i].Weight != test.want[i].Weight {
+				t.Errorf("after %q: move %d is %s weight %d, want %s weight %d", test.moves, i,
+					got[i].Move.String(), got[i].Weight, test.want[i].Move.String(), test.want[i].Weight)
+			}
+		}
+	}
+}
+
+// moveOf parses move in the position after moves
+func moveOf(t *testing.T, moves, move string) board.Move {
+	t.Helper()
+	parsed, ok := playMoves(t, moves).ParseMove(move)
+	if !ok {
+		t.Fatalf("can't parse %s", move)
+	}
+	return parsed
+}
@@ -0,0 +1,263 @@
+// Package book reads and builds Polyglot opening books.
+package book
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+// Book move selection
+const (
+	BookWeighted = iota // Pick randomly in proportion to the move weights
+	BookBest            // Always pick the highest weighted move
+)
+
+// polyglotEntrySize is the size of one book entry on disk
+const polyglotEntrySize = 16
+
+// Offsets of the non piece keys in polyglotRandom64
+const (
+	polyglotCastleOffset    = 768
+	polyglotEnPassantOffset = 772
+	polyglotTurnOffset      = 780
+)
+
+// polyglotPieceKinds maps our piece types to Polyglot's piece order, where
+// black pieces are even and white pieces odd
+var polyglotPieceKinds = map[int]int{
+	board.Pawn: 0, board.Knight: 2, board.Bishop: 4, board.Rook: 6, board.Queen: 8, board.King: 10,
+}
+
+// Polyglot promotion piece codes
+var polyglotPromotions = map[int]int{
+	board.Knight: 1, board.Bishop: 2, board.Rook: 3, board.Queen: 4,
+}
+
+// PolyglotKey computes the Polyglot hash of a position
+func PolyglotKey(g *game.GameState) uint64 {
+	var key uint64
+
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			piece := g.Board.GetPiece(row, col)
+			if piece.Type == board.Empty {
+				continue
+			}
+			kind := polyglotPieceKinds[piece.Type]
+			if piece.Color == board.White {
+				kind++
+			}
+			rank := 7 - row // Polyglot counts ranks from white's side
+			key ^= polyglotRandom64[64*kind+8*rank+col]
+		}
+	}
+
+	if g.WhiteCanCastleK {
+		key ^= polyglotRandom64[polyglotCastleOffset]
+	}
+	if g.WhiteCanCastleQ {
+		key ^= polyglotRandom64[polyglotCastleOffset+1]
+	}
+	if g.BlackCanCastleK {
+		key ^= polyglotRandom64[polyglotCastleOffset+2]
+	}
+	if g.BlackCanCastleQ {
+		key ^= polyglotRandom64[polyglotCastleOffset+3]
+	}
+
+	// The en passant file only counts if a pawn is actually there to capture
+	if g.EnPassantSquare[0] != -1 {
+		epCol := g.EnPassantSquare[1]
+		pawnRow := g.EnPassantSquare[0] + 1
+		if g.CurrentPlayer == board.Black {
+			pawnRow = g.EnPassantSquare[0] - 1
+		}
+		for _, deltaCol := range []int{-1, 1} {
+			if !board.IsValidSquare(pawnRow, epCol+deltaCol) {
+				continue
+			}
+			piece := g.Board.GetPiece(pawnRow, epCol+deltaCol)
+			if piece.Type == board.Pawn && piece.Color == g.CurrentPlayer {
+				key ^= polyglotRandom64[polyglotEnPassantOffset+epCol]
+				break
+			}
+		}
+	}
+
+	if g.CurrentPlayer == board.White {
+		key ^= polyglotRandom64[polyglotTurnOffset]
+	}
+
+	return key
+}
+
+// polyglotEntry is one book entry
+type polyglotEntry struct {
+	Key    uint64
+	Move   uint16
+	Weight uint16
+	Learn  uint32
+}
+
+// BookMove is a legal move found in the book for a position
+type BookMove struct {
+	Move   board.Move
+	Weight int
+}
+
+// PolyglotBook is an opening book in Polyglot .bin format
+type PolyglotBook struct {
+	Path    string
+	entries []polyglotEntry // Sorted by key, as in the file
+}
+
+// OpenPolyglotBook loads a Polyglot book into memory
+func OpenPolyglotBook(path string) (*PolyglotBook, error) {
+	if !polyglotKeysValid() {
+		return nil, errors.New("polyglot key table does not match the reference start position key")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	book := &PolyglotBook{Path: path}
+	if err := book.read(f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return book, nil
+}
+
+func (b *PolyglotBook) read(r io.Reader) error {
+	buf := make([]byte, polyglotEntrySize)
+	for {
+		_, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			return errors.New("truncated book entry")
+		}
+		if err != nil {
+			return err
+		}
+		b.entries = append(b.entries, polyglotEntry{
+			Key:    binary.BigEndian.Uint64(buf[0:8]),
+			Move:   binary.BigEndian.Uint16(buf[8:10]),
+			Weight: binary.BigEndian.Uint16(buf[10:12]),
+			Learn:  binary.BigEndian.Uint32(buf[12:16]),
+		})
+	}
+
+	// Books should already be sorted, but don't rely on it
+	if !sort.SliceIsSorted(b.entries, func(i, j int) bool { return b.entries[i].Key < b.entries[j].Key }) {
+		sort.SliceStable(b.entries, func(i, j int) bool { return b.entries[i].Key < b.entries[j].Key })
+	}
+	return nil
+}
+
+// Size returns the number of entries in the book
+func (b *PolyglotBook) Size() int {
+	return len(b.entries)
+}
+
+// Moves returns the legal book moves for a position, highest weight first
+func (b *PolyglotBook) Moves(g *game.GameState) []BookMove {
+	key := PolyglotKey(g)
+	i := sort.Search(len(b.entries), func(i int) bool { return b.entries[i].Key >= key })
+
+	legalMoves := g.GenerateAllLegalMoves()
+	var moves []BookMove
+	for ; i < len(b.entries) && b.entries[i].Key == key; i++ {
+		entry := b.entries[i]
+		if move, ok := decodePolyglotMove(entry.Move, legalMoves); ok {
+			moves = append(moves, BookMove{Move: move, Weight: int(entry.Weight)})
+		}
+	}
+
+	sort.SliceStable(moves, func(i, j int) bool { return moves[i].Weight > moves[j].Weight })
+	return moves
+}
+
+// PickMove chooses a book move for the position using the given selection mode
+func (b *PolyglotBook) PickMove(g *game.GameState, selection int) (board.Move, bool) {
+	moves := b.Moves(g)
+	if len(moves) == 0 {
+		return board.Move{}, false
+	}
+
+	if selection == BookBest {
+		return moves[0].Move, true
+	}
+
+	total := 0
+	for _, bm := range moves {
+		total += bm.Weight
+	}
+	if total == 0 {
+		return moves[rand.Intn(len(moves))].Move, true
+	}
+
+	pick := rand.Intn(total)
+	for _, bm := range moves {
+		pick -= bm.Weight
+		if pick < 0 {
+			return bm.Move, true
+		}
+	}
+	return moves[0].Move, true
+}
+
+// decodePolyglotMove finds the legal move matching a Polyglot move encoding.
+// Castling is stored as the king capturing its own rook
+func decodePolyglotMove(code uint16, legalMoves []board.Move) (board.Move, bool) {
+	toCol := int(code & 7)
+	toRow := 7 - int((code>>3)&7)
+	fromCol := int((code >> 6) & 7)
+	fromRow := 7 - int((code>>9)&7)
+	promotion := int((code >> 12) & 7)
+
+	for _, move := range legalMoves {
+		if move.FromRow != fromRow || move.FromCol != fromCol {
+			continue
+		}
+		if move.IsCastle {
+			rookCol := 7
+			if move.ToCol == 2 {
+				rookCol = 0
+			}
+			if toRow == move.ToRow && toCol == rookCol {
+				return move, true
+			}
+			continue
+		}
+		if move.ToRow == toRow && move.ToCol == toCol && polyglotPromotions[move.PromotionPiece] == promotion {
+			return move, true
+		}
+	}
+	return board.Move{}, false
+}
+
+// encodePolyglotMove converts a move into Polyglot's encoding
+func encodePolyglotMove(move board.Move) uint16 {
+	toCol := move.ToCol
+	if move.IsCastle {
+		toCol = 7
+		if move.ToCol == 2 {
+			toCol = 0
+		}
+	}
+	code := uint16(toCol) | uint16(7-move.ToRow)<<3 | uint16(move.FromCol)<<6 | uint16(7-move.FromRow)<<9
+	code |= uint16(polyglotPromotions[move.PromotionPiece]) << 12
+	return code
+}
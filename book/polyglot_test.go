@@ -0,0 +1,50 @@
+package book
+
+import (
+	"strings"
+	"testing"
+
+	"chess-engine/game"
+)
+
+// playMoves plays moves in coordinate notation from the starting position
+func playMoves(t *testing.T, moves string) *game.GameState {
+	t.Helper()
+	g := game.NewGame()
+	for _, text := range strings.Fields(moves) {
+		move, ok := g.ParseMove(text)
+		if !ok || !g.MakeMove(move) {
+			t.Fatalf("illegal move %s in %q", text, moves)
+		}
+	}
+	return g
+}
+
+// The keys are the examples from the Polyglot book format specification
+func TestPolyglotKey(t *testing.T) {
+	tests := []struct {
+		moves string
+		key   uint64
+	}{
+		{"", 0x463B96181691FC9C},
+		{"e2e4", 0x823C9B50FD114196}, // No black pawn can take en passant, so no file key
+		{"e2e4 d7d5", 0x0756B94461C50FB0},
+		{"e2e4 d7d5 e4e5", 0x662FAFB965DB29D4},
+		{"e2e4 d7d5 e4e5 f7f5", 0x22A48B5A8E47FF78},                // e5 can take on f6: en passant file key
+		{"e2e4 d7d5 e4e5 f7f5 e1e2", 0x652A607CA3F242C1},           // White loses both castling keys
+		{"e2e4 d7d5 e4e5 f7f5 e1e2 e8f7", 0x00FDD303C946BDD9},      // And black too
+		{"a2a4 b7b5 h2h4 b5b4 c2c4", 0x3C8123EA7B067637},           // b4 can take on c3
+		{"a2a4 b7b5 h2h4 b5b4 c2c4 b4c3 a1a3", 0x5C3F9B829B279560}, // White loses queenside castling
+	}
+	for _, test := range tests {
+		if key := PolyglotKey(playMoves(t, test.moves)); key != test.key {
+			t.Errorf("after %q: key %#016x, want %#016x", test.moves, key, test.key)
+		}
+	}
+}
+
+func TestPolyglotKeysValid(t *testing.T) {
+	if !polyglotKeysValid() {
+		t.Fatal("key table doesn't give the start position key")
+	}
+}
@@ -0,0 +1,111 @@
+package bot
+
+import "context"
+
+// API is what the bot needs from the server it plays on. Client implements it
+// over the Lichess Bot API; anything else speaking the same events, such as a
+// stand-in server for trying the bot out, can be plugged in instead
+type API interface {
+	// Account returns the bot's own account
+	Account(ctx context.Context) (Account, error)
+
+	// StreamEvents passes each incoming event to handle until the stream
+	// ends, the context is cancelled or handle returns an error
+	StreamEvents(ctx context.Context, handle func(Event) error) error
+
+	// StreamGame passes each event of a game to handle until the game's
+	// stream ends, the context is cancelled or handle returns an error
+	StreamGame(ctx context.Context, gameID string, handle func(GameEvent) error) error
+
+	AcceptChallenge(ctx context.Context, challengeID string) error
+	DeclineChallenge(ctx context.Context, challengeID, reason string) error
+
+	// MakeMove plays a move, given in UCI coordinate notation
+	MakeMove(ctx context.Context, gameID, move string) error
+}
+
+// Account is the bot's account
+type Account struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// Event is a line of the event stream: a challenge coming in or going away,
+// or a game starting or finishing
+type Event struct {
+	Type      string     `json:"type"` // "challenge", "challengeCanceled", "challengeDeclined", "gameStart" or "gameFinish"
+	Challenge *Challenge `json:"challenge,omitempty"`
+	Game      *GameInfo  `json:"game,omitempty"`
+}
+
+// Challenge is an invitation to a game
+type Challenge struct {
+	ID          string      `json:"id"`
+	Challenger  Player      `json:"challenger"`
+	Variant     Variant     `json:"variant"`
+	Rated       bool        `json:"rated"`
+	Speed       string      `json:"speed"` // "ultraBullet", "bullet", "blitz", "rapid", "classical" or "correspondence"
+	TimeControl TimeControl `json:"timeControl"`
+	Color       string      `json:"color"` // The challenger's choice: "white", "black" or "random"
+}
+
+// Player is a player of a game or the sender of a challenge
+type Player struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Title  string `json:"title,omitempty"` // "BOT" for bots
+	Rating int    `json:"rating,omitempty"`
+}
+
+// Variant names the rules a game is played by
+type Variant struct {
+	Key string `json:"key"` // "standard", "fromPosition", ...
+}
+
+// TimeControl is a challenge's clock, in seconds
+type TimeControl struct {
+	Type        string `json:"type"` // "clock", "correspondence" or "unlimited"
+	Limit       int    `json:"limit,omitempty"`
+	Increment   int    `json:"increment,omitempty"`
+	DaysPerTurn int    `json:"daysPerTurn,omitempty"`
+}
+
+// GameInfo identifies a game that started or finished
+type GameInfo struct {
+	GameID string `json:"gameId"`
+	ID     string `json:"id"` // Same as GameID; older servers only send this
+	Color  string `json:"color,omitempty"`
+}
+
+// GameEvent is a line of a game's stream. "gameFull" comes first and holds
+// the players and the state so far in State; each "gameState" after it
+// holds the new state in its GameStatus fields. Other types, such as
+// "chatLine" and "opponentGone", carry nothing the bot uses
+type GameEvent struct {
+	Type       string      `json:"type"`
+	ID         string      `json:"id,omitempty"`
+	White      Player      `json:"white"`
+	Black      Player      `json:"black"`
+	InitialFEN string      `json:"initialFen,omitempty"` // "startpos" or a FEN
+	Clock      *GameClock  `json:"clock,omitempty"`      // nil for correspondence and unlimited games
+	State      *GameStatus `json:"state,omitempty"`
+	GameStatus
+}
+
+// GameClock is a game's time control, in milliseconds
+type GameClock struct {
+	Initial   int `json:"initial"`
+	Increment int `json:"increment"`
+}
+
+// GameStatus is the state of a game: every move played from the initial
+// position and the clocks, in milliseconds
+type GameStatus struct {
+	Moves  string `json:"moves"` // UCI moves separated by spaces
+	WTime  int    `json:"wtime"`
+	BTime  int    `json:"btime"`
+	WInc   int    `json:"winc"`
+	BInc   int    `json:"binc"`
+	Status string `json:"status"` // "started" while the game goes on
+	Winner string `json:"winner,omitempty"`
+}
@@ -0,0 +1,280 @@
+// Package bot plays the engine online as a bot: it follows the account's
+// event stream, accepts challenges by its rules and plays each game it starts.
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"chess-engine/board"
+	"chess-engine/game"
+	"chess-engine/search"
+	"chess-engine/uci"
+)
+
+// Bot accepts challenges and plays games through an API
+type Bot struct {
+	API          API
+	Rules        Rules
+	NewEngine    func() *search.Engine // Makes each game's engine, search.NewEngine when nil
+	MoveOverhead time.Duration         // Kept off the clock for network lag
+	Out          io.Writer             // Receives progress messages, nil for none
+
+	id    string // The bot's account id, lower case
+	mu    sync.Mutex
+	games map[string]bool // Games being played
+	wg    sync.WaitGroup
+}
+
+// New creates a bot with the default rules
+func New(api API) *Bot {
+	return &Bot{
+		API:          api,
+		Rules:        DefaultRules(),
+		MoveOverhead: 300 * time.Millisecond,
+	}
+}
+
+// Run handles events until the event stream ends or the context is
+// cancelled, then waits for the games in progress to end
+func (b *Bot) Run(ctx context.Context) error {
+	account, err := b.API.Account(ctx)
+	if err != nil {
+		return err
+	}
+	b.id = strings.ToLower(account.ID)
+	b.games = make(map[string]bool)
+	b.logf("Logged in as %s\n", account.Username)
+
+	err = b.API.StreamEvents(ctx, func(event Event) error {
+		b.handleEvent(ctx, event)
+		return nil
+	})
+	b.wg.Wait()
+	return err
+}
+
+// handleEvent answers challenges and starts playing games
+func (b *Bot) handleEvent(ctx context.Context, event Event) {
+	switch event.Type {
+	case "challenge":
+		if event.Challenge == nil || strings.EqualFold(event.Challenge.Challenger.ID, b.id) {
+			return // Our own challenge to someone else
+		}
+		b.answerChallenge(ctx, *event.Challenge)
+	case "gameStart":
+		if event.Game == nil {
+			return
+		}
+		id := event.Game.GameID
+		if id == "" {
+			id = event.Game.ID
+		}
+		b.startGame(ctx, id)
+	case "gameFinish":
+		if event.Game != nil {
+			b.logf("Game %s finished\n", event.Game.GameID)
+		}
+	}
+}
+
+// answerChallenge accepts or declines a challenge by the rules
+func (b *Bot) answerChallenge(ctx context.Context, c Challenge) {
+	b.mu.Lock()
+	playing := len(b.games)
+	b.mu.Unlock()
+
+	description := fmt.Sprintf("%s %s %s from %s", c.Speed, c.Variant.Key, ratedName(c.Rated), c.Challenger.Name)
+	if reason := b.Rules.Check(c, playing); reason != "" {
+		b.logf("Declining %s (%s)\n", description, reason)
+		if err := b.API.DeclineChallenge(ctx, c.ID, reason); err != nil {
+			b.logf("Declining challenge %s failed: %v\n", c.ID, err)
+		}
+		return
+	}
+	b.logf("Accepting %s\n", description)
+	if err := b.API.AcceptChallenge(ctx, c.ID); err != nil {
+		b.logf("Accepting challenge %s failed: %v\n", c.ID, err)
+	}
+}
+
+// ratedName describes whether a game is rated
+func ratedName(rated bool) string {
+	if rated {
+		return "rated"
+	}
+	return "casual"
+}
+
+// startGame plays a game on a goroutine of its own, unless it's already
+// being played
+func (b *Bot) startGame(ctx context.Context, id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.games[id] {
+		return
+	}
+	b.games[id] = true
+
+	newEngine := b.NewEngine
+	if newEngine == nil {
+		newEngine = search.NewEngine
+	}
+	player := &gamePlayer{bot: b, ctx: ctx, id: id, engine: newEngine(), color: noColor}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.logf("Game %s started\n", id)
+		if err := b.API.StreamGame(ctx, id, player.handle); err != nil {
+			b.logf("Game %s: %v\n", id, err)
+		}
+		b.mu.Lock()
+		delete(b.games, id)
+		b.mu.Unlock()
+	}()
+}
+
+// logf writes a progress message
+func (b *Bot) logf(format string, args ...any) {
+	if b.Out != nil {
+		fmt.Fprintf(b.Out, format, args...)
+	}
+}
+
+// noColor is the color of a game the bot isn't playing in
+const noColor = -1
+
+// gamePlayer keeps one game in sync with its stream and moves when it's the
+// bot's turn
+type gamePlayer struct {
+	bot    *Bot
+	ctx    context.Context
+	id     string
+	engine *search.Engine
+	color  int             // The bot's color, noColor until the game is known
+	clock  *GameClock      // nil when the game has no clock
+	start  *game.GameState // The position the game started from
+	game   *game.GameState // The position after moves
+	moves  []string        // Moves played so far
+}
+
+// handle takes in an event of the game's stream
+func (p *gamePlayer) handle(event GameEvent) error {
+	switch event.Type {
+	case "gameFull":
+		if err := p.begin(event); err != nil {
+			return err
+		}
+		if event.State != nil {
+			return p.update(*event.State)
+		}
+	case "gameState":
+		if p.game == nil {
+			return fmt.Errorf("game state before the full game")
+		}
+		return p.update(event.GameStatus)
+	}
+	return nil
+}
+
+// begin learns the players, clock and starting position of the game
+func (p *gamePlayer) begin(event GameEvent) error {
+	switch p.bot.id {
+	case strings.ToLower(event.White.ID):
+		p.color = board.White
+	case strings.ToLower(event.Black.ID):
+		p.color = board.Black
+	default:
+		return fmt.Errorf("not playing in this game")
+	}
+	p.clock = event.Clock
+
+	p.start = game.NewGame()
+	if event.InitialFEN != "" && event.InitialFEN != "startpos" {
+		start, err := game.NewGameFromFEN(event.InitialFEN)
+		if err != nil {
+			return err
+		}
+		p.start = start
+	}
+	p.game, p.moves = p.start.Copy(), nil
+
+	opponent := event.Black
+	if p.color == board.Black {
+		opponent = event.White
+	}
+	p.bot.logf("Game %s: playing %s against %s\n", p.id, strings.ToLower(board.ColorName(p.color)), opponent.Name)
+	return nil
+}
+
+// update brings the game up to a new state and moves if it's the bot's turn
+func (p *gamePlayer) update(state GameStatus) error {
+	if err := p.sync(strings.Fields(state.Moves)); err != nil {
+		return err
+	}
+	if state.Status != "" && state.Status != "started" && state.Status != "created" {
+		p.bot.logf("Game %s: %s%s\n", p.id, state.Status, winnerText(state.Winner))
+		return nil
+	}
+	if p.game.CurrentPlayer != p.color {
+		return nil
+	}
+	if over, _ := p.game.IsGameOver(); over {
+		return nil
+	}
+	return p.play(state)
+}
+
+// winnerText names the winner, if there is one
+func winnerText(winner string) string {
+	if winner == "" {
+		return ""
+	}
+	return ", " + winner + " wins"
+}
+
+// sync plays the moves the position doesn't have yet. When the moves no
+// longer follow on from it, after a takeback, the game is replayed from
+// the start
+func (p *gamePlayer) sync(moves []string) error {
+	if len(moves) < len(p.moves) || !slices.Equal(moves[:len(p.moves)], p.moves) {
+		p.game, p.moves = p.start.Copy(), nil
+	}
+	for _, text := range moves[len(p.moves):] {
+		move, ok := p.game.ParseMove(text)
+		if !ok || !p.game.MakeMove(move) {
+			return fmt.Errorf("illegal move %s after %s", text, strings.Join(p.moves, " "))
+		}
+		p.moves = append(p.moves, text)
+	}
+	return nil
+}
+
+// play searches the position, giving the search a share of the clock, and
+// sends the move found
+func (p *gamePlayer) play(state GameStatus) error {
+	if p.clock != nil {
+		remaining, increment := state.WTime, state.WInc
+		if p.color == board.Black {
+			remaining, increment = state.BTime, state.BInc
+		}
+		left := max(time.Duration(remaining)*time.Millisecond-p.bot.MoveOverhead, 0)
+		p.engine.TimeLimit = p.engine.AllocateTime(left, time.Duration(increment)*time.Millisecond, 0)
+	}
+
+	result := p.engine.SearchBestMoveOrdered(p.game)
+	move := uci.MoveString(result.BestMove)
+	p.bot.logf("Game %s: playing %s (%+d) after %v\n", p.id, p.game.MoveToSAN(result.BestMove),
+		uci.RelativeScore(result.Score, p.color), result.Duration.Round(time.Millisecond))
+	if err := p.bot.API.MakeMove(p.ctx, p.id, move); err != nil {
+		// The game may have ended meanwhile; the stream says so if it has
+		p.bot.logf("Game %s: sending %s failed: %v\n", p.id, move, err)
+	}
+	return nil
+}
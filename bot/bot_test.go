@@ -0,0 +1,329 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"chess-engine/board"
+	"chess-engine/game"
+	"chess-engine/search"
+	"chess-engine/uci"
+)
+
+// fakeLichess is a stand-in for the Lichess Bot API. The test feeds the
+// lines of the event and game streams and reads back what the bot posts
+type fakeLichess struct {
+	events chan string // Lines of the event stream, which ends when it is closed
+	games  chan string // Lines of the game stream, likewise
+	posted chan string // Path of each POST, with the form's reason when there is one
+}
+
+func newFakeLichess(t *testing.T) (*fakeLichess, *httptest.Server) {
+	f := &fakeLichess{events: make(chan string), games: make(chan string), posted: make(chan string, 10)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/account", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": "TestBot", "username": "TestBot"}`)
+	})
+	mux.HandleFunc("GET /api/stream/event", func(w http.ResponseWriter, r *http.Request) {
+		streamLines(w, r, f.events)
+	})
+	mux.HandleFunc("GET /api/bot/game/stream/{id}", func(w http.ResponseWriter, r *http.Request) {
+		streamLines(w, r, f.games)
+	})
+	mux.HandleFunc("POST /", func(w http.ResponseWriter, r *http.Request) {
+		posted := r.URL.Path
+		if reason := r.FormValue("reason"); reason != "" {
+			posted += " " + reason
+		}
+		f.posted <- posted
+		fmt.Fprint(w, `{"ok": true}`)
+	})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, `{"error": "No such token"}`, http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return f, ts
+}
+
+// streamLines writes each line as it comes, as NDJSON
+func streamLines(w http.ResponseWriter, r *http.Request, lines chan string) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.(http.Flusher).Flush()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			fmt.Fprintln(w, line)
+			w.(http.Flusher).Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// jsonLine encodes a stream line
+func jsonLine(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// nextPost waits for the bot's next POST
+func (f *fakeLichess) nextPost(t *testing.T) string {
+	t.Helper()
+	select {
+	case posted := <-f.posted:
+		return posted
+	case <-time.After(10 * time.Second):
+		t.Fatal("nothing posted")
+		return ""
+	}
+}
+
+// expectNoPost checks that the bot posts nothing for a while
+func (f *fakeLichess) expectNoPost(t *testing.T) {
+	t.Helper()
+	select {
+	case posted := <-f.posted:
+		t.Errorf("unexpected post %s", posted)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// expectMove waits for a move in game g1 and checks it is legal after moves
+func (f *fakeLichess) expectMove(t *testing.T, moves string) string {
+	t.Helper()
+	posted := f.nextPost(t)
+	move, found := strings.CutPrefix(posted, "/api/bot/game/g1/move/")
+	if !found {
+		t.Fatalf("posted %s, want a move", posted)
+	}
+	g := game.NewGame()
+	for _, text := range strings.Fields(moves + " " + move) {
+		m, ok := g.ParseMove(text)
+		if !ok || !g.MakeMove(m) {
+			t.Fatalf("bot played %s after %q, which isn't legal", move, moves)
+		}
+	}
+	return move
+}
+
+func challengeEvent(id, challenger, variant, speed string, limit int) Event {
+	return Event{Type: "challenge", Challenge: &Challenge{
+		ID:          id,
+		Challenger:  Player{ID: strings.ToLower(challenger), Name: challenger},
+		Variant:     Variant{Key: variant},
+		Rated:       true,
+		Speed:       speed,
+		TimeControl: TimeControl{Type: "clock", Limit: limit, Increment: 2},
+		Color:       "random",
+	}}
+}
+
+func TestBotRun(t *testing.T) {
+	f, ts := newFakeLichess(t)
+	b := New(NewClient(ts.URL, "secret"))
+	var engine atomic.Pointer[search.Engine]
+	b.NewEngine = func() *search.Engine {
+		e := search.NewEngine()
+		e.MaxDepth = 2
+		engine.Store(e)
+		return e
+	}
+	done := make(chan error)
+	go func() { done <- b.Run(context.Background()) }()
+
+	// Challenges, with keep-alive lines among them
+	f.events <- ""
+	f.events <- jsonLine(t, challengeEvent("c1", "Alice", "standard", "blitz", 180))
+	if posted := f.nextPost(t); posted != "/api/challenge/c1/accept" {
+		t.Errorf("posted %s, want c1 accepted", posted)
+	}
+	f.events <- jsonLine(t, challengeEvent("c2", "Bob", "atomic", "blitz", 180))
+	if posted := f.nextPost(t); posted != "/api/challenge/c2/decline variant" {
+		t.Errorf("posted %s, want c2 declined for its variant", posted)
+	}
+	f.events <- ""
+	f.events <- jsonLine(t, challengeEvent("mine", "TestBot", "standard", "blitz", 180)) // Ignored
+	f.events <- jsonLine(t, challengeEvent("c3", "Carol", "standard", "bullet", 15))
+	if posted := f.nextPost(t); posted != "/api/challenge/c3/decline tooFast" {
+		t.Errorf("posted %s, want c3 declined as too fast", posted)
+	}
+
+	// A game starts, and challenges wait for it to finish
+	f.events <- jsonLine(t, Event{Type: "gameStart", Game: &GameInfo{GameID: "g1", Color: "white"}})
+	f.events <- jsonLine(t, challengeEvent("c4", "Dave", "standard", "rapid", 600))
+	if posted := f.nextPost(t); posted != "/api/challenge/c4/decline later" {
+		t.Errorf("posted %s, want c4 declined until later", posted)
+	}
+
+	// The bot plays white, moving on its turns only
+	f.games <- jsonLine(t, GameEvent{
+		Type:       "gameFull",
+		ID:         "g1",
+		White:      Player{ID: "testbot", Name: "TestBot", Title: "BOT"},
+		Black:      Player{ID: "alice", Name: "Alice"},
+		InitialFEN: "startpos",
+		Clock:      &GameClock{Initial: 180000, Increment: 2000},
+		State:      &GameStatus{WTime: 180000, BTime: 180000, WInc: 2000, BInc: 2000, Status: "started"},
+	})
+	first := f.expectMove(t, "")
+	if got, want := engine.Load().TimeLimit, engine.Load().AllocateTime(180*time.Second-b.MoveOverhead, 2*time.Second, 0); got != want {
+		t.Errorf("time limit %v from the clock, want %v", got, want)
+	}
+	f.games <- ""
+	f.games <- jsonLine(t, GameEvent{Type: "gameState", GameStatus: GameStatus{Moves: first, WTime: 179000, BTime: 180000, Status: "started"}})
+	f.expectNoPost(t)
+
+	reply := replyTo(t, first)
+	moves := first + " " + reply
+	f.games <- jsonLine(t, GameEvent{Type: "gameState", GameStatus: GameStatus{Moves: moves, WTime: 179000, BTime: 175000, Status: "started"}})
+	f.expectMove(t, moves)
+
+	// After takebacks the moves no longer follow on from the bot's position:
+	// as many moves but different ones, then fewer
+	f.games <- jsonLine(t, GameEvent{Type: "gameState", GameStatus: GameStatus{Moves: "g2g4 e7e5", WTime: 170000, BTime: 175000, Status: "started"}})
+	f.expectMove(t, "g2g4 e7e5")
+	f.games <- jsonLine(t, GameEvent{Type: "gameState", GameStatus: GameStatus{Moves: "", WTime: 170000, BTime: 175000, Status: "started"}})
+	f.expectMove(t, "")
+
+	// Once the game is over the bot stops moving, even on its turn
+	f.games <- jsonLine(t, GameEvent{Type: "gameState", GameStatus: GameStatus{Moves: "", Status: "aborted"}})
+	f.expectNoPost(t)
+	close(f.games)
+	f.events <- jsonLine(t, Event{Type: "gameFinish", Game: &GameInfo{GameID: "g1"}})
+	close(f.events)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run didn't return after the streams ended")
+	}
+}
+
+// replyTo finds a legal black reply to a white first move
+func replyTo(t *testing.T, move string) string {
+	t.Helper()
+	g := game.NewGame()
+	m, ok := g.ParseMove(move)
+	if !ok || !g.MakeMove(m) {
+		t.Fatalf("illegal move %s", move)
+	}
+	return uci.MoveString(g.GenerateAllLegalMoves()[0])
+}
+
+func TestBotBadToken(t *testing.T) {
+	_, ts := newFakeLichess(t)
+	if err := New(NewClient(ts.URL, "wrong")).Run(context.Background()); err == nil {
+		t.Error("Run succeeded with a bad token")
+	}
+}
+
+// stubAPI records the moves a gamePlayer makes
+type stubAPI struct {
+	API
+	moves []string
+}
+
+func (s *stubAPI) MakeMove(ctx context.Context, gameID, move string) error {
+	s.moves = append(s.moves, move)
+	return nil
+}
+
+func TestBotTimeLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		clock *GameClock
+		state GameStatus
+		want  func(e *search.Engine) time.Duration
+	}{
+		{"black's clock", &GameClock{Initial: 60000, Increment: 1000},
+			GameStatus{Moves: "e2e4", WTime: 50000, BTime: 20000, WInc: 1000, BInc: 500, Status: "started"},
+			func(e *search.Engine) time.Duration {
+				return e.AllocateTime(20*time.Second-300*time.Millisecond, 500*time.Millisecond, 0)
+			}},
+		{"less time than the overhead", &GameClock{Initial: 60000},
+			GameStatus{Moves: "e2e4", WTime: 50000, BTime: 100, Status: "started"},
+			func(e *search.Engine) time.Duration { return e.AllocateTime(0, 0, 0) }},
+		{"no clock", nil,
+			GameStatus{Moves: "e2e4", Status: "started"},
+			func(e *search.Engine) time.Duration { return search.NewEngine().TimeLimit }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api := &stubAPI{}
+			b := New(api)
+			b.id = "testbot"
+			engine := search.NewEngine()
+			engine.MaxDepth = 1
+			p := &gamePlayer{bot: b, ctx: context.Background(), id: "g1", engine: engine, color: noColor}
+
+			err := p.handle(GameEvent{
+				Type:  "gameFull",
+				White: Player{ID: "alice"},
+				Black: Player{ID: "TestBot"},
+				Clock: test.clock,
+				State: &test.state,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.color != board.Black || len(api.moves) != 1 {
+				t.Fatalf("playing color %d, made %d moves", p.color, len(api.moves))
+			}
+			if want := test.want(engine); engine.TimeLimit != want {
+				t.Errorf("time limit %v, want %v", engine.TimeLimit, want)
+			}
+		})
+	}
+}
+
+func TestGamePlayerSync(t *testing.T) {
+	b := New(&stubAPI{})
+	b.id = "testbot"
+	p := &gamePlayer{bot: b, ctx: context.Background(), id: "g1", engine: search.NewEngine(), color: noColor}
+	err := p.begin(GameEvent{White: Player{ID: "alice"}, Black: Player{ID: "testbot"},
+		InitialFEN: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each list of moves, whether it follows on from the last or not, must
+	// leave the same position as playing it out from the start
+	for _, moves := range []string{"e2e4", "e2e4 e7e5 g1f3", "e2e4 e7e5", "d2d4 d7d5", "d2d4 d7d5 c2c4", ""} {
+		if err := p.sync(strings.Fields(moves)); err != nil {
+			t.Fatalf("%q: %v", moves, err)
+		}
+		want := game.NewGame()
+		for _, text := range strings.Fields(moves) {
+			move, _ := want.ParseMove(text)
+			want.MakeMove(move)
+		}
+		if p.game.FEN() != want.FEN() {
+			t.Errorf("after %q: position %s, want %s", moves, p.game.FEN(), want.FEN())
+		}
+	}
+
+	if err := p.sync([]string{"e2e5"}); err == nil {
+		t.Error("illegal move accepted")
+	}
+}
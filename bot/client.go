@@ -0,0 +1,161 @@
+package bot
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the Lichess server
+const DefaultBaseURL = "https://lichess.org"
+
+// requestTimeout bounds every request but the streams, which stay open
+const requestTimeout = 30 * time.Second
+
+// maxLine is the longest NDJSON line read from a stream
+const maxLine = 1 << 20
+
+// Client speaks the Lichess Bot API over HTTP
+type Client struct {
+	BaseURL string // Server to talk to, without a trailing slash
+	Token   string // API token with the bot:play scope
+	HTTP    *http.Client
+}
+
+// NewClient creates a client for a server, DefaultBaseURL when baseURL is empty
+func NewClient(baseURL, token string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), Token: token, HTTP: &http.Client{}}
+}
+
+// Account returns the bot's own account
+func (c *Client) Account(ctx context.Context) (Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	var account Account
+	resp, err := c.do(ctx, http.MethodGet, "/api/account", nil)
+	if err != nil {
+		return account, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&account); err != nil {
+		return account, fmt.Errorf("reading account: %v", err)
+	}
+	return account, nil
+}
+
+// StreamEvents follows the account's event stream
+func (c *Client) StreamEvents(ctx context.Context, handle func(Event) error) error {
+	return c.stream(ctx, "/api/stream/event", func(line []byte) error {
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			return fmt.Errorf("reading event: %v", err)
+		}
+		return handle(event)
+	})
+}
+
+// StreamGame follows a game's stream
+func (c *Client) StreamGame(ctx context.Context, gameID string, handle func(GameEvent) error) error {
+	return c.stream(ctx, "/api/bot/game/stream/"+url.PathEscape(gameID), func(line []byte) error {
+		var event GameEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return fmt.Errorf("reading game event: %v", err)
+		}
+		return handle(event)
+	})
+}
+
+// AcceptChallenge accepts a challenge
+func (c *Client) AcceptChallenge(ctx context.Context, challengeID string) error {
+	return c.post(ctx, "/api/challenge/"+url.PathEscape(challengeID)+"/accept", nil)
+}
+
+// DeclineChallenge declines a challenge, giving one of the reasons Lichess
+// knows, such as "generic", "later", "tooFast" or "variant"
+func (c *Client) DeclineChallenge(ctx context.Context, challengeID, reason string) error {
+	return c.post(ctx, "/api/challenge/"+url.PathEscape(challengeID)+"/decline", url.Values{"reason": {reason}})
+}
+
+// MakeMove plays a move in a game
+func (c *Client) MakeMove(ctx context.Context, gameID, move string) error {
+	return c.post(ctx, "/api/bot/game/"+url.PathEscape(gameID)+"/move/"+url.PathEscape(move), nil)
+}
+
+// post sends a form and discards the answer
+func (c *Client) post(ctx context.Context, path string, form url.Values) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	resp, err := c.do(ctx, http.MethodPost, path, form)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// stream reads an NDJSON response line by line, skipping the empty lines
+// servers send to keep the connection alive
+func (c *Client) stream(ctx context.Context, path string, handle func(line []byte) error) error {
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLine)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := handle(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("reading %s: %v", path, err)
+	}
+	return ctx.Err()
+}
+
+// do sends a request, turning any status but 2xx into an error
+func (c *Client) do(ctx context.Context, method, path string, form url.Values) (*http.Response, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(message))
+	}
+	return resp, nil
+}
@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"slices"
+	"time"
+)
+
+// Rules decide which challenges the bot accepts
+type Rules struct {
+	Variants []string      // Variant keys accepted
+	Speeds   []string      // Speeds accepted, from "ultraBullet" to "correspondence"
+	MinTime  time.Duration // Shortest initial clock time accepted
+	MaxTime  time.Duration // Longest initial clock time accepted, 0 for no limit
+	Rated    bool          // Accept rated games
+	Casual   bool          // Accept casual games
+	Bots     bool          // Accept challenges from other bots
+	MaxGames int           // Games played at once
+}
+
+// DefaultRules accept standard games at real-time speeds from anyone, one
+// game at a time. The engine can only start from a position it is given, so
+// "fromPosition" is the only other variant it can play
+func DefaultRules() Rules {
+	return Rules{
+		Variants: []string{"standard", "fromPosition"},
+		Speeds:   []string{"bullet", "blitz", "rapid", "classical"},
+		MinTime:  30 * time.Second,
+		Rated:    true,
+		Casual:   true,
+		Bots:     true,
+		MaxGames: 1,
+	}
+}
+
+// Check returns the reason to decline a challenge, as Lichess names it, or
+// "" to accept it. playing is the number of games already being played
+func (r Rules) Check(c Challenge, playing int) string {
+	initial := time.Duration(c.TimeControl.Limit) * time.Second
+	clock := c.TimeControl.Type == "clock"
+	switch {
+	case !slices.Contains(r.Variants, c.Variant.Key):
+		return "variant"
+	case !slices.Contains(r.Speeds, c.Speed):
+		return "timeControl"
+	case clock && initial < r.MinTime:
+		return "tooFast"
+	case clock && r.MaxTime > 0 && initial > r.MaxTime:
+		return "tooSlow"
+	case c.Rated && !r.Rated:
+		return "casual" // Asks for a casual game instead
+	case !c.Rated && !r.Casual:
+		return "rated"
+	case c.Challenger.Title == "BOT" && !r.Bots:
+		return "noBot"
+	case playing >= r.MaxGames:
+		return "later"
+	}
+	return ""
+}
@@ -0,0 +1,1147 @@
+// Command chess is an interactive chess engine, which can also speak UCI and XBoard.
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"chess-engine/board"
+	"chess-engine/book"
+	"chess-engine/bot"
+	"chess-engine/eval"
+	"chess-engine/game"
+	"chess-engine/match"
+	"chess-engine/search"
+	"chess-engine/selfplay"
+	"chess-engine/server"
+	"chess-engine/tablebase"
+	"chess-engine/uci"
+	"chess-engine/xboard"
+)
+
+func main() {
+	scanner := bufio.NewScanner(os.Stdin)
+
+	// GUIs can start the engine straight in UCI or XBoard mode
+	if len(os.Args) > 1 && os.Args[1] == "uci" {
+		uci.Run(scanner, os.Stdout, false)
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "xboard" {
+		xboard.Run(scanner, os.Stdout)
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		handleServeCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bot" {
+		handleBotCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "match" {
+		handleMatchCommand(os.Args[2:])
+		return
+	}
+
+	fmt.Println("Chess Engine v1.0")
+	fmt.Println("=================")
+	fmt.Println("Commands: move, eval, ai, play, autoplay, twoplayer, depth <n>, undo, history, quit, moves, help")
+	fmt.Println()
+
+	g := game.NewGame()
+	engines := [2]*search.Engine{search.NewEngine(), search.NewEngine()} // Settings for the engine playing each side
+	engineSide := [2]bool{}                                              // Which sides the engine plays automatically
+	var clock *game.GameClock                                            // nil when playing without clocks
+	timeResult := ""                                                     // Set when a flag falls
+	for _, engine := range engines {
+		engine.Observer = search.ObserverFunc(printSearch)
+	}
+
+	for {
+
+		g.Board.Display(os.Stdout)
+		if clock != nil {
+			fmt.Println(clock.String())
+		}
+
+		score := eval.Evaluate(g)
+		fmt.Printf("\nPosition evaluation: %+d centipawns", score)
+		if score > 0 {
+			fmt.Print(" (White is better)")
+		} else if score < 0 {
+			fmt.Print(" (Black is better)")
+		} else {
+			fmt.Print(" (Equal position)")
+		}
+		fmt.Println()
+
+		fmt.Printf("\n%s to move", g.GetCurrentPlayerString())
+
+		// Check game status
+		gameOver, result := g.IsGameOver()
+		if !gameOver && timeResult == "" && clock != nil && clock.Flagged(g.CurrentPlayer) {
+			timeResult = g.TimeForfeitResult(g.CurrentPlayer)
+		}
+		if !gameOver && timeResult != "" {
+			gameOver, result = true, timeResult
+		}
+
+		if gameOver {
+			fmt.Printf("\nGame Over: %s\n", result)
+			if clock != nil {
+				clock.Stop()
+			}
+			if engineSide[board.White] || engineSide[board.Black] {
+				// Stop automatic play but leave the REPL open for undo, history etc.
+				engineSide = [2]bool{}
+				fmt.Println("Engine play stopped")
+			}
+		} else {
+			if clock != nil {
+				clock.Start(g.CurrentPlayer)
+			}
+			if engineSide[g.CurrentPlayer] {
+				fmt.Println()
+				if playEngineMove(g, engines[g.CurrentPlayer], clock) {
+					timeResult = punchClock(g, clock)
+				}
+				fmt.Println()
+				continue
+			}
+		}
+
+		if g.Board.IsInCheck(g.CurrentPlayer) {
+			fmt.Print(" (in check)")
+		}
+		fmt.Print(": ")
+
+		// Get input
+		if !scanner.Scan() {
+			break
+		}
+
+		input := strings.TrimSpace(scanner.Text())
+		parts := strings.Fields(input)
+		if len(parts) == 0 {
+			continue
+		}
+
+		command := parts[0]
+
+		switch command {
+		case "quit", "q":
+			fmt.Println("Thanks for playing!")
+			return
+
+		case "eval", "e":
+			trace := eval.EvaluateTrace(g)
+			fmt.Println("Detailed evaluation:")
+			trace.WriteTable(os.Stdout)
+			if eval.CurrentNetwork != nil {
+				fmt.Printf("NNUE score: %+d centipawns (%s)\n", eval.EvaluateNNUE(g, eval.CurrentNetwork), nnueStatus())
+			}
+
+		case "ai":
+			if playEngineMove(g, engines[g.CurrentPlayer], clock) {
+				timeResult = punchClock(g, clock)
+			}
+
+		case "clock":
+			if len(parts) < 2 {
+				if clock == nil {
+					fmt.Println("No clock set. Use clock <control>, e.g. clock 5+3 or clock 40/90+30")
+				} else {
+					fmt.Println(clock.String())
+				}
+				continue
+			}
+			if parts[1] == "off" {
+				clock = nil
+				timeResult = ""
+				fmt.Println("Clock turned off")
+				continue
+			}
+			tc, err := game.ParseTimeControl(parts[1])
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			clock = game.NewGameClock(tc)
+			timeResult = ""
+			fmt.Printf("Clock set to %s\n", tc)
+
+		case "play":
+			color, ok := parseColor(parts[1:])
+			if !ok {
+				fmt.Println("Usage: play white|black")
+				continue
+			}
+			engineSide = [2]bool{}
+			engineSide[1-color] = true
+			fmt.Printf("You play %s, the engine plays %s\n", board.ColorName(color), board.ColorName(1-color))
+
+		case "autoplay":
+			engineSide = [2]bool{true, true}
+			fmt.Println("Engine plays both sides")
+
+		case "twoplayer":
+			engineSide = [2]bool{}
+			fmt.Println("Two-player mode: enter moves for both sides")
+
+		case "depth":
+			sides, args := engineSettingSides(parts[1:])
+			if len(args) == 0 {
+				for _, side := range sides {
+					fmt.Printf("%s search depth: %d\n", board.ColorName(side), engines[side].MaxDepth)
+				}
+				continue
+			}
+			depth, err := strconv.Atoi(args[0])
+			if err != nil || depth <= 0 || depth > 10 {
+				fmt.Println("Invalid depth. Use 1-10")
+				continue
+			}
+			for _, side := range sides {
+				engines[side].MaxDepth = depth
+				fmt.Printf("%s search depth set to %d\n", board.ColorName(side), depth)
+			}
+
+		case "time":
+			sides, args := engineSettingSides(parts[1:])
+			if len(args) == 0 {
+				for _, side := range sides {
+					fmt.Printf("%s time per move: %.1fs\n", board.ColorName(side), engines[side].TimeLimit.Seconds())
+				}
+				continue
+			}
+			seconds, err := strconv.ParseFloat(args[0], 64)
+			if err != nil || seconds <= 0 {
+				fmt.Println("Invalid time. Give seconds per move, e.g. time 2.5")
+				continue
+			}
+			for _, side := range sides {
+				engines[side].TimeLimit = time.Duration(seconds * float64(time.Second))
+				fmt.Printf("%s time per move set to %.1fs\n", board.ColorName(side), seconds)
+			}
+
+		case "moves", "m":
+			moves := g.GenerateAllLegalMoves()
+			fmt.Printf("Legal moves (%d):\n", len(moves))
+			for i, move := range moves {
+				if i > 0 && i%8 == 0 {
+					fmt.Println()
+				}
+				fmt.Printf("%-6s ", move.String())
+			}
+			fmt.Println()
+
+		case "undo", "u":
+			if move, ok := g.UndoMove(); ok {
+				takeBackClock(g, clock)
+				fmt.Printf("Took back: %s\n", move.String())
+			} else {
+				fmt.Println("No moves to undo")
+			}
+
+		case "redo":
+			if clock != nil {
+				// A replayed move would skip the clock, so it has to be played again
+				fmt.Println("Redo isn't available with the clock on, play the move instead")
+				continue
+			}
+			if move, ok := g.RedoMove(); ok {
+				fmt.Printf("Replayed: %s\n", move.String())
+			} else {
+				fmt.Println("No moves to redo")
+			}
+
+		case "takeback":
+			// Take back a full move so the same side is to move again
+			undone := 0
+			for undone < 2 {
+				if _, ok := g.UndoMove(); !ok {
+					break
+				}
+				takeBackClock(g, clock)
+				undone++
+			}
+			if undone == 0 {
+				fmt.Println("No moves to take back")
+			} else {
+				fmt.Printf("Took back %d half-moves\n", undone)
+			}
+
+		case "history":
+			printHistory(g)
+
+		case "pgn":
+			if len(parts) < 2 {
+				fmt.Println("Usage: pgn <file> [game number]")
+				continue
+			}
+			index := 1
+			if len(parts) >= 3 {
+				n, err := strconv.Atoi(parts[2])
+				if err != nil || n < 1 {
+					fmt.Println("Invalid game number")
+					continue
+				}
+				index = n
+			}
+			loaded, err := loadPGNFile(parts[1], index)
+			if err != nil {
+				fmt.Printf("Could not load game: %v\n", err)
+				continue
+			}
+			g = loaded
+			fmt.Printf("Loaded game %d (%d moves played)\n", index, len(g.MoveHistory))
+
+			// Time used and flags fallen in the old game don't carry over
+			timeResult = ""
+			if clock != nil {
+				clock = game.NewGameClock(clock.Control)
+				fmt.Printf("Clock reset to %s\n", clock.Control)
+			}
+
+		case "book":
+			handleBookCommand(parts[1:], engines)
+
+		case "buildbook":
+			handleBuildBookCommand(parts[1:])
+
+		case "syzygy":
+			handleSyzygyCommand(parts[1:], engines)
+
+		case "params":
+			handleParamsCommand(parts[1:])
+
+		case "tune":
+			handleTuneCommand(parts[1:])
+
+		case "nnue":
+			handleNNUECommand(parts[1:])
+
+		case "gensfens":
+			handleGenSfensCommand(parts[1:])
+
+		case "uci":
+			uci.Run(scanner, os.Stdout, true)
+			return
+
+		case "xboard":
+			xboard.Run(scanner, os.Stdout)
+			return
+
+		case "serve":
+			handleServeCommand(parts[1:])
+
+		case "bot":
+			handleBotCommand(parts[1:])
+
+		case "match":
+			handleMatchCommand(parts[1:])
+
+		case "help", "h":
+			fmt.Println("Commands:")
+			fmt.Println("  <move>    - Make a move (e.g., e2e4, O-O)")
+			fmt.Println("  ai        - Let the AI make a move")
+			fmt.Println("  play white|black - Play a side against the engine")
+			fmt.Println("  autoplay  - Let the engine play both sides")
+			fmt.Println("  twoplayer - Enter moves for both sides")
+			fmt.Println("  eval      - Show detailed position evaluation")
+			fmt.Println("  depth <n> [white|black] - Set AI search depth (1-10), for both sides by default")
+			fmt.Println("  time <s> [white|black]  - Set AI time per move in seconds")
+			fmt.Println("  moves     - Show all legal moves")
+			fmt.Println("  undo      - Take back the last half-move")
+			fmt.Println("  redo      - Replay a half-move that was taken back")
+			fmt.Println("  takeback  - Take back a full move (both sides)")
+			fmt.Println("  history   - Show the moves played so far")
+			fmt.Println("  clock <tc> - Play with clocks, e.g. 5+3, 40/90+30 or 15d5 (delay); clock off to stop")
+			fmt.Println("  pgn <file> [n] - Load game n (default 1) from a PGN file")
+			fmt.Println("  book <file>    - Use a Polyglot opening book; book off to stop")
+			fmt.Println("  book best|random - Play the top book move or pick by weight")
+			fmt.Println("  book depth <n> - Only use the book for the first n moves")
+			fmt.Println("  buildbook <out.bin> <file.pgn>... [ply=N] [mingames=N] [minrating=N]")
+			fmt.Println("            - Build a Polyglot book from PGN games")
+			fmt.Println("  syzygy <path>  - Use Syzygy tablebases (dirs separated by ':'); syzygy off to stop")
+			fmt.Println("  syzygy depth <n> - Only probe in search with at least n plies left")
+			fmt.Println("  params load <file> - Load evaluation weights from a JSON file")
+			fmt.Println("  params reload|default - Reread the weights file or go back to the built-in weights")
+			fmt.Println("  params save <file> - Write the current evaluation weights to a file")
+			fmt.Println("  tune <data> <out.json> [passes=N] [threads=N] [step=N] [k=X]")
+			fmt.Println("            - Tune the evaluation weights on positions labelled with game results")
+			fmt.Println("  nnue <file>    - Evaluate with a neural network; nnue on|off to switch evaluators")
+			fmt.Println("  gensfens <out> [games=N] [depth=N] [threads=N] [random=N] [maxply=N] [format=text|binary]")
+			fmt.Println("            - Generate training positions from engine self-play")
+			fmt.Println("  uci       - Switch to UCI protocol mode")
+			fmt.Println("  xboard    - Switch to XBoard protocol mode")
+			fmt.Println("  serve [addr=:8080] [engines=N] [depth=N] [maxdepth=N] [time=S] [multipv=N] [streams=N]")
+			fmt.Println("            - Serve analysis over HTTP as JSON")
+			fmt.Println("  bot [token=T] [url=U] [depth=N] [games=N] [speeds=a,b] [variants=a,b] [mintime=S] [maxtime=S]")
+			fmt.Println("      [rated=yes|no] [casual=yes|no] [bots=yes|no] [overhead=MS]")
+			fmt.Println("            - Play online through the Lichess Bot API until interrupted;")
+			fmt.Println("              the token defaults to $LICHESS_BOT_TOKEN")
+			fmt.Println("  match <engine> [games=N] [tc=5+3] [movetime=MS] [depth=N] [maxplies=N] [option.Name=Value]...")
+			fmt.Println("            - Play games against an external UCI engine, alternating colors")
+			fmt.Println("  quit      - Exit the game")
+			fmt.Println("  help      - Show this help")
+
+		default:
+			// Try to parse as a move
+			move, valid := g.ParseMove(input)
+			if !valid {
+				fmt.Println("Invalid move format. Try e2e4 or O-O")
+				continue
+			}
+
+			if g.MakeMove(move) {
+				fmt.Printf("Played: %s\n", move.String())
+				timeResult = punchClock(g, clock)
+			} else {
+				fmt.Println("Illegal move!")
+			}
+		}
+		fmt.Println()
+	}
+}
+
+// loadPGNFile replays the main line of a game from a PGN file
+func loadPGNFile(path string, index int) (*game.GameState, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pg, err := game.LoadPGNGame(f, index)
+	if err != nil {
+		return nil, err
+	}
+	return pg.GameState()
+}
+
+// printHistory lists the game so far in numbered SAN pairs
+func printHistory(game *game.GameState) {
+	sans := game.HistorySAN()
+	if len(sans) == 0 {
+		fmt.Println("No moves played yet")
+		return
+	}
+
+	// Work out which side made the first recorded move
+	start := game.InitialPosition()
+	moveNumber := start.FullMoveNumber
+
+	i := 0
+	if start.CurrentPlayer == board.Black {
+		fmt.Printf("%d... %s\n", moveNumber, sans[0])
+		moveNumber++
+		i = 1
+	}
+	for ; i < len(sans); i += 2 {
+		if i+1 < len(sans) {
+			fmt.Printf("%d. %-8s %s\n", moveNumber, sans[i], sans[i+1])
+		} else {
+			fmt.Printf("%d. %s\n", moveNumber, sans[i])
+		}
+		moveNumber++
+	}
+}
+
+// playEngineMove searches with the given engine and plays its choice.
+// With a clock running the engine's time comes from the clock instead of its time setting
+func playEngineMove(game *game.GameState, engine *search.Engine, clock *game.GameClock) bool {
+	fmt.Printf("AI (%s) is thinking...\n", game.GetCurrentPlayerString())
+
+	if clock != nil {
+		color := game.CurrentPlayer
+		timeLimit := engine.TimeLimit
+		engine.TimeLimit = engine.AllocateTime(clock.TimeLeft(color),
+			clock.Control.Increment+clock.Control.Delay, clock.MovesToGo(color))
+		defer func() { engine.TimeLimit = timeLimit }()
+	}
+
+	result := engine.SearchBestMoveOrdered(game)
+
+	if result.BestMove.PieceType == board.Empty {
+		fmt.Println("AI couldn't find a move")
+		return false
+	}
+
+	san := game.MoveToSAN(result.BestMove)
+	if result.FromBook {
+		fmt.Printf("\nAI chooses: %s (book move)\n", san)
+	} else if result.FromTablebase {
+		fmt.Printf("\nAI chooses: %s (tablebase move, score: %+d)\n", san, result.Score)
+	} else {
+		fmt.Printf("\nAI chooses: %s (score: %+d)\n", san, result.Score)
+		fmt.Printf("Search info: %d nodes, depth %d, %.2fs\n",
+			result.NodesVisited, result.Depth, result.Duration.Seconds())
+	}
+
+	if !game.MakeMove(result.BestMove) {
+		fmt.Println("Error: AI suggested illegal move!")
+		return false
+	}
+	return true
+}
+
+// printSearch shows the engine's progress as it searches
+func printSearch(event search.Event) {
+	switch ev := event.(type) {
+	case search.SearchStarted:
+		fmt.Printf("Searching with %s at depth %d...\n", ev.Algorithm, ev.Depth)
+	case search.MoveSearched:
+		fmt.Printf("Move %d/%d: %s -> %+d\n", ev.Number, ev.Total, ev.Move.String(), ev.Score)
+	case search.TimeLimitReached:
+		fmt.Println("Time limit reached!")
+	}
+}
+
+// punchClock completes the move just played on the clock, returning the
+// result of the game if the mover's flag fell
+func punchClock(game *game.GameState, clock *game.GameClock) string {
+	if clock == nil {
+		return ""
+	}
+	mover := 1 - game.CurrentPlayer
+	if clock.Punch() {
+		return game.TimeForfeitResult(mover)
+	}
+	return ""
+}
+
+// takeBackClock rewinds the clock's move count after the side to move has
+// had its last move taken back
+func takeBackClock(game *game.GameState, clock *game.GameClock) {
+	if clock != nil {
+		clock.TakeBack(game.CurrentPlayer)
+	}
+}
+
+// parseColor reads "white" or "black" from the first argument
+func parseColor(args []string) (int, bool) {
+	if len(args) == 0 {
+		return board.White, false
+	}
+	switch strings.ToLower(args[0]) {
+	case "white", "w":
+		return board.White, true
+	case "black", "b":
+		return board.Black, true
+	}
+	return board.White, false
+}
+
+// engineSettingSides picks out an optional side from settings arguments.
+// With no side given, settings apply to both engines
+func engineSettingSides(args []string) ([]int, []string) {
+	sides := []int{board.White, board.Black}
+	var rest []string
+	for _, arg := range args {
+		if color, ok := parseColor([]string{arg}); ok {
+			sides = []int{color}
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	return sides, rest
+}
+
+// handleBookCommand sets up the opening book for both engines
+func handleBookCommand(args []string, engines [2]*search.Engine) {
+	if len(args) == 0 {
+		if engines[board.White].Book == nil {
+			fmt.Println("No opening book loaded")
+			return
+		}
+		mode := "random"
+		if engines[board.White].BookSelection == book.BookBest {
+			mode = "best"
+		}
+		fmt.Printf("Book: %s (%d entries, %s moves, first %d moves)\n",
+			engines[board.White].Book.Path, engines[board.White].Book.Size(), mode, engines[board.White].BookDepth)
+		return
+	}
+
+	switch args[0] {
+	case "off":
+		for _, engine := range engines {
+			engine.Book = nil
+		}
+		fmt.Println("Opening book turned off")
+
+	case "best", "random":
+		selection := book.BookWeighted
+		if args[0] == "best" {
+			selection = book.BookBest
+		}
+		for _, engine := range engines {
+			engine.BookSelection = selection
+		}
+		fmt.Printf("Book selection set to %s\n", args[0])
+
+	case "depth":
+		if len(args) < 2 {
+			fmt.Printf("Book depth: %d moves\n", engines[board.White].BookDepth)
+			return
+		}
+		depth, err := strconv.Atoi(args[1])
+		if err != nil || depth < 0 {
+			fmt.Println("Invalid book depth")
+			return
+		}
+		for _, engine := range engines {
+			engine.BookDepth = depth
+		}
+		fmt.Printf("Book used for the first %d moves\n", depth)
+
+	default:
+		book, err := book.OpenPolyglotBook(args[0])
+		if err != nil {
+			fmt.Printf("Could not load book: %v\n", err)
+			return
+		}
+		for _, engine := range engines {
+			engine.Book = book
+		}
+		fmt.Printf("Loaded book %s (%d entries)\n", args[0], book.Size())
+	}
+}
+
+// handleSyzygyCommand sets up endgame tablebases for both engines
+func handleSyzygyCommand(args []string, engines [2]*search.Engine) {
+	if len(args) == 0 {
+		if engines[board.White].Tablebases == nil {
+			fmt.Println("No tablebases loaded")
+			return
+		}
+		fmt.Printf("Tablebases: %s (up to %d pieces, probe depth %d)\n",
+			engines[board.White].Tablebases.Path, engines[board.White].Tablebases.MaxPieces, engines[board.White].SyzygyProbeDepth)
+		return
+	}
+
+	switch args[0] {
+	case "off":
+		if engines[board.White].Tablebases != nil {
+			engines[board.White].Tablebases.Close()
+		}
+		for _, engine := range engines {
+			engine.Tablebases = nil
+		}
+		fmt.Println("Tablebases turned off")
+
+	case "depth":
+		if len(args) < 2 {
+			fmt.Printf("Probe depth: %d\n", engines[board.White].SyzygyProbeDepth)
+			return
+		}
+		depth, err := strconv.Atoi(args[1])
+		if err != nil || depth < 1 {
+			fmt.Println("Invalid probe depth")
+			return
+		}
+		for _, engine := range engines {
+			engine.SyzygyProbeDepth = depth
+		}
+		fmt.Printf("Probing tablebases with at least %d plies left\n", depth)
+
+	default:
+		tb, err := tablebase.OpenSyzygy(args[0])
+		if err != nil {
+			fmt.Printf("Could not load tablebases: %v\n", err)
+			return
+		}
+		if engines[board.White].Tablebases != nil {
+			engines[board.White].Tablebases.Close()
+		}
+		for _, engine := range engines {
+			engine.Tablebases = tb
+		}
+		fmt.Printf("Loaded tablebases from %s (up to %d pieces)\n", args[0], tb.MaxPieces)
+	}
+}
+
+// handleBuildBookCommand builds an opening book from PGN files
+func handleBuildBookCommand(args []string) {
+	options := book.BookBuilderOptions{MaxPly: 30, MinGames: 1}
+	var files []string
+	for _, arg := range args {
+		name, value, isOption := strings.Cut(arg, "=")
+		if !isOption {
+			files = append(files, arg)
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			fmt.Printf("Invalid value for %s\n", name)
+			return
+		}
+		switch name {
+		case "ply":
+			options.MaxPly = n
+		case "mingames":
+			options.MinGames = n
+		case "minrating":
+			options.MinRating = n
+		default:
+			fmt.Printf("Unknown option %s\n", name)
+			return
+		}
+	}
+
+	if len(files) < 2 {
+		fmt.Println("Usage: buildbook <out.bin> <file.pgn>... [ply=N] [mingames=N] [minrating=N]")
+		return
+	}
+
+	fmt.Printf("Building book from %d PGN files...\n", len(files)-1)
+	bb, entries, err := book.BuildPolyglotBook(files[0], files[1:], options)
+	if err != nil {
+		fmt.Printf("Could not build book: %v\n", err)
+		return
+	}
+	fmt.Printf("Wrote %s: %d entries from %d games (%d skipped)\n",
+		files[0], entries, bb.GamesAdded, bb.GamesSkipped)
+}
+
+// handleParamsCommand loads, reloads and saves the evaluation weights
+func handleParamsCommand(args []string) {
+	if len(args) == 0 {
+		if eval.EvalParamsFile == "" {
+			fmt.Println("Using the built-in evaluation parameters")
+		} else {
+			fmt.Printf("Evaluation parameters: %s\n", eval.EvalParamsFile)
+		}
+		return
+	}
+
+	switch args[0] {
+	case "load":
+		if len(args) < 2 {
+			fmt.Println("Usage: params load <file>")
+			return
+		}
+		if err := eval.UseEvalParams(args[1]); err != nil {
+			fmt.Printf("Could not load evaluation parameters: %v\n", err)
+			return
+		}
+		fmt.Printf("Loaded evaluation parameters from %s\n", args[1])
+
+	case "reload":
+		if eval.EvalParamsFile == "" {
+			fmt.Println("No parameter file loaded")
+			return
+		}
+		if err := eval.UseEvalParams(eval.EvalParamsFile); err != nil {
+			fmt.Printf("Could not reload evaluation parameters: %v\n", err)
+			return
+		}
+		fmt.Printf("Reloaded evaluation parameters from %s\n", eval.EvalParamsFile)
+
+	case "default":
+		if err := eval.UseEvalParams(""); err != nil {
+			fmt.Printf("Could not restore evaluation parameters: %v\n", err)
+			return
+		}
+		fmt.Println("Using the built-in evaluation parameters")
+
+	case "save":
+		if len(args) < 2 {
+			fmt.Println("Usage: params save <file>")
+			return
+		}
+		if err := eval.SaveEvalParams(args[1], eval.CurrentEvalParams()); err != nil {
+			fmt.Printf("Could not save evaluation parameters: %v\n", err)
+			return
+		}
+		fmt.Printf("Saved evaluation parameters to %s\n", args[1])
+
+	default:
+		fmt.Println("Usage: params [load <file>|reload|default|save <file>]")
+	}
+}
+
+// handleTuneCommand tunes the evaluation weights on a file of labelled positions
+func handleTuneCommand(args []string) {
+	var options eval.TunerOptions
+	var files []string
+	for _, arg := range args {
+		name, value, isOption := strings.Cut(arg, "=")
+		if !isOption {
+			files = append(files, arg)
+			continue
+		}
+		if name == "k" {
+			k, err := strconv.ParseFloat(value, 64)
+			if err != nil || k <= 0 {
+				fmt.Println("Invalid value for k")
+				return
+			}
+			options.K = k
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			fmt.Printf("Invalid value for %s\n", name)
+			return
+		}
+		switch name {
+		case "passes":
+			options.MaxPasses = n
+		case "threads":
+			options.Threads = n
+		case "step":
+			options.Step = n
+		default:
+			fmt.Printf("Unknown option %s\n", name)
+			return
+		}
+	}
+
+	if len(files) != 2 {
+		fmt.Println("Usage: tune <data> <out.json> [passes=N] [threads=N] [step=N] [k=X]")
+		return
+	}
+
+	positions, skipped, err := eval.LoadTuningData(files[0])
+	if err != nil {
+		fmt.Printf("Could not load tuning data: %v\n", err)
+		return
+	}
+	fmt.Printf("Loaded %d positions (%d not quiet, skipped)\n", len(positions), skipped)
+
+	tuner := eval.NewTuner(positions, options)
+	tuner.Log = os.Stdout
+	tuner.Save = func(p *eval.EvalParams) error { return eval.SaveEvalParams(files[1], p) }
+	if _, err := tuner.Run(); err != nil {
+		fmt.Printf("Tuning failed: %v\n", err)
+		return
+	}
+	eval.EvalParamsFile = files[1]
+	fmt.Printf("Wrote tuned parameters to %s\n", files[1])
+}
+
+// handleNNUECommand loads a network and switches between the classical and NNUE evaluators
+func handleNNUECommand(args []string) {
+	if len(args) == 0 {
+		if eval.CurrentNetwork == nil {
+			fmt.Println("No network loaded, using the classical evaluation")
+			return
+		}
+		fmt.Printf("Network: %s (%d hidden), %s\n", eval.CurrentNetwork.Path, eval.CurrentNetwork.Hidden, nnueStatus())
+		return
+	}
+
+	switch args[0] {
+	case "on":
+		if eval.CurrentNetwork == nil {
+			fmt.Println("Load a network first with nnue <file>")
+			return
+		}
+		eval.UseNNUE = true
+		fmt.Println("Using the NNUE evaluation")
+
+	case "off":
+		eval.UseNNUE = false
+		fmt.Println("Using the classical evaluation")
+
+	default:
+		net, err := eval.LoadNetwork(args[0])
+		if err != nil {
+			fmt.Printf("Could not load network: %v\n", err)
+			return
+		}
+		eval.CurrentNetwork, eval.UseNNUE = net, true
+		fmt.Printf("Loaded network %s (%d hidden), using the NNUE evaluation\n", args[0], net.Hidden)
+	}
+}
+
+// nnueStatus says which evaluator search uses
+func nnueStatus() string {
+	if eval.UseNNUE && eval.CurrentNetwork != nil {
+		return "in use"
+	}
+	return "not in use"
+}
+
+// handleGenSfensCommand writes training positions from self-play games
+func handleGenSfensCommand(args []string) {
+	options := selfplay.DefaultGenSfensOptions()
+	var files []string
+	for _, arg := range args {
+		name, value, isOption := strings.Cut(arg, "=")
+		if !isOption {
+			files = append(files, arg)
+			continue
+		}
+		if name == "format" {
+			switch value {
+			case "text":
+				options.Format = selfplay.SfenText
+			case "binary":
+				options.Format = selfplay.SfenBinary
+			default:
+				fmt.Println("Format must be text or binary")
+				return
+			}
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			fmt.Printf("Invalid value for %s\n", name)
+			return
+		}
+		switch name {
+		case "games":
+			options.Games = n
+		case "depth":
+			options.Depth = max(1, n)
+		case "threads":
+			options.Threads = n
+		case "random":
+			options.RandomPlies = n
+		case "maxply":
+			options.MaxPlies = n
+		default:
+			fmt.Printf("Unknown option %s\n", name)
+			return
+		}
+	}
+
+	if len(files) != 1 {
+		fmt.Println("Usage: gensfens <out> [games=N] [depth=N] [threads=N] [random=N] [maxply=N] [format=text|binary]")
+		return
+	}
+
+	fmt.Printf("Playing %d games at depth %d...\n", options.Games, options.Depth)
+	start := time.Now()
+	stats, err := selfplay.GenerateSfensFile(files[0], options, func(s selfplay.GenSfensStats) {
+		if s.Games%10 == 0 {
+			fmt.Printf("%d games, %d positions\n", s.Games, s.Positions)
+		}
+	})
+	if err != nil {
+		fmt.Printf("Could not generate training data: %v\n", err)
+		return
+	}
+	fmt.Printf("Wrote %d positions from %d games to %s in %s\n",
+		stats.Positions, stats.Games, files[0], time.Since(start).Round(time.Second))
+}
+
+// handleServeCommand serves the HTTP analysis API until the server fails
+func handleServeCommand(args []string) {
+	addr := ":8080"
+	options := server.DefaultOptions()
+	for _, arg := range args {
+		name, value, _ := strings.Cut(arg, "=")
+		if name == "addr" {
+			addr = value
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			fmt.Printf("Invalid value for %s\n", name)
+			return
+		}
+		switch name {
+		case "engines":
+			options.Engines = n
+		case "depth":
+			options.DefaultDepth = max(1, n)
+		case "maxdepth":
+			options.MaxDepth = max(1, n)
+		case "time":
+			options.MaxTime = time.Duration(max(1, n)) * time.Second
+		case "multipv":
+			options.MaxMultiPV = max(1, n)
+		case "streams":
+			options.MaxStreams = n
+		default:
+			fmt.Printf("Unknown option %s\n", name)
+			return
+		}
+	}
+	options.DefaultDepth = min(options.DefaultDepth, options.MaxDepth)
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           server.New(options),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Printf("Serving analysis on %s\n", addr)
+	if err := srv.ListenAndServe(); err != nil {
+		fmt.Printf("Server stopped: %v\n", err)
+	}
+}
+
+// handleBotCommand plays online as a bot until interrupted or the event
+// stream fails
+func handleBotCommand(args []string) {
+	token, baseURL := os.Getenv("LICHESS_BOT_TOKEN"), bot.DefaultBaseURL
+	depth := search.NewEngine().MaxDepth
+	rules := bot.DefaultRules()
+	overhead := 300 * time.Millisecond
+	for _, arg := range args {
+		name, value, _ := strings.Cut(arg, "=")
+		switch name {
+		case "token":
+			token = value
+		case "url":
+			baseURL = value
+		case "speeds":
+			rules.Speeds = strings.Split(value, ",")
+		case "variants":
+			rules.Variants = strings.Split(value, ",")
+		case "rated", "casual", "bots":
+			if value != "yes" && value != "no" {
+				fmt.Printf("Invalid value for %s\n", name)
+				return
+			}
+			switch name {
+			case "rated":
+				rules.Rated = value == "yes"
+			case "casual":
+				rules.Casual = value == "yes"
+			case "bots":
+				rules.Bots = value == "yes"
+			}
+		case "depth", "games", "mintime", "maxtime", "overhead":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				fmt.Printf("Invalid value for %s\n", name)
+				return
+			}
+			switch name {
+			case "depth":
+				depth = max(1, n)
+			case "games":
+				rules.MaxGames = max(1, n)
+			case "mintime":
+				rules.MinTime = time.Duration(n) * time.Second
+			case "maxtime":
+				rules.MaxTime = time.Duration(n) * time.Second
+			case "overhead":
+				overhead = time.Duration(n) * time.Millisecond
+			}
+		default:
+			fmt.Printf("Unknown option %s\n", name)
+			return
+		}
+	}
+	if token == "" {
+		fmt.Println("No API token: give token=T or set LICHESS_BOT_TOKEN")
+		return
+	}
+
+	b := bot.New(bot.NewClient(baseURL, token))
+	b.Rules, b.MoveOverhead, b.Out = rules, overhead, os.Stdout
+	b.NewEngine = func() *search.Engine {
+		engine := search.NewEngine()
+		engine.MaxDepth = depth
+		return engine
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := b.Run(ctx); err != nil && ctx.Err() == nil {
+		fmt.Printf("Bot stopped: %v\n", err)
+	}
+}
+
+// handleMatchCommand plays a match against an external UCI engine
+func handleMatchCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: match <engine> [games=N] [tc=5+3] [movetime=MS] [depth=N] [maxplies=N] [option.Name=Value]...")
+		return
+	}
+
+	engine := search.NewEngine()
+	options := match.Options{Games: 2, MoveTime: time.Second, MaxPlies: 300}
+	engineOptions := map[string]string{}
+	for _, arg := range args[1:] {
+		name, value, _ := strings.Cut(arg, "=")
+		if option, ok := strings.CutPrefix(name, "option."); ok {
+			engineOptions[option] = value
+			continue
+		}
+		if name == "tc" {
+			tc, err := game.ParseTimeControl(value)
+			if err != nil {
+				fmt.Printf("Invalid time control: %v\n", err)
+				return
+			}
+			options.Control = tc
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			fmt.Printf("Invalid value for %s\n", name)
+			return
+		}
+		switch name {
+		case "games":
+			options.Games = n
+		case "movetime":
+			options.MoveTime = time.Duration(max(1, n)) * time.Millisecond
+		case "depth":
+			engine.MaxDepth = max(1, n)
+		case "maxplies":
+			options.MaxPlies = n
+		default:
+			fmt.Printf("Unknown option %s\n", name)
+			return
+		}
+	}
+
+	opponent, err := uci.StartEngine(args[0])
+	if err != nil {
+		fmt.Printf("Error starting engine: %v\n", err)
+		return
+	}
+	defer opponent.Quit()
+	for name, value := range engineOptions {
+		if err := opponent.SetOption(name, value); err != nil {
+			fmt.Printf("Error setting option: %v\n", err)
+			return
+		}
+	}
+
+	limit := fmt.Sprintf("%v a move", options.MoveTime)
+	if options.Control.Base > 0 {
+		limit = options.Control.String()
+	}
+	fmt.Printf("Playing %d games against %s (%s)\n", options.Games, opponent.Name, limit)
+	stats, err := match.Run(engine, opponent, options, func(result match.GameResult, stats match.Stats) {
+		score := result.Score
+		if result.EngineColor == board.Black {
+			score = 1 - score
+		}
+		fmt.Printf("Game %d, playing %s: %s %s (%d plies). Score %s\n", result.Number,
+			strings.ToLower(board.ColorName(result.EngineColor)), resultText(score), result.Reason,
+			len(result.Moves), stats)
+	})
+	if err != nil {
+		fmt.Printf("Match stopped: %v\n", err)
+	}
+	fmt.Printf("Final score after %d games: %s\n", stats.Games(), stats)
+}
+
+// resultText writes white's score the way PGN does
+func resultText(whiteScore float64) string {
+	switch whiteScore {
+	case 1:
+		return "1-0"
+	case 0:
+		return "0-1"
+	}
+	return "1/2-1/2"
+}
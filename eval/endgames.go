@@ -0,0 +1,372 @@
+package eval
+
+import (
+	"strings"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+// Scale factors shrink the endgame half of the evaluation in drawish endings
+const (
+	ScaleDraw   = 0
+	ScaleNormal = 64
+)
+
+// EndgameWinScore is added to the score of endings that are known wins, so
+// search heads for them. It stays below tablebase and mate scores
+const EndgameWinScore = 10000
+
+// endgameFunc scores a known ending from the strong side's point of view
+type endgameFunc func(g *game.GameState, strong int) int
+
+// scaleFunc returns the scale factor for an ending, ScaleNormal to leave it alone
+type scaleFunc func(g *game.GameState, strong int) int
+
+// endgameEntry is a registered function and the side it was written for
+type endgameEntry struct {
+	name     string
+	strong   int
+	evaluate endgameFunc
+	scale    scaleFunc
+}
+
+// Specialized evaluators and scale factors by material signature, e.g. KRPvKR.
+// Every ending is registered under both colors
+var (
+	endgameEvaluators = make(map[string]endgameEntry)
+	endgameScales     = make(map[string]endgameEntry)
+)
+
+func init() {
+	for _, signature := range []string{"KvK", "KBvK", "KNvK", "KNNvK"} {
+		registerEndgame(signature, evaluateDraw)
+	}
+	registerEndgame("KBNvK", evaluateKBNK)
+	registerEndgame("KPvK", evaluateKPK)
+	registerEndgame("KRvKP", evaluateKRKP)
+	registerEndgame("KRvKB", evaluateKRKB)
+	registerEndgame("KRvKN", evaluateKRKN)
+	registerEndgame("KQvKR", evaluateKQKR)
+
+	registerScale("KRPvKR", scaleKRPKR)
+}
+
+// registerEndgame adds an evaluator for a signature with the strong side first
+func registerEndgame(signature string, f endgameFunc) {
+	name := strings.Replace(signature, "v", "", 1)
+	endgameEvaluators[signature] = endgameEntry{name: name, strong: board.White, evaluate: f}
+	endgameEvaluators[mirrorSignature(signature)] = endgameEntry{name: name, strong: board.Black, evaluate: f}
+}
+
+// registerScale adds a scale factor for a signature with the strong side first
+func registerScale(signature string, f scaleFunc) {
+	name := strings.Replace(signature, "v", "", 1)
+	endgameScales[signature] = endgameEntry{name: name, strong: board.White, scale: f}
+	endgameScales[mirrorSignature(signature)] = endgameEntry{name: name, strong: board.Black, scale: f}
+}
+
+// mirrorSignature swaps the sides of a material signature, KRvKP becoming KPvKR
+func mirrorSignature(signature string) string {
+	white, black, _ := strings.Cut(signature, "v")
+	return black + "v" + white
+}
+
+// probeEndgame looks for a specialized evaluator for the position, returning
+// its score, positive for white, and its name
+func probeEndgame(g *game.GameState) (int, string, bool) {
+	b := g.Board
+	signature := b.MaterialSignature()
+	entry, ok := endgameEvaluators[signature]
+	if !ok {
+		// Any lone king against enough material to force mate
+		strong := -1
+		switch {
+		case strings.HasSuffix(signature, "vK"):
+			strong = board.White
+		case strings.HasPrefix(signature, "Kv"):
+			strong = board.Black
+		}
+		if strong == -1 || (pieceCount(b, strong) < 2 && !hasMajorPiece(b, strong)) {
+			return 0, "", false
+		}
+		entry = endgameEntry{name: "KXK", strong: strong, evaluate: evaluateKXK}
+	}
+
+	score := entry.evaluate(g, entry.strong)
+	if entry.strong == board.Black {
+		score = -score
+	}
+	return score, entry.name, true
+}
+
+// endgameScale returns the scale factor for the endgame half of the
+// evaluation. eg is that half, and decides which side is stronger
+func endgameScale(g *game.GameState, eg int) (int, string) {
+	signature := g.Board.MaterialSignature()
+	if entry, ok := endgameScales[signature]; ok {
+		return entry.scale(g, entry.strong), entry.name
+	}
+
+	strong := board.White
+	if eg < 0 {
+		strong = board.Black
+	}
+	if scale := scaleOppositeBishops(g, strong); scale != ScaleNormal {
+		return scale, "opposite bishops"
+	}
+	if scale := scaleRookPawns(g, strong); scale != ScaleNormal {
+		return scale, "rook pawns"
+	}
+	return ScaleNormal, ""
+}
+
+// pieceSquares lists the squares of color's pieces of one type
+func pieceSquares(b *board.Board, color, pieceType int) [][2]int {
+	var squares [][2]int
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			if piece := b.GetPiece(row, col); piece.Type == pieceType && piece.Color == color {
+				squares = append(squares, [2]int{row, col})
+			}
+		}
+	}
+	return squares
+}
+
+// pieceCount counts color's pieces other than pawns and the king. Endings are
+// told apart by counting pieces, as the piece values can be tuned to anything
+func pieceCount(b *board.Board, color int) int {
+	count := 0
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			piece := b.GetPiece(row, col)
+			if piece.Color == color && piece.Type != board.Empty && piece.Type != board.Pawn && piece.Type != board.King {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// hasMajorPiece reports whether color has a rook or a queen
+func hasMajorPiece(b *board.Board, color int) bool {
+	return len(pieceSquares(b, color, board.Rook)) > 0 || len(pieceSquares(b, color, board.Queen)) > 0
+}
+
+// squareDistance is the king distance between two squares
+func squareDistance(a, b [2]int) int {
+	return kingDistance(a[0], a[1], b[0], b[1])
+}
+
+// pushToEdge is larger the closer a square is to the edge, and largest in the corners
+func pushToEdge(sq [2]int) int {
+	return 10 * (abs(2*sq[0]-7) + abs(2*sq[1]-7))
+}
+
+// pushClose is larger the closer two squares are
+func pushClose(a, b [2]int) int {
+	return 140 - 20*squareDistance(a, b)
+}
+
+// kingSquare returns the square of color's king
+func kingSquare(b *board.Board, color int) [2]int {
+	row, col := b.FindKing(color)
+	return [2]int{row, col}
+}
+
+// evaluateDraw scores endings where neither side can win
+func evaluateDraw(g *game.GameState, strong int) int {
+	return 0
+}
+
+// evaluateKXK drives the lone king to the edge and brings the other king close
+func evaluateKXK(g *game.GameState, strong int) int {
+	b := g.Board
+	strongKing, weakKing := kingSquare(b, strong), kingSquare(b, 1-strong)
+
+	score := pushToEdge(weakKing) + pushClose(strongKing, weakKing)
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			if piece := b.GetPiece(row, col); piece.Color == strong && piece.Type != board.Empty && piece.Type != board.King {
+				score += PieceValuesEndGame[piece.Type]
+			}
+		}
+	}
+
+	// Bishop and knight mates have their own evaluator; two bishops need both colors
+	bishops := pieceSquares(b, strong, board.Bishop)
+	twoBishops := len(bishops) >= 2 && squareColor(bishops[0]) != squareColor(bishops[len(bishops)-1])
+	if len(pieceSquares(b, strong, board.Queen)) > 0 || len(pieceSquares(b, strong, board.Rook)) > 0 || twoBishops ||
+		(len(bishops) > 0 && len(pieceSquares(b, strong, board.Knight)) > 0) {
+		score += EndgameWinScore
+	}
+	return score
+}
+
+// squareColor is 0 for light squares and 1 for dark ones
+func squareColor(sq [2]int) int {
+	return (sq[0] + sq[1]) % 2
+}
+
+// evaluateKBNK drives the lone king to a corner the bishop can cover
+func evaluateKBNK(g *game.GameState, strong int) int {
+	b := g.Board
+	strongKing, weakKing := kingSquare(b, strong), kingSquare(b, 1-strong)
+	bishop := pieceSquares(b, strong, board.Bishop)[0]
+
+	// a1 and h8 are dark, a8 and h1 light
+	corners := [2][2]int{{0, 0}, {7, 7}}
+	if squareColor(bishop) == 1 {
+		corners = [2][2]int{{7, 0}, {0, 7}}
+	}
+	cornerDistance := min(squareDistance(weakKing, corners[0]), squareDistance(weakKing, corners[1]))
+
+	return EndgameWinScore + PieceValuesEndGame[board.Bishop] + PieceValuesEndGame[board.Knight] +
+		pushClose(strongKing, weakKing) + 40*(7-cornerDistance)
+}
+
+// evaluateKPK looks the position up in the KPK bitbase. Wins score higher
+// the further the pawn has run
+func evaluateKPK(g *game.GameState, strong int) int {
+	if win, _ := ProbeKPK(g); !win {
+		return 0
+	}
+	pawn := pieceSquares(g.Board, strong, board.Pawn)[0]
+	return EndgameWinScore + PieceValuesEndGame[board.Pawn] + 10*relativeRank(strong, pawn[0])
+}
+
+// evaluateKRKP is a win when our king is in front of the pawn or theirs is too
+// far from it, and drawish when the pawn is advanced and escorted by its king
+func evaluateKRKP(g *game.GameState, strong int) int {
+	b := g.Board
+	weak := 1 - strong
+	strongKing, weakKing := kingSquare(b, strong), kingSquare(b, weak)
+	rook := pieceSquares(b, strong, board.Rook)[0]
+	pawn := pieceSquares(b, weak, board.Pawn)[0]
+
+	pawnForward := 1
+	if weak == board.White {
+		pawnForward = -1
+	}
+	promotion := [2]int{7, pawn[1]}
+	if weak == board.White {
+		promotion[0] = 0
+	}
+	stop := [2]int{pawn[0] + pawnForward, pawn[1]}
+	rookValue := PieceValuesEndGame[board.Rook]
+
+	kingInFront := strongKing[1] == pawn[1] && (strongKing[0]-pawn[0])*pawnForward > 0
+	tempo := 0
+	if g.CurrentPlayer == strong {
+		tempo = 1
+	}
+
+	switch {
+	case kingInFront:
+		return rookValue - squareDistance(strongKing, pawn)
+
+	case squareDistance(weakKing, pawn) >= 3+tempo && squareDistance(weakKing, rook) >= 3:
+		return rookValue - squareDistance(strongKing, pawn)
+
+	case relativeRank(weak, pawn[0]) >= 5 && squareDistance(weakKing, pawn) == 1 &&
+		relativeRank(weak, strongKing[0]) <= 4 && squareDistance(strongKing, pawn) > 2+tempo:
+		return 80 - 8*squareDistance(strongKing, pawn)
+
+	default:
+		return 200 - 8*(squareDistance(strongKing, stop)-squareDistance(weakKing, stop)-squareDistance(pawn, promotion))
+	}
+}
+
+// evaluateKRKB is usually a draw, but the lone king on the edge gives chances
+func evaluateKRKB(g *game.GameState, strong int) int {
+	return pushToEdge(kingSquare(g.Board, 1-strong))
+}
+
+// evaluateKRKN pushes the king to the edge and keeps the knight away from it
+func evaluateKRKN(g *game.GameState, strong int) int {
+	b := g.Board
+	weakKing := kingSquare(b, 1-strong)
+	knight := pieceSquares(b, 1-strong, board.Knight)[0]
+	return pushToEdge(weakKing) + 20*squareDistance(weakKing, knight)
+}
+
+// evaluateKQKR is a win: drive the king to the edge and bring our king up
+func evaluateKQKR(g *game.GameState, strong int) int {
+	b := g.Board
+	strongKing, weakKing := kingSquare(b, strong), kingSquare(b, 1-strong)
+	return EndgameWinScore + PieceValuesEndGame[board.Queen] - PieceValuesEndGame[board.Rook] +
+		pushToEdge(weakKing) + pushClose(strongKing, weakKing)
+}
+
+// scaleKRPKR is drawish when the defending king stands in front of the pawn
+func scaleKRPKR(g *game.GameState, strong int) int {
+	b := g.Board
+	weakKing := kingSquare(b, 1-strong)
+	pawn := pieceSquares(b, strong, board.Pawn)[0]
+	ahead := relativeRank(strong, weakKing[0]) > relativeRank(strong, pawn[0])
+	if ahead && abs(weakKing[1]-pawn[1]) <= 1 {
+		return 10
+	}
+	return ScaleNormal
+}
+
+// scaleOppositeBishops scales down endings where each side has one bishop and
+// they run on different colors. With nothing else on the board they are very
+// drawish unless one side is well ahead in pawns
+func scaleOppositeBishops(g *game.GameState, strong int) int {
+	b := g.Board
+	whiteBishops, blackBishops := pieceSquares(b, board.White, board.Bishop), pieceSquares(b, board.Black, board.Bishop)
+	if len(whiteBishops) != 1 || len(blackBishops) != 1 || squareColor(whiteBishops[0]) == squareColor(blackBishops[0]) {
+		return ScaleNormal
+	}
+
+	if pieceCount(b, board.White) == 1 && pieceCount(b, board.Black) == 1 {
+		pawnDifference := len(pieceSquares(b, strong, board.Pawn)) - len(pieceSquares(b, 1-strong, board.Pawn))
+		if pawnDifference <= 1 {
+			return 12
+		}
+		return 24
+	}
+	return 46
+}
+
+// scaleRookPawns spots pawns on a single rook file that can't win: the lone
+// king sits in the queening corner, and any bishop can't cover that corner
+func scaleRookPawns(g *game.GameState, strong int) int {
+	b := g.Board
+	weak := 1 - strong
+	if pieceCount(b, weak) > 0 || len(pieceSquares(b, weak, board.Pawn)) > 0 {
+		return ScaleNormal
+	}
+	pawns := pieceSquares(b, strong, board.Pawn)
+	if len(pawns) == 0 {
+		return ScaleNormal
+	}
+	file := pawns[0][1]
+	if file != 0 && file != 7 {
+		return ScaleNormal
+	}
+	for _, pawn := range pawns {
+		if pawn[1] != file {
+			return ScaleNormal
+		}
+	}
+
+	promotion := [2]int{0, file}
+	if strong == board.Black {
+		promotion[0] = 7
+	}
+	bishops := pieceSquares(b, strong, board.Bishop)
+	switch {
+	case pieceCount(b, strong) == 0:
+	case pieceCount(b, strong) == 1 && len(bishops) == 1 && squareColor(bishops[0]) != squareColor(promotion):
+	default:
+		return ScaleNormal
+	}
+
+	if squareDistance(kingSquare(b, weak), promotion) <= 1 {
+		return ScaleDraw
+	}
+	return ScaleNormal
+}
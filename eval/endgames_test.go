@@ -0,0 +1,42 @@
+package eval
+
+import (
+	"testing"
+
+	"chess-engine/game"
+)
+
+// Tuned values may make pieces worth the same, so endings must be told apart
+// by the pieces on the board and not by their value
+func TestEndgameScalesWithEqualMinorValues(t *testing.T) {
+	saved := CurrentEvalParams()
+	defer saved.Apply()
+	params := CurrentEvalParams()
+	params.PieceValues["knight"] = params.PieceValues["bishop"]
+	if err := params.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		fen     string
+		scaleBy string
+		scale   int
+	}{
+		{"rook pawn and knight", "8/8/8/4k3/8/P7/8/N3K3 w - - 0 1", "", ScaleNormal},
+		{"rook pawn and the wrong bishop", "8/1k6/8/8/8/P7/8/2B1K3 w - - 0 1", "rook pawns", ScaleDraw},
+		{"rook pawn and the right bishop", "8/1k6/8/8/8/P7/8/1B2K3 w - - 0 1", "", ScaleNormal},
+		{"opposite bishops", "8/4k3/2b5/3p4/3P4/8/4KB2/8 w - - 0 1", "opposite bishops", 12},
+		{"opposite bishops and a knight", "8/4k3/2b5/3p4/3P4/8/4KB2/N7 w - - 0 1", "opposite bishops", 46},
+	}
+	for _, test := range tests {
+		g, err := game.NewGameFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trace := EvaluateTrace(g)
+		if trace.ScaleBy != test.scaleBy || trace.Scale != test.scale {
+			t.Errorf("%s: scaled to %d by %q, want %d by %q", test.name, trace.Scale, trace.ScaleBy, test.scale, test.scaleBy)
+		}
+	}
+}
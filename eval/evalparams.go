@@ -0,0 +1,386 @@
+package eval
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"chess-engine/board"
+)
+
+// defaultEvalParams is the built-in parameter file
+//
+//go:embed evalparams.json
+var defaultEvalParams []byte
+
+// EvalParams holds every evaluation weight in a form that can be read from and
+// written to JSON. Tables are slices so their shape can be checked on load
+type EvalParams struct {
+	PieceValues   map[string]EvalScore `json:"pieceValues"`
+	PieceSquareMG map[string][][]int   `json:"pieceSquareMG"`
+	PieceSquareEG map[string][][]int   `json:"pieceSquareEG"`
+	Castling      EvalScore            `json:"castling"`
+
+	DoubledPawn            EvalScore   `json:"doubledPawn"`
+	IsolatedPawn           EvalScore   `json:"isolatedPawn"`
+	BackwardPawn           EvalScore   `json:"backwardPawn"`
+	ConnectedPawn          EvalScore   `json:"connectedPawn"`
+	PassedPawn             []EvalScore `json:"passedPawn"`
+	PassedPawnFreePath     EvalScore   `json:"passedPawnFreePath"`
+	PassedPawnKingDistance EvalScore   `json:"passedPawnKingDistance"`
+
+	KnightMobility   []EvalScore `json:"knightMobility"`
+	BishopMobility   []EvalScore `json:"bishopMobility"`
+	RookMobility     []EvalScore `json:"rookMobility"`
+	QueenMobility    []EvalScore `json:"queenMobility"`
+	BishopPair       EvalScore   `json:"bishopPair"`
+	RookOpenFile     EvalScore   `json:"rookOpenFile"`
+	RookSemiOpenFile EvalScore   `json:"rookSemiOpenFile"`
+	RookOnSeventh    EvalScore   `json:"rookOnSeventh"`
+	KnightOutpost    EvalScore   `json:"knightOutpost"`
+	BishopOutpost    EvalScore   `json:"bishopOutpost"`
+	TrappedRook      EvalScore   `json:"trappedRook"`
+	TrappedBishop    EvalScore   `json:"trappedBishop"`
+
+	KingAttackerWeight       map[string]int `json:"kingAttackerWeight"`
+	KingSafeCheckWeight      map[string]int `json:"kingSafeCheckWeight"`
+	KingZoneAttack           int            `json:"kingZoneAttack"`
+	KingShieldMissing        int            `json:"kingShieldMissing"`
+	KingShieldAdvanced       int            `json:"kingShieldAdvanced"`
+	KingOpenFile             int            `json:"kingOpenFile"`
+	KingSemiOpenFile         int            `json:"kingSemiOpenFile"`
+	KingPawnStorm            []int          `json:"kingPawnStorm"`
+	KingDangerDivisor        int            `json:"kingDangerDivisor"`
+	KingDangerEndGameDivisor int            `json:"kingDangerEndGameDivisor"`
+}
+
+// pieceNames are the keys used for piece types in parameter files
+var pieceNames = map[int]string{
+	board.Pawn: "pawn", board.Knight: "knight", board.Bishop: "bishop", board.Rook: "rook", board.Queen: "queen", board.King: "king",
+}
+
+// Pieces that attack the king, and so have king safety weights
+var kingAttackerTypes = []int{board.Knight, board.Bishop, board.Rook, board.Queen}
+
+// EvalParamsFile is the file the current parameters were loaded from, empty for the built-in ones
+var EvalParamsFile string
+
+func init() {
+	params, err := DefaultEvalParams()
+	if err == nil {
+		err = params.Apply()
+	}
+	if err != nil {
+		panic("built-in evaluation parameters: " + err.Error())
+	}
+}
+
+// MarshalJSON writes a score as [mg, eg]
+func (s EvalScore) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]int{s.MG, s.EG})
+}
+
+// UnmarshalJSON reads a score written as [mg, eg]
+func (s *EvalScore) UnmarshalJSON(data []byte) error {
+	var pair []int
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return err
+	}
+	if len(pair) != 2 {
+		return fmt.Errorf("score %s should be [middlegame, endgame]", data)
+	}
+	s.MG, s.EG = pair[0], pair[1]
+	return nil
+}
+
+// DefaultEvalParams returns the built-in evaluation parameters
+func DefaultEvalParams() (*EvalParams, error) {
+	return ReadEvalParams(bytes.NewReader(defaultEvalParams), &EvalParams{})
+}
+
+// LoadEvalParams reads a parameter file. Anything the file leaves out keeps its built-in value
+func LoadEvalParams(path string) (*EvalParams, error) {
+	defaults, err := DefaultEvalParams()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	params, err := ReadEvalParams(f, defaults)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return params, nil
+}
+
+// UseEvalParams loads and applies a parameter file, or the built-in parameters
+// for an empty path. Calling it again with the same path reloads the file
+func UseEvalParams(path string) error {
+	var params *EvalParams
+	var err error
+	if path == "" {
+		params, err = DefaultEvalParams()
+	} else {
+		params, err = LoadEvalParams(path)
+	}
+	if err != nil {
+		return err
+	}
+	if err := params.Apply(); err != nil {
+		return err
+	}
+	EvalParamsFile = path
+	return nil
+}
+
+// ReadEvalParams decodes JSON parameters over base and checks the result
+func ReadEvalParams(r io.Reader, base *EvalParams) (*EvalParams, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(base); err != nil {
+		return nil, err
+	}
+	if err := base.Validate(); err != nil {
+		return nil, err
+	}
+	return base, nil
+}
+
+// Validate checks every table has the right shape and divisors aren't zero
+func (p *EvalParams) Validate() error {
+	for pieceType := board.Pawn; pieceType <= board.King; pieceType++ {
+		name := pieceNames[pieceType]
+		if _, ok := p.PieceValues[name]; !ok && pieceType != board.King {
+			return fmt.Errorf("pieceValues: missing %s", name)
+		}
+		for _, tables := range []struct {
+			field string
+			table map[string][][]int
+		}{{"pieceSquareMG", p.PieceSquareMG}, {"pieceSquareEG", p.PieceSquareEG}} {
+			if err := checkTable(tables.table[name], tables.field+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range kingAttackerTypes {
+		if _, ok := p.KingAttackerWeight[pieceNames[name]]; !ok {
+			return fmt.Errorf("kingAttackerWeight: missing %s", pieceNames[name])
+		}
+		if _, ok := p.KingSafeCheckWeight[pieceNames[name]]; !ok {
+			return fmt.Errorf("kingSafeCheckWeight: missing %s", pieceNames[name])
+		}
+	}
+
+	lengths := []struct {
+		field string
+		got   int
+		want  int
+	}{
+		{"passedPawn", len(p.PassedPawn), len(PassedPawnBonus)},
+		{"knightMobility", len(p.KnightMobility), len(KnightMobility)},
+		{"bishopMobility", len(p.BishopMobility), len(BishopMobility)},
+		{"rookMobility", len(p.RookMobility), len(RookMobility)},
+		{"queenMobility", len(p.QueenMobility), len(QueenMobility)},
+		{"kingPawnStorm", len(p.KingPawnStorm), len(KingPawnStorm)},
+	}
+	for _, l := range lengths {
+		if l.got != l.want {
+			return fmt.Errorf("%s: expected %d entries, got %d", l.field, l.want, l.got)
+		}
+	}
+
+	if p.KingDangerDivisor <= 0 || p.KingDangerEndGameDivisor <= 0 {
+		return fmt.Errorf("king danger divisors must be positive")
+	}
+	return nil
+}
+
+// checkTable checks a piece square table is 8 rows of 8
+func checkTable(table [][]int, field string) error {
+	if len(table) != 8 {
+		return fmt.Errorf("%s: expected 8 rows, got %d", field, len(table))
+	}
+	for i, row := range table {
+		if len(row) != 8 {
+			return fmt.Errorf("%s: row %d has %d entries, expected 8", field, i+1, len(row))
+		}
+	}
+	return nil
+}
+
+// Apply validates the parameters and makes them the ones evaluation uses.
+// It must not be called while a search is running
+func (p *EvalParams) Apply() error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	for pieceType := board.Pawn; pieceType <= board.Queen; pieceType++ {
+		value := p.PieceValues[pieceNames[pieceType]]
+		PieceValues[pieceType] = value.MG
+		PieceValuesEndGame[pieceType] = value.EG
+	}
+	for pieceType, table := range pieceSquareTables() {
+		copyTable(table[0], p.PieceSquareMG[pieceNames[pieceType]])
+		copyTable(table[1], p.PieceSquareEG[pieceNames[pieceType]])
+	}
+	CastlingBonus = p.Castling
+
+	DoubledPawnPenalty = p.DoubledPawn
+	IsolatedPawnPenalty = p.IsolatedPawn
+	BackwardPawnPenalty = p.BackwardPawn
+	ConnectedPawnBonus = p.ConnectedPawn
+	copy(PassedPawnBonus[:], p.PassedPawn)
+	PassedPawnFreePath = p.PassedPawnFreePath
+	PassedPawnKingDistance = p.PassedPawnKingDistance
+
+	copy(KnightMobility[:], p.KnightMobility)
+	copy(BishopMobility[:], p.BishopMobility)
+	copy(RookMobility[:], p.RookMobility)
+	copy(QueenMobility[:], p.QueenMobility)
+	BishopPairBonus = p.BishopPair
+	RookOpenFileBonus = p.RookOpenFile
+	RookSemiOpenFileBonus = p.RookSemiOpenFile
+	RookOnSeventhBonus = p.RookOnSeventh
+	KnightOutpostBonus = p.KnightOutpost
+	BishopOutpostBonus = p.BishopOutpost
+	TrappedRookPenalty = p.TrappedRook
+	TrappedBishopPenalty = p.TrappedBishop
+
+	for _, pieceType := range kingAttackerTypes {
+		KingAttackerWeight[pieceType] = p.KingAttackerWeight[pieceNames[pieceType]]
+		KingSafeCheckWeight[pieceType] = p.KingSafeCheckWeight[pieceNames[pieceType]]
+	}
+	KingZoneAttackWeight = p.KingZoneAttack
+	KingShieldMissing = p.KingShieldMissing
+	KingShieldAdvanced = p.KingShieldAdvanced
+	KingOpenFile = p.KingOpenFile
+	KingSemiOpenFile = p.KingSemiOpenFile
+	copy(KingPawnStorm[:], p.KingPawnStorm)
+	KingDangerDivisor = p.KingDangerDivisor
+	KingDangerEndGameDivisor = p.KingDangerEndGameDivisor
+
+	// Cached pawn scores were worked out with the old weights
+	clearPawnHash()
+	return nil
+}
+
+// CurrentEvalParams returns the weights evaluation is using now
+func CurrentEvalParams() *EvalParams {
+	p := &EvalParams{
+		PieceValues:   make(map[string]EvalScore),
+		PieceSquareMG: make(map[string][][]int),
+		PieceSquareEG: make(map[string][][]int),
+		Castling:      CastlingBonus,
+
+		DoubledPawn:            DoubledPawnPenalty,
+		IsolatedPawn:           IsolatedPawnPenalty,
+		BackwardPawn:           BackwardPawnPenalty,
+		ConnectedPawn:          ConnectedPawnBonus,
+		PassedPawn:             append([]EvalScore(nil), PassedPawnBonus[:]...),
+		PassedPawnFreePath:     PassedPawnFreePath,
+		PassedPawnKingDistance: PassedPawnKingDistance,
+
+		KnightMobility:   append([]EvalScore(nil), KnightMobility[:]...),
+		BishopMobility:   append([]EvalScore(nil), BishopMobility[:]...),
+		RookMobility:     append([]EvalScore(nil), RookMobility[:]...),
+		QueenMobility:    append([]EvalScore(nil), QueenMobility[:]...),
+		BishopPair:       BishopPairBonus,
+		RookOpenFile:     RookOpenFileBonus,
+		RookSemiOpenFile: RookSemiOpenFileBonus,
+		RookOnSeventh:    RookOnSeventhBonus,
+		KnightOutpost:    KnightOutpostBonus,
+		BishopOutpost:    BishopOutpostBonus,
+		TrappedRook:      TrappedRookPenalty,
+		TrappedBishop:    TrappedBishopPenalty,
+
+		KingAttackerWeight:       make(map[string]int),
+		KingSafeCheckWeight:      make(map[string]int),
+		KingZoneAttack:           KingZoneAttackWeight,
+		KingShieldMissing:        KingShieldMissing,
+		KingShieldAdvanced:       KingShieldAdvanced,
+		KingOpenFile:             KingOpenFile,
+		KingSemiOpenFile:         KingSemiOpenFile,
+		KingPawnStorm:            append([]int(nil), KingPawnStorm[:]...),
+		KingDangerDivisor:        KingDangerDivisor,
+		KingDangerEndGameDivisor: KingDangerEndGameDivisor,
+	}
+
+	for pieceType := board.Pawn; pieceType <= board.Queen; pieceType++ {
+		p.PieceValues[pieceNames[pieceType]] = EvalScore{PieceValues[pieceType], PieceValuesEndGame[pieceType]}
+	}
+	for pieceType, table := range pieceSquareTables() {
+		p.PieceSquareMG[pieceNames[pieceType]] = tableRows(table[0])
+		p.PieceSquareEG[pieceNames[pieceType]] = tableRows(table[1])
+	}
+	for _, pieceType := range kingAttackerTypes {
+		p.KingAttackerWeight[pieceNames[pieceType]] = KingAttackerWeight[pieceType]
+		p.KingSafeCheckWeight[pieceNames[pieceType]] = KingSafeCheckWeight[pieceType]
+	}
+	return p
+}
+
+// pieceSquareTables maps each piece type to its middlegame and endgame tables
+func pieceSquareTables() map[int][2]*[8][8]int {
+	return map[int][2]*[8][8]int{
+		board.Pawn:   {&PawnTable, &PawnEndGameTable},
+		board.Knight: {&KnightTable, &KnightEndGameTable},
+		board.Bishop: {&BishopTable, &BishopEndGameTable},
+		board.Rook:   {&RookTable, &RookEndGameTable},
+		board.Queen:  {&QueenTable, &QueenEndGameTable},
+		board.King:   {&KingMiddleGameTable, &KingEndGameTable},
+	}
+}
+
+func copyTable(dst *[8][8]int, src [][]int) {
+	for row := range dst {
+		copy(dst[row][:], src[row])
+	}
+}
+
+func tableRows(table *[8][8]int) [][]int {
+	rows := make([][]int, 8)
+	for row := range rows {
+		rows[row] = append([]int(nil), table[row][:]...)
+	}
+	return rows
+}
+
+// Matches an array of plain numbers, so it can be written on one line
+var numberArray = regexp.MustCompile(`\[[-0-9,\s]*\]`)
+
+// Write writes the parameters as indented JSON with each table row on one line
+func (p *EvalParams) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = numberArray.ReplaceAllFunc(data, func(array []byte) []byte {
+		return bytes.ReplaceAll(bytes.Join(bytes.Fields(array), nil), []byte(","), []byte(", "))
+	})
+	data = append(data, '\n')
+	_, err = w.Write(data)
+	return err
+}
+
+// SaveEvalParams writes parameters to a file
+func SaveEvalParams(path string, p *EvalParams) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = p.Write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"chess-engine/game"
+)
+
+func TestValidate(t *testing.T) {
+	saved := CurrentEvalParams()
+	defer saved.Apply()
+
+	tests := []struct {
+		name   string
+		change func(p *EvalParams)
+		err    string
+	}{
+		{"built-in", func(p *EvalParams) {}, ""},
+		{"short table", func(p *EvalParams) { p.PieceSquareMG["knight"] = p.PieceSquareMG["knight"][:7] }, "pieceSquareMG.knight: expected 8 rows, got 7"},
+		{"short row", func(p *EvalParams) { p.PieceSquareEG["pawn"][3] = []int{1, 2, 3} }, "pieceSquareEG.pawn: row 4 has 3 entries"},
+		{"missing table", func(p *EvalParams) { delete(p.PieceSquareMG, "king") }, "pieceSquareMG.king: expected 8 rows, got 0"},
+		{"missing value", func(p *EvalParams) { delete(p.PieceValues, "rook") }, "pieceValues: missing rook"},
+		{"long mobility", func(p *EvalParams) { p.RookMobility = append(p.RookMobility, EvalScore{}) }, "rookMobility: expected"},
+		{"missing attacker", func(p *EvalParams) { delete(p.KingAttackerWeight, "queen") }, "kingAttackerWeight: missing queen"},
+		{"zero divisor", func(p *EvalParams) { p.KingDangerDivisor = 0 }, "divisors must be positive"},
+		{"negative endgame divisor", func(p *EvalParams) { p.KingDangerEndGameDivisor = -1 }, "divisors must be positive"},
+	}
+	for _, test := range tests {
+		params, err := DefaultEvalParams()
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.change(params)
+		err = params.Validate()
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+		}
+		// Parameters that don't validate are never put in use
+		if err := params.Apply(); err == nil {
+			t.Errorf("%s: applied", test.name)
+		}
+	}
+}
+
+func TestLoadEvalParams(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// A file that sets a few weights keeps the built-in values for the rest
+	params, err := LoadEvalParams(write("partial.json", `{"bishopPair": [40, 60], "pieceValues": {"knight": [310, 290]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults, _ := DefaultEvalParams()
+	if params.BishopPair != (EvalScore{40, 60}) || params.PieceValues["knight"] != (EvalScore{310, 290}) {
+		t.Errorf("loaded bishop pair %v, knight %v", params.BishopPair, params.PieceValues["knight"])
+	}
+	if params.PieceValues["queen"] != defaults.PieceValues["queen"] || params.RookOpenFile != defaults.RookOpenFile {
+		t.Errorf("weights the file leaves out changed: queen %v, rook open file %v", params.PieceValues["queen"], params.RookOpenFile)
+	}
+
+	bad := []struct {
+		name, contents, err string
+	}{
+		{"unknown.json", `{"bishopPair": [40, 60], "bishopPiar": [40, 60]}`, `unknown field "bishopPiar"`},
+		{"score.json", `{"castling": [1, 2, 3]}`, "should be [middlegame, endgame]"},
+		{"divisor.json", `{"kingDangerDivisor": 0}`, "divisors must be positive"},
+		{"table.json", `{"pieceSquareEG": {"queen": [[0, 0, 0, 0, 0, 0, 0, 0]]}}`, "pieceSquareEG.queen: expected 8 rows, got 1"},
+		{"truncated.json", `{"bishopPair": [40,`, "unexpected EOF"},
+	}
+	for _, test := range bad {
+		path := write(test.name, test.contents)
+		_, err := LoadEvalParams(path)
+		if err == nil || !strings.Contains(err.Error(), test.err) || !strings.HasPrefix(err.Error(), path) {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+		}
+	}
+	if _, err := LoadEvalParams(filepath.Join(dir, "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("missing file: got error %v", err)
+	}
+}
+
+// TestApplyClearsPawnHash checks that new pawn weights take effect for a
+// pawn structure that was evaluated, and so cached, under the old ones
+func TestApplyClearsPawnHash(t *testing.T) {
+	saved := CurrentEvalParams()
+	defer saved.Apply()
+
+	g, err := game.NewGameFromFEN("4k3/8/8/8/3P4/3P4/3P4/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := Evaluate(g)
+	if entry := pawnHash[pawnKey(g.Board)&(pawnHashSize-1)].Load(); entry == nil || entry.key != pawnKey(g.Board) {
+		t.Fatal("the pawn structure wasn't cached")
+	}
+
+	params := CurrentEvalParams()
+	params.DoubledPawn = params.DoubledPawn.Add(EvalScore{50, 50})
+	if err := params.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	after := Evaluate(g)
+	if after >= before {
+		t.Errorf("score %d with a larger doubled pawn penalty, %d before", after, before)
+	}
+	clearPawnHash()
+	if fresh := Evaluate(g); fresh != after {
+		t.Errorf("score %d after the reload, %d from an empty pawn hash", after, fresh)
+	}
+}
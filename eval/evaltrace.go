@@ -0,0 +1,173 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+// Evaluation terms reported in an EvalTrace
+const (
+	TermMaterial = iota
+	TermPieceSquare
+	TermPawns
+	TermMobility
+	TermPieces
+	TermKingSafety
+	TermCastling
+	numEvalTerms
+)
+
+// EvalTermNames are the display names of the evaluation terms
+var EvalTermNames = [numEvalTerms]string{
+	TermMaterial:    "Material",
+	TermPieceSquare: "Piece-square",
+	TermPawns:       "Pawns",
+	TermMobility:    "Mobility",
+	TermPieces:      "Pieces",
+	TermKingSafety:  "King safety",
+	TermCastling:    "Castling",
+}
+
+// EvalTrace is a breakdown of an evaluation. Each side's terms are from that
+// side's point of view, so a positive value is always good for the side
+type EvalTrace struct {
+	Terms    [numEvalTerms][2]EvalScore // [term][color]
+	Phase    float64                    // 1.0 for the opening, 0.0 for the endgame
+	Total    int                        // Final score, positive for white
+	Terminal bool                       // Checkmate or stalemate, no terms were evaluated
+	Endgame  string                     // Known ending scored by its own evaluator, no terms were evaluated
+	Scale    int                        // Scale factor applied to the endgame half, ScaleNormal for none
+	ScaleBy  string                     // Ending that set the scale factor
+}
+
+// Net returns a term as white's score minus black's
+func (t *EvalTrace) Net(term int) EvalScore {
+	return t.Terms[term][board.White].Sub(t.Terms[term][board.Black])
+}
+
+// Sum returns all terms added together, white minus black, before tapering
+func (t *EvalTrace) Sum() EvalScore {
+	var sum EvalScore
+	for term := 0; term < numEvalTerms; term++ {
+		sum = sum.Add(t.Net(term))
+	}
+	return sum
+}
+
+// EvaluateTrace evaluates the position, recording every term for both sides
+func EvaluateTrace(g *game.GameState) EvalTrace {
+	if score, over := terminalScore(g); over {
+		return EvalTrace{Total: score, Terminal: true}
+	}
+	return evaluateTerms(g)
+}
+
+// terminalScore checks for checkmate and stalemate, returning the score if the game is over
+func terminalScore(g *game.GameState) (int, bool) {
+	moves := g.GenerateAllLegalMoves()
+	if len(moves) > 0 {
+		return 0, false
+	}
+	if !g.Board.IsInCheck(g.CurrentPlayer) {
+		return 0, true
+	}
+	if g.CurrentPlayer == board.White {
+		return -30000, true
+	}
+	return 30000, true
+}
+
+// evaluateTerms works out every term of the evaluation without first checking
+// whether the game is over
+func evaluateTerms(g *game.GameState) EvalTrace {
+	var trace EvalTrace
+	trace.Phase = GetGamePhase(g)
+	trace.Scale = ScaleNormal
+
+	// Known endings have their own evaluators
+	if score, name, ok := probeEndgame(g); ok {
+		trace.Endgame = name
+		trace.Total = score
+		return trace
+	}
+
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			piece := g.Board.GetPiece(row, col)
+			if piece.Type == board.Empty {
+				continue
+			}
+			if piece.Type != board.King {
+				material := EvalScore{PieceValues[piece.Type], PieceValuesEndGame[piece.Type]}
+				trace.Terms[TermMaterial][piece.Color] = trace.Terms[TermMaterial][piece.Color].Add(material)
+			}
+			trace.Terms[TermPieceSquare][piece.Color] = trace.Terms[TermPieceSquare][piece.Color].Add(pieceSquareScore(piece, row, col))
+		}
+	}
+
+	for color := board.White; color <= board.Black; color++ {
+		// Pawn structure, cached by pawn skeleton
+		trace.Terms[TermPawns][color] = evaluatePawns(g, color)
+
+		// Mobility and placement of the pieces
+		trace.Terms[TermMobility][color], trace.Terms[TermPieces][color] = evaluatePieces(g, color)
+
+		// King safety, mostly a middlegame term
+		trace.Terms[TermKingSafety][color] = evaluateKingSafety(g, color)
+	}
+
+	// Castling bonus
+	if g.WhiteCanCastleK || g.WhiteCanCastleQ {
+		trace.Terms[TermCastling][board.White] = CastlingBonus
+	}
+	if g.BlackCanCastleK || g.BlackCanCastleQ {
+		trace.Terms[TermCastling][board.Black] = CastlingBonus
+	}
+
+	// Drawish endings scale down the endgame half
+	sum := trace.Sum()
+	trace.Scale, trace.ScaleBy = endgameScale(g, sum.EG)
+	sum.EG = sum.EG * trace.Scale / ScaleNormal
+	trace.Total = sum.Taper(trace.Phase)
+	return trace
+}
+
+// WriteTable prints the trace as a table of middlegame and endgame values per side
+func (t *EvalTrace) WriteTable(w io.Writer) {
+	if t.Terminal {
+		switch {
+		case t.Total > 0:
+			fmt.Fprintln(w, "Checkmate, white wins")
+		case t.Total < 0:
+			fmt.Fprintln(w, "Checkmate, black wins")
+		default:
+			fmt.Fprintln(w, "Stalemate")
+		}
+		return
+	}
+	if t.Endgame != "" {
+		fmt.Fprintf(w, "Known ending %s, scored by its own evaluator\n", t.Endgame)
+		fmt.Fprintf(w, "Score: %+d centipawns\n", t.Total)
+		return
+	}
+
+	fmt.Fprintf(w, "%-13s | %6s %6s | %6s %6s | %6s %6s\n", "Term", "White", "", "Black", "", "Net", "")
+	fmt.Fprintf(w, "%-13s | %6s %6s | %6s %6s | %6s %6s\n", "", "MG", "EG", "MG", "EG", "MG", "EG")
+	fmt.Fprintln(w, "--------------+---------------+---------------+--------------")
+	for term := 0; term < numEvalTerms; term++ {
+		white, black, net := t.Terms[term][board.White], t.Terms[term][board.Black], t.Net(term)
+		fmt.Fprintf(w, "%-13s | %6d %6d | %6d %6d | %+6d %+6d\n",
+			EvalTermNames[term], white.MG, white.EG, black.MG, black.EG, net.MG, net.EG)
+	}
+	fmt.Fprintln(w, "--------------+---------------+---------------+--------------")
+	sum := t.Sum()
+	fmt.Fprintf(w, "%-13s | %13s | %13s | %+6d %+6d\n", "Total", "", "", sum.MG, sum.EG)
+	if t.Scale != ScaleNormal {
+		fmt.Fprintf(w, "Endgame half scaled by %d/%d (%s)\n", t.Scale, ScaleNormal, t.ScaleBy)
+	}
+	fmt.Fprintf(w, "Game phase: %.2f (1.0=opening, 0.0=endgame)\n", t.Phase)
+	fmt.Fprintf(w, "Score: %+d centipawns\n", t.Total)
+}
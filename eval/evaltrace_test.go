@@ -0,0 +1,69 @@
+package eval
+
+import (
+	"testing"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+func traceFEN(t *testing.T, fen string) EvalTrace {
+	t.Helper()
+	g, err := game.NewGameFromFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := EvaluateTrace(g)
+	if trace.Terminal || trace.Endgame != "" {
+		t.Fatalf("%s: no terms were evaluated", fen)
+	}
+	return trace
+}
+
+func TestTraceTerms(t *testing.T) {
+	tests := []struct {
+		name        string
+		fen         string
+		term, color int
+		want        EvalScore
+	}{
+		// Bishops and knights on the back rank have no outpost or trap terms
+		{"bishop pair", "4k3/pppppppp/8/8/8/8/PPPPPPPP/2B1KB2 w - - 0 1", TermPieces, board.White, BishopPairBonus},
+		{"bishop and knight", "4k3/pppppppp/8/8/8/8/PPPPPPPP/2B1KN2 w - - 0 1", TermPieces, board.White, EvalScore{}},
+		{"rook on an open file", "r6k/ppp2ppp/8/8/8/8/PPP2PPP/4RK2 w - - 0 1", TermPieces, board.White, RookOpenFileBonus},
+		{"rook on a semi-open file", "r6k/ppp1pppp/8/8/8/8/PPP2PPP/4RK2 w - - 0 1", TermPieces, board.White, RookSemiOpenFileBonus},
+		{"rook behind its own pawn", "r6k/ppp2ppp/8/8/8/8/PPP2PPP/4RK2 w - - 0 1", TermPieces, board.Black, EvalScore{}},
+		// An isolated passer on e6 with a clear path, its king six squares from
+		// e7 and the enemy king three: advanced four ranks, three squares behind
+		{"passed pawn on the 6th", "r6k/8/4P3/8/8/8/8/R5K1 w - - 0 1", TermPawns, board.White,
+			PassedPawnBonus[5].Sub(IsolatedPawnPenalty).Add(PassedPawnFreePath.Scale(4)).Add(PassedPawnKingDistance.Scale(-3 * 4))},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trace := traceFEN(t, test.fen)
+			if got := trace.Terms[test.term][test.color]; got != test.want {
+				t.Errorf("%s for %s: %+v, want %+v", EvalTermNames[test.term], board.ColorName(test.color), got, test.want)
+			}
+		})
+	}
+}
+
+// TestTraceTotal checks that the terms add up to the evaluation
+func TestTraceTotal(t *testing.T) {
+	fens := []string{
+		game.StartingFEN,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"r1bq1rk1/pp2bppp/2n1pn2/3p4/2PP4/2N2N2/PP2BPPP/R2QKB1R w KQ - 0 8",
+		"r6k/8/4P3/8/8/8/8/R5K1 w - - 0 1",
+	}
+	for _, fen := range fens {
+		trace := traceFEN(t, fen)
+		if trace.Scale != ScaleNormal {
+			t.Fatalf("%s: scaled by %s", fen, trace.ScaleBy)
+		}
+		g, _ := game.NewGameFromFEN(fen)
+		if got, want := trace.Sum().Taper(trace.Phase), Evaluate(g); got != want {
+			t.Errorf("%s: terms taper to %d, Evaluate gives %d", fen, got, want)
+		}
+	}
+}
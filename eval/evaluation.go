@@ -0,0 +1,155 @@
+// Package eval scores positions, with a hand-crafted evaluation whose weights can be
+// loaded and tuned, specialized endgame knowledge and an optional NNUE network.
+package eval
+
+import (
+	"math"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+// EvalScore holds separate middlegame and endgame values for an evaluation
+// term, blended by game phase at the end of evaluation
+type EvalScore struct {
+	MG int
+	EG int
+}
+
+// Add returns the sum of two scores
+func (s EvalScore) Add(o EvalScore) EvalScore {
+	return EvalScore{s.MG + o.MG, s.EG + o.EG}
+}
+
+// Sub returns the difference of two scores
+func (s EvalScore) Sub(o EvalScore) EvalScore {
+	return EvalScore{s.MG - o.MG, s.EG - o.EG}
+}
+
+// Scale multiplies both halves of a score
+func (s EvalScore) Scale(n int) EvalScore {
+	return EvalScore{s.MG * n, s.EG * n}
+}
+
+// Taper blends the score by phase, 1.0 being the opening and 0.0 the endgame
+func (s EvalScore) Taper(phase float64) int {
+	return int(math.Round(float64(s.MG)*phase + float64(s.EG)*(1-phase)))
+}
+
+// The evaluation weights below are loaded from evalparams.json, which is built
+// in, or from a parameter file given at run time (see evalparams.go)
+
+// PieceValues are the middlegame piece values, also used for move ordering
+var PieceValues = map[int]int{
+	board.Empty: 0,
+	board.King:  20000, // Invaluable
+}
+
+// PieceValuesEndGame are the endgame piece values
+var PieceValuesEndGame = map[int]int{
+	board.Empty: 0,
+	board.King:  20000,
+}
+
+// CastlingBonus is awarded for keeping a castling right
+var CastlingBonus EvalScore
+
+// Piece square tables, from white's side with row 0 as rank 8
+var (
+	PawnTable           [8][8]int
+	KnightTable         [8][8]int
+	BishopTable         [8][8]int
+	RookTable           [8][8]int
+	QueenTable          [8][8]int
+	KingMiddleGameTable [8][8]int
+
+	PawnEndGameTable   [8][8]int
+	KnightEndGameTable [8][8]int
+	BishopEndGameTable [8][8]int
+	RookEndGameTable   [8][8]int
+	QueenEndGameTable  [8][8]int
+	KingEndGameTable   [8][8]int
+)
+
+// Get piece square table
+func GetPieceSquareTable(piceType int, isEndgame bool) [8][8]int {
+	switch piceType {
+	case board.Pawn:
+		if isEndgame {
+			return PawnEndGameTable
+		}
+		return PawnTable
+	case board.Knight:
+		if isEndgame {
+			return KnightEndGameTable
+		}
+		return KnightTable
+	case board.Bishop:
+		if isEndgame {
+			return BishopEndGameTable
+		}
+		return BishopTable
+	case board.Rook:
+		if isEndgame {
+			return RookEndGameTable
+		}
+		return RookTable
+	case board.Queen:
+		if isEndgame {
+			return QueenEndGameTable
+		}
+		return QueenTable
+	case board.King:
+		if isEndgame {
+			return KingEndGameTable
+		}
+		return KingMiddleGameTable
+	default:
+		return [8][8]int{}
+	}
+}
+
+// pieceSquareScore is a piece's middlegame and endgame table values.
+// Black pieces read the tables flipped vertically
+func pieceSquareScore(piece board.Piece, row, col int) EvalScore {
+	if piece.Color == board.Black {
+		row = 7 - row
+	}
+	mg := GetPieceSquareTable(piece.Type, false)
+	eg := GetPieceSquareTable(piece.Type, true)
+	return EvalScore{mg[row][col], eg[row][col]}
+}
+
+// Evaluate position
+// Positive values for white, negative for black
+func Evaluate(g *game.GameState) int {
+	if UseNNUE && CurrentNetwork != nil {
+		return EvaluateNNUE(g, CurrentNetwork)
+	}
+	return EvaluateTrace(g).Total
+}
+
+// Get Game Phase return value from 0 - endgame, to 1 - opening. The material
+// on the board is measured against the starting material with the piece
+// values in use, so tuned values still give the full range
+func GetGamePhase(g *game.GameState) float64 {
+	startingMaterial := 16*PieceValues[board.Pawn] + 2*PieceValues[board.Queen] +
+		4*(PieceValues[board.Knight]+PieceValues[board.Bishop]+PieceValues[board.Rook])
+	if startingMaterial <= 0 {
+		return 0
+	}
+	totalMaterial := 0
+
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			piece := g.Board.GetPiece(row, col)
+			if piece.Type != board.Empty && piece.Type != board.King {
+				totalMaterial += PieceValues[piece.Type]
+			}
+		}
+	}
+
+	phase := float64(totalMaterial) / float64(startingMaterial) // Promotions can take it past 1
+	return math.Min(1.0, math.Max(0.0, phase))
+
+}
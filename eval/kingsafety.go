@@ -0,0 +1,191 @@
+package eval
+
+import (
+	"math/bits"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+// King safety weights. Everything adds up to danger units, which are turned
+// into a score that grows with the square of the danger
+var (
+	// KingAttackerWeight is added for each enemy piece attacking the king zone
+	KingAttackerWeight [7]int
+
+	// KingSafeCheckWeight is added for each square a piece can safely give check from
+	KingSafeCheckWeight [7]int
+
+	KingZoneAttackWeight int // Per attack on a square of the king zone
+	KingShieldMissing    int // No friendly pawn in front of the king on a file
+	KingShieldAdvanced   int // Per rank the shield pawn has moved away from the king
+	KingOpenFile         int // No pawns at all on a file next to the king
+	KingSemiOpenFile     int // Only enemy pawns on a file next to the king
+
+	// KingPawnStorm is indexed by how many ranks an enemy pawn is in front of the king
+	KingPawnStorm [8]int
+
+	// The middlegame penalty is danger squared over KingDangerDivisor and the
+	// endgame penalty danger over KingDangerEndGameDivisor
+	KingDangerDivisor        int
+	KingDangerEndGameDivisor int
+)
+
+// kingZone is the king's square, its neighbours and the three squares two ranks in front
+func kingZone(b *board.Board, color, kingRow, kingCol int) uint64 {
+	zone := squareBit(kingRow, kingCol) | attackSet(b, kingRow, kingCol, board.King)
+	forward := -1
+	if color == board.Black {
+		forward = 1
+	}
+	for dc := -1; dc <= 1; dc++ {
+		if board.IsValidSquare(kingRow+2*forward, kingCol+dc) {
+			zone |= squareBit(kingRow+2*forward, kingCol+dc)
+		}
+	}
+	return zone
+}
+
+// attackedBy returns every square attacked by color's pieces
+func attackedBy(b *board.Board, color int) uint64 {
+	attacks := pawnAttacks(b, color)
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			piece := b.GetPiece(row, col)
+			if piece.Type != board.Empty && piece.Type != board.Pawn && piece.Color == color {
+				attacks |= attackSet(b, row, col, piece.Type)
+			}
+		}
+	}
+	return attacks
+}
+
+// kingDanger adds up the danger units threatening color's king
+func kingDanger(g *game.GameState, color int) int {
+	b := g.Board
+	kingRow, kingCol := b.FindKing(color)
+	if kingRow == -1 {
+		return 0
+	}
+	enemy := 1 - color
+	zone := kingZone(b, color, kingRow, kingCol)
+	defended := attackedBy(b, color)
+	enemyPieces := occupancy(b, enemy)
+
+	// Squares a piece would give check from
+	checkSquares := [7]uint64{
+		board.Knight: attackSet(b, kingRow, kingCol, board.Knight),
+		board.Bishop: attackSet(b, kingRow, kingCol, board.Bishop),
+		board.Rook:   attackSet(b, kingRow, kingCol, board.Rook),
+	}
+	checkSquares[board.Queen] = checkSquares[board.Bishop] | checkSquares[board.Rook]
+
+	danger := 0
+	attackers, attackerWeight := 0, 0
+	hasQueen := false
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			piece := b.GetPiece(row, col)
+			if piece.Color != enemy {
+				continue
+			}
+			switch piece.Type {
+			case board.Knight, board.Bishop, board.Rook, board.Queen:
+			default:
+				continue
+			}
+
+			attacks := attackSet(b, row, col, piece.Type)
+			if piece.Type == board.Queen {
+				hasQueen = true
+			}
+			if zoneAttacks := bits.OnesCount64(attacks & zone); zoneAttacks > 0 {
+				attackers++
+				attackerWeight += KingAttackerWeight[piece.Type]
+				danger += zoneAttacks * KingZoneAttackWeight
+			}
+
+			// Checks from squares we don't cover and aren't blocked by their own pieces
+			safeChecks := attacks & checkSquares[piece.Type] &^ defended &^ enemyPieces
+			danger += bits.OnesCount64(safeChecks) * KingSafeCheckWeight[piece.Type]
+		}
+	}
+
+	// A single attacker without a queen is rarely dangerous
+	if attackers >= 2 || (attackers == 1 && hasQueen) {
+		danger += attackerWeight
+	}
+
+	danger += kingShelter(g, color, kingRow, kingCol)
+	return danger
+}
+
+// kingShelter adds danger for a weak pawn shield, advancing enemy pawns and
+// open files on and beside the king's file
+func kingShelter(g *game.GameState, color, kingRow, kingCol int) int {
+	b := g.Board
+	kingRank := relativeRank(color, kingRow)
+	danger := 0
+
+	// Look at three files even when the king is on the edge
+	center := kingCol
+	if center == 0 {
+		center = 1
+	} else if center == 7 {
+		center = 6
+	}
+
+	for col := center - 1; col <= center+1; col++ {
+		ownRank, enemyRank := 8, 8 // Nearest pawn in front of the king, 8 for none
+		ownPawns, enemyPawns := 0, 0
+		for row := 0; row < 8; row++ {
+			piece := b.GetPiece(row, col)
+			if piece.Type != board.Pawn {
+				continue
+			}
+			rank := relativeRank(color, row)
+			if piece.Color == color {
+				ownPawns++
+				if rank > kingRank && rank < ownRank {
+					ownRank = rank
+				}
+			} else {
+				enemyPawns++
+				if rank > kingRank && rank < enemyRank {
+					enemyRank = rank
+				}
+			}
+		}
+
+		switch {
+		case ownRank == 8:
+			danger += KingShieldMissing
+		case ownRank-kingRank > 1:
+			danger += (ownRank - kingRank - 1) * KingShieldAdvanced
+		}
+
+		// A storming pawn that has run into our shield pawn is less of a threat
+		if enemyRank < 8 {
+			storm := KingPawnStorm[enemyRank-kingRank]
+			if ownRank == enemyRank-1 {
+				storm /= 2
+			}
+			danger += storm
+		}
+
+		switch {
+		case ownPawns == 0 && enemyPawns == 0:
+			danger += KingOpenFile
+		case ownPawns == 0:
+			danger += KingSemiOpenFile
+		}
+	}
+	return danger
+}
+
+// evaluateKingSafety scores how exposed color's king is, from color's point of
+// view. The middlegame penalty grows with the square of the danger
+func evaluateKingSafety(g *game.GameState, color int) EvalScore {
+	danger := kingDanger(g, color)
+	return EvalScore{-danger * danger / KingDangerDivisor, -danger / KingDangerEndGameDivisor}
+}
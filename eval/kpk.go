@@ -0,0 +1,223 @@
+package eval
+
+import (
+	"math/bits"
+	"sync"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+// The KPK bitbase holds one bit per king and pawn vs king position: whether
+// the side with the pawn wins. Positions are seen with white holding the pawn
+// on files a-d, using squares numbered from a1 = 0, and indexed by
+//
+//	white king | black king << 6 | side to move << 12 | pawn file << 13 | (6 - pawn rank) << 15
+//
+// so pawns on ranks 2-7 take 24 slots
+const kpkPositions = 2 * 24 * 64 * 64
+
+var (
+	kpkOnce    sync.Once
+	kpkBitbase [kpkPositions / 32]uint32
+)
+
+// Results of positions during retrograde analysis
+const (
+	kpkInvalid = 0
+	kpkUnknown = 1
+	kpkDraw    = 2
+	kpkWin     = 4
+)
+
+// kpkPosition is one position being classified
+type kpkPosition struct {
+	sideToMove int
+	kings      [2]int // Squares of the white and black kings
+	pawn       int
+	result     uint8
+}
+
+func kpkIndex(sideToMove, blackKing, whiteKing, pawn int) int {
+	return whiteKing | blackKing<<6 | sideToMove<<12 | (pawn&7)<<13 | (6-pawn>>3)<<15
+}
+
+// kpkKingAttacks returns the squares a king on sq attacks, with a1 = 0
+func kpkKingAttacks(sq int) uint64 {
+	var attacks uint64
+	rank, file := sq>>3, sq&7
+	for dr := -1; dr <= 1; dr++ {
+		for df := -1; df <= 1; df++ {
+			r, f := rank+dr, file+df
+			if (dr != 0 || df != 0) && r >= 0 && r < 8 && f >= 0 && f < 8 {
+				attacks |= 1 << uint(r*8+f)
+			}
+		}
+	}
+	return attacks
+}
+
+// kpkPawnAttacks returns the squares a white pawn on sq attacks
+func kpkPawnAttacks(sq int) uint64 {
+	var attacks uint64
+	if file := sq & 7; file > 0 {
+		attacks |= 1 << uint(sq+7)
+	}
+	if file := sq & 7; file < 7 {
+		attacks |= 1 << uint(sq+9)
+	}
+	return attacks
+}
+
+// newKPKPosition decodes an index and settles the positions that are decided
+// without looking ahead
+func newKPKPosition(index int) kpkPosition {
+	pos := kpkPosition{
+		kings:      [2]int{index & 63, index >> 6 & 63},
+		sideToMove: index >> 12 & 1,
+		pawn:       (index>>13&3 | (6-(index>>15))<<3),
+	}
+	whiteKing, blackKing, pawn := pos.kings[board.White], pos.kings[board.Black], pos.pawn
+	promotion := pawn + 8
+
+	switch {
+	// Kings touching or on the pawn, or black left in check by the pawn
+	case kingDistanceSq(whiteKing, blackKing) <= 1 || whiteKing == pawn || blackKing == pawn ||
+		(pos.sideToMove == board.White && kpkPawnAttacks(pawn)&(1<<uint(blackKing)) != 0):
+		pos.result = kpkInvalid
+
+	// The pawn promotes and the queen can't be taken
+	case pos.sideToMove == board.White && pawn>>3 == 6 && whiteKing != promotion && blackKing != promotion &&
+		(kingDistanceSq(blackKing, promotion) > 1 || kingDistanceSq(whiteKing, promotion) == 1):
+		pos.result = kpkWin
+
+	// Stalemate, or black takes the undefended pawn
+	case pos.sideToMove == board.Black &&
+		(kpkKingAttacks(blackKing)&^(kpkKingAttacks(whiteKing)|kpkPawnAttacks(pawn)) == 0 ||
+			kpkKingAttacks(blackKing)&^kpkKingAttacks(whiteKing)&(1<<uint(pawn)) != 0):
+		pos.result = kpkDraw
+
+	default:
+		pos.result = kpkUnknown
+	}
+	return pos
+}
+
+// kingDistanceSq is the king distance between two squares numbered from a1 = 0
+func kingDistanceSq(a, b int) int {
+	return kingDistance(a>>3, a&7, b>>3, b&7)
+}
+
+// classify works out a position from the results of the positions it can reach.
+// White wins if any move wins; black draws if any move draws
+func (pos *kpkPosition) classify(db []kpkPosition) uint8 {
+	us := pos.sideToMove
+	them := 1 - us
+	good, bad := uint8(kpkWin), uint8(kpkDraw)
+	if us == board.Black {
+		good, bad = kpkDraw, kpkWin
+	}
+
+	var r uint8 = kpkInvalid
+	moves := kpkKingAttacks(pos.kings[us])
+	for moves != 0 {
+		to := bits.TrailingZeros64(moves)
+		moves &= moves - 1
+		if us == board.White {
+			r |= db[kpkIndex(them, pos.kings[board.Black], to, pos.pawn)].result
+		} else {
+			r |= db[kpkIndex(them, to, pos.kings[board.White], pos.pawn)].result
+		}
+	}
+
+	if us == board.White {
+		// Single and double pawn pushes
+		push := pos.pawn + 8
+		if push != pos.kings[board.White] && push != pos.kings[board.Black] && push>>3 < 7 {
+			r |= db[kpkIndex(board.Black, pos.kings[board.Black], pos.kings[board.White], push)].result
+			if pos.pawn>>3 == 1 && push+8 != pos.kings[board.White] && push+8 != pos.kings[board.Black] {
+				r |= db[kpkIndex(board.Black, pos.kings[board.Black], pos.kings[board.White], push+8)].result
+			}
+		}
+	}
+
+	switch {
+	case r&good != 0:
+		return good
+	case r&kpkUnknown != 0:
+		return kpkUnknown
+	default:
+		return bad
+	}
+}
+
+// initKPK builds the bitbase by retrograde analysis: positions decided
+// outright are settled first, then the rest are classified from their
+// successors again and again until nothing changes
+func initKPK() {
+	db := make([]kpkPosition, kpkPositions)
+	for index := range db {
+		db[index] = newKPKPosition(index)
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for index := range db {
+			if db[index].result != kpkUnknown {
+				continue
+			}
+			if result := db[index].classify(db); result != kpkUnknown {
+				db[index].result = result
+				changed = true
+			}
+		}
+	}
+
+	// Positions still unknown can't be won
+	for index, pos := range db {
+		if pos.result == kpkWin {
+			kpkBitbase[index/32] |= 1 << uint(index%32)
+		}
+	}
+}
+
+// ProbeKPK reports whether the side with the pawn wins a king and pawn vs king
+// position. ok is false when the position isn't KPK, or has the pawn on its first or last rank
+func ProbeKPK(g *game.GameState) (win, ok bool) {
+	b := g.Board
+	if b.MaterialSignature() != "KPvK" && b.MaterialSignature() != "KvKP" {
+		return false, false
+	}
+	kpkOnce.Do(initKPK)
+
+	strong := board.White
+	if b.MaterialSignature() == "KvKP" {
+		strong = board.Black
+	}
+	pawn := pieceSquares(b, strong, board.Pawn)[0]
+	if pawn[0] == 0 || pawn[0] == 7 {
+		// Not a legal position, and outside the bitbase
+		return false, false
+	}
+	squares := [3][2]int{kingSquare(b, strong), kingSquare(b, 1-strong), pawn}
+
+	// Turn the board so the pawn is white and on files a-d
+	var normalized [3]int
+	for i, sq := range squares {
+		rank, file := 7-sq[0], sq[1]
+		if strong == board.Black {
+			rank = 7 - rank
+		}
+		if pawn[1] >= 4 {
+			file = 7 - file
+		}
+		normalized[i] = rank*8 + file
+	}
+	sideToMove := board.White
+	if g.CurrentPlayer != strong {
+		sideToMove = board.Black
+	}
+
+	index := kpkIndex(sideToMove, normalized[1], normalized[0], normalized[2])
+	return kpkBitbase[index/32]&(1<<uint(index%32)) != 0, true
+}
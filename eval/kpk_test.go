@@ -0,0 +1,52 @@
+package eval
+
+import (
+	"testing"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+func TestProbeKPK(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		win  bool
+	}{
+		{"king in front on the 6th", "4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", true},
+		{"king in front on the 6th, black to move", "4k3/8/4K3/4P3/8/8/8/8 b - - 0 1", true},
+		{"defender has the opposition", "4k3/8/4P3/4K3/8/8/8/8 w - - 0 1", false},
+		{"king on a key square", "4k3/8/3K4/8/4P3/8/8/8 b - - 0 1", true},
+		{"rook pawn with the king in the corner", "k7/8/8/8/8/8/P7/K7 w - - 0 1", false},
+		{"black pawn", "8/8/8/8/4p3/4k3/8/4K3 b - - 0 1", true},
+		{"black pawn, white has the opposition", "8/8/8/8/4k3/4p3/8/4K3 b - - 0 1", false},
+	}
+	for _, test := range tests {
+		g, err := game.NewGameFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		win, ok := ProbeKPK(g)
+		if !ok {
+			t.Errorf("%s: not probed", test.name)
+		} else if win != test.win {
+			t.Errorf("%s: win %v, want %v", test.name, win, test.win)
+		}
+	}
+}
+
+func TestProbeKPKBackRankPawn(t *testing.T) {
+	g, err := game.NewGameFromFEN("8/8/8/8/8/8/k7/2K5 b - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []int{0, 7} {
+		for _, color := range []int{board.White, board.Black} {
+			position := g.Copy()
+			position.Board.SetPiece(row, 7, board.Piece{Type: board.Pawn, Color: color})
+			if _, ok := ProbeKPK(position); ok {
+				t.Errorf("%s pawn on row %d was probed", board.ColorName(color), row)
+			}
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package eval
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+// NNUE network file layout, all little endian:
+//
+//	magic "NNUE", version uint32 (1), hidden size uint32
+//	feature weights int16[768][hidden], feature biases int16[hidden]
+//	output weights int16[2*hidden], side to move's half first
+//	output bias int32, already scaled by nnueQA*nnueQB
+//
+// Features are the 768 (color, piece, square) combinations seen from each
+// side: colors are relative to the side, pieces ordered pawn, knight, bishop,
+// rook, queen, king, and squares numbered from a1, flipped vertically for black
+const (
+	nnueMagic   = "NNUE"
+	nnueVersion = 1
+	nnueInputs  = 768
+	nnueQA      = 255 // Feature weights are scaled by this and activations clipped to it
+	nnueQB      = 64  // Output weights are scaled by this
+	nnueScale   = 400 // Network output to centipawns
+
+	nnueMaxHidden = 4096
+)
+
+// nnuePieceIndex orders piece types the way network files expect them
+var nnuePieceIndex = [7]int{board.Pawn: 0, board.Knight: 1, board.Bishop: 2, board.Rook: 3, board.Queen: 4, board.King: 5}
+
+// Network is an efficiently updatable neural network evaluator: one hidden
+// layer, computed for each side, feeding a single output
+type Network struct {
+	Path   string
+	Hidden int
+
+	featureWeights []int16 // [feature*Hidden + i]
+	featureBias    []int16
+	outputWeights  []int16
+	outputBias     int32
+}
+
+// The network in use. Evaluation uses it instead of the classical terms while UseNNUE is set
+var (
+	CurrentNetwork *Network
+	UseNNUE        bool
+)
+
+// LoadNetwork reads a network file
+func LoadNetwork(path string) (*Network, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	net, err := ReadNetwork(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	net.Path = path
+	return net, nil
+}
+
+// ReadNetwork reads a network in the layout described above
+func ReadNetwork(r io.Reader) (*Network, error) {
+	var header struct {
+		Magic   [4]byte
+		Version uint32
+		Hidden  uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("reading header: %v", err)
+	}
+	if string(header.Magic[:]) != nnueMagic {
+		return nil, fmt.Errorf("not a network file")
+	}
+	if header.Version != nnueVersion {
+		return nil, fmt.Errorf("unsupported network version %d", header.Version)
+	}
+	if header.Hidden == 0 || header.Hidden > nnueMaxHidden {
+		return nil, fmt.Errorf("bad hidden layer size %d", header.Hidden)
+	}
+
+	hidden := int(header.Hidden)
+	net := &Network{
+		Hidden:         hidden,
+		featureWeights: make([]int16, nnueInputs*hidden),
+		featureBias:    make([]int16, hidden),
+		outputWeights:  make([]int16, 2*hidden),
+	}
+	for _, data := range []interface{}{net.featureWeights, net.featureBias, net.outputWeights, &net.outputBias} {
+		if err := binary.Read(r, binary.LittleEndian, data); err != nil {
+			return nil, fmt.Errorf("truncated network: %v", err)
+		}
+	}
+	if _, err := r.Read(make([]byte, 1)); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the network")
+	}
+	return net, nil
+}
+
+// nnueFeature is the input index of a piece on a square seen from perspective's side
+func nnueFeature(perspective int, piece board.Piece, row, col int) int {
+	square := (7-row)*8 + col
+	relativeColor := 0
+	if perspective == board.Black {
+		square ^= 56
+	}
+	if piece.Color != perspective {
+		relativeColor = 1
+	}
+	return relativeColor*384 + nnuePieceIndex[piece.Type]*64 + square
+}
+
+// nnueAccumulator holds the hidden layer of a network for both perspectives.
+// A board keeps one in step with its pieces, so evaluation only has to run
+// the output layer
+type nnueAccumulator struct {
+	net    *Network
+	values [2][]int16 // [perspective][hidden]
+}
+
+// newAccumulator computes the hidden layer for a board from scratch
+func (n *Network) newAccumulator(b *board.Board) *nnueAccumulator {
+	acc := &nnueAccumulator{net: n}
+	for perspective := board.White; perspective <= board.Black; perspective++ {
+		acc.values[perspective] = append([]int16(nil), n.featureBias...)
+	}
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			if piece := b.GetPiece(row, col); piece.Type != board.Empty {
+				acc.add(piece, row, col)
+			}
+		}
+	}
+	return acc
+}
+
+// attach gives a board an accumulator for the network if it doesn't have one
+func (n *Network) attach(b *board.Board) *nnueAccumulator {
+	acc, ok := b.Accumulator().(*nnueAccumulator)
+	if !ok || acc.net != n {
+		acc = n.newAccumulator(b)
+		b.SetAccumulator(acc)
+	}
+	return acc
+}
+
+// Clone copies an accumulator for a copy of its board
+func (acc *nnueAccumulator) Clone() board.Accumulator {
+	c := &nnueAccumulator{net: acc.net}
+	for perspective := range acc.values {
+		c.values[perspective] = append([]int16(nil), acc.values[perspective]...)
+	}
+	return c
+}
+
+// add turns on the features of a piece arriving on a square
+func (acc *nnueAccumulator) add(piece board.Piece, row, col int) {
+	hidden := acc.net.Hidden
+	for perspective := board.White; perspective <= board.Black; perspective++ {
+		feature := nnueFeature(perspective, piece, row, col)
+		weights := acc.net.featureWeights[feature*hidden : (feature+1)*hidden]
+		values := acc.values[perspective]
+		for i, w := range weights {
+			values[i] += w
+		}
+	}
+}
+
+// remove turns off the features of a piece leaving a square
+func (acc *nnueAccumulator) remove(piece board.Piece, row, col int) {
+	hidden := acc.net.Hidden
+	for perspective := board.White; perspective <= board.Black; perspective++ {
+		feature := nnueFeature(perspective, piece, row, col)
+		weights := acc.net.featureWeights[feature*hidden : (feature+1)*hidden]
+		values := acc.values[perspective]
+		for i, w := range weights {
+			values[i] -= w
+		}
+	}
+}
+
+// Update follows a square changing from one piece to another
+func (acc *nnueAccumulator) Update(old, piece board.Piece, row, col int) {
+	if old == piece {
+		return
+	}
+	if old.Type != board.Empty {
+		acc.remove(old, row, col)
+	}
+	if piece.Type != board.Empty {
+		acc.add(piece, row, col)
+	}
+}
+
+// Evaluate scores a board for the side to move, positive for white
+func (n *Network) Evaluate(b *board.Board, sideToMove int) int {
+	acc := n.attach(b)
+
+	us, them := acc.values[sideToMove], acc.values[1-sideToMove]
+	var sum int64
+	for i := 0; i < n.Hidden; i++ {
+		sum += int64(clippedReLU(us[i])) * int64(n.outputWeights[i])
+		sum += int64(clippedReLU(them[i])) * int64(n.outputWeights[n.Hidden+i])
+	}
+	sum += int64(n.outputBias)
+
+	score := int(sum * nnueScale / (nnueQA * nnueQB))
+	if sideToMove == board.Black {
+		score = -score
+	}
+	return score
+}
+
+// clippedReLU clamps a hidden value to the range of the activation
+func clippedReLU(x int16) int32 {
+	switch {
+	case x < 0:
+		return 0
+	case x > nnueQA:
+		return nnueQA
+	}
+	return int32(x)
+}
+
+// EvaluateNNUE scores the position with a network, positive for white
+func EvaluateNNUE(g *game.GameState, net *Network) int {
+	if score, over := terminalScore(g); over {
+		return score
+	}
+	if score, _, ok := probeEndgame(g); ok {
+		return score
+	}
+	return net.Evaluate(g.Board, g.CurrentPlayer)
+}
+
+// PrepareEvaluation sets the board up for the evaluator in use before a
+// search, so every position searched from it is updated incrementally
+func PrepareEvaluation(g *game.GameState) {
+	if UseNNUE && CurrentNetwork != nil {
+		CurrentNetwork.attach(g.Board)
+	} else {
+		g.Board.SetAccumulator(nil)
+	}
+}
@@ -0,0 +1,120 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+// testNetworkData writes a network of small random weights in the file layout
+func testNetworkData(hidden int) []byte {
+	rng := rand.New(rand.NewSource(1))
+	var buf bytes.Buffer
+	buf.WriteString(nnueMagic)
+	binary.Write(&buf, binary.LittleEndian, [2]uint32{nnueVersion, uint32(hidden)})
+	weights := make([]int16, (nnueInputs+1+2)*hidden)
+	for i := range weights {
+		weights[i] = int16(rng.Intn(128) - 64)
+	}
+	binary.Write(&buf, binary.LittleEndian, weights)
+	binary.Write(&buf, binary.LittleEndian, int32(1000))
+	return buf.Bytes()
+}
+
+func TestReadNetworkErrors(t *testing.T) {
+	const hidden = 8
+	data := testNetworkData(hidden)
+	if _, err := ReadNetwork(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	header := func(magic string, version, hidden uint32) []byte {
+		b := []byte(magic)
+		b = binary.LittleEndian.AppendUint32(b, version)
+		return binary.LittleEndian.AppendUint32(b, hidden)
+	}
+	tests := []struct {
+		name, data, err string
+	}{
+		{"empty file", "", "reading header"},
+		{"short header", nnueMagic, "reading header"},
+		{"wrong magic", string(header("NNUF", nnueVersion, hidden)) + string(data[12:]), "not a network file"},
+		{"wrong version", string(header(nnueMagic, 2, hidden)) + string(data[12:]), "unsupported network version"},
+		{"no hidden layer", string(header(nnueMagic, nnueVersion, 0)), "bad hidden layer size"},
+		{"hidden layer too large", string(header(nnueMagic, nnueVersion, nnueMaxHidden+1)), "bad hidden layer size"},
+		{"truncated", string(data[:len(data)-10]), "truncated network"},
+		// Weights for 8 hidden values read as a layer of 16 run out, and as a
+		// layer of 4 leave data over
+		{"layer larger than the weights", string(header(nnueMagic, nnueVersion, 2*hidden)) + string(data[12:]), "truncated network"},
+		{"layer smaller than the weights", string(header(nnueMagic, nnueVersion, hidden/2)) + string(data[12:]), "unexpected data"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadNetwork(strings.NewReader(test.data))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got error %v, want one about %q", err, test.err)
+			}
+		})
+	}
+}
+
+// TestAccumulatorIncremental plays and takes back moves that change several
+// squares at once, checking the accumulator kept in step with the board
+// matches one computed from scratch
+func TestAccumulatorIncremental(t *testing.T) {
+	net, err := ReadNetwork(bytes.NewReader(testNetworkData(16)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := game.NewGameFromFEN("r3k2r/1P6/8/3pP3/8/8/8/R3K2R w KQkq d6 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.attach(g.Board)
+
+	check := func(when string) {
+		t.Helper()
+		acc, ok := g.Board.Accumulator().(*nnueAccumulator)
+		if !ok {
+			t.Fatalf("%s: the board lost its accumulator", when)
+		}
+		fresh := net.newAccumulator(g.Board)
+		for perspective := board.White; perspective <= board.Black; perspective++ {
+			if !slices.Equal(acc.values[perspective], fresh.values[perspective]) {
+				t.Fatalf("%s: %s's hidden layer differs from a fresh one", when, board.ColorName(perspective))
+			}
+		}
+	}
+
+	// En passant, castling each way and a capturing promotion
+	moves := []string{"e5d6", "e8g8", "b7a8q", "g8g7", "e1c1"}
+	for _, notation := range moves {
+		move, ok := g.ParseMove(notation)
+		if !ok || !g.MakeMove(move) {
+			t.Fatalf("can't play %s", notation)
+		}
+		check("after " + notation)
+	}
+	for range moves {
+		move, _ := g.UndoMove()
+		check("after taking back " + move.String())
+	}
+	for range moves {
+		move, _ := g.RedoMove()
+		check("after replaying " + move.String())
+	}
+
+	// A search copy carries its own accumulator on; a copy for testing moves has none
+	if g.Copy().Board.Accumulator() == g.Board.Accumulator() {
+		t.Error("a copy shares its accumulator with the original")
+	}
+	if g.DetachedCopy().Board.Accumulator() != nil {
+		t.Error("a detached copy has an accumulator")
+	}
+}
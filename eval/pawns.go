@@ -0,0 +1,249 @@
+package eval
+
+import (
+	"math/rand"
+	"sync/atomic"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+// Pawn structure weights
+var (
+	DoubledPawnPenalty  EvalScore // Per extra pawn on a file
+	IsolatedPawnPenalty EvalScore // No friendly pawns on the neighbouring files
+	BackwardPawnPenalty EvalScore // Can't be supported and its stop square is covered by an enemy pawn
+	ConnectedPawnBonus  EvalScore // Defended by a pawn or standing beside one
+
+	// PassedPawnBonus is indexed by rank counted from the pawn's own side, 0 being the first rank
+	PassedPawnBonus [8]EvalScore
+
+	// Endgame passed pawn terms, multiplied by how far the pawn has advanced
+	PassedPawnFreePath     EvalScore // No pieces on the path to promotion
+	PassedPawnKingDistance EvalScore // Per square the enemy king is further away than our own
+)
+
+// pawnHashSize is the number of entries in the pawn hash table, a power of two
+const pawnHashSize = 1 << 14
+
+// pawnEntry caches the evaluation of a pawn skeleton
+type pawnEntry struct {
+	key    uint64
+	score  [2]EvalScore // Structure score for each side
+	passed [2]uint64    // Squares (row*8 + col) of each side's passed pawns
+}
+
+// The pawn hash table is shared by all games; entries are swapped atomically
+// so concurrent searches can use it
+var pawnHash [pawnHashSize]atomic.Pointer[pawnEntry]
+
+// pawnZobrist holds random keys for a pawn of each color on each square
+var pawnZobrist [2][64]uint64
+
+func init() {
+	r := rand.New(rand.NewSource(0x5EED))
+	for color := 0; color < 2; color++ {
+		for sq := 0; sq < 64; sq++ {
+			pawnZobrist[color][sq] = r.Uint64()
+		}
+	}
+}
+
+// clearPawnHash empties the pawn hash table
+func clearPawnHash() {
+	for i := range pawnHash {
+		pawnHash[i].Store(nil)
+	}
+}
+
+// pawnKey hashes the position of the pawns only
+func pawnKey(b *board.Board) uint64 {
+	var key uint64
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			piece := b.GetPiece(row, col)
+			if piece.Type == board.Pawn {
+				key ^= pawnZobrist[piece.Color][row*8+col]
+			}
+		}
+	}
+	return key
+}
+
+// relativeRank counts ranks from color's own side, 0 for its back rank
+func relativeRank(color, row int) int {
+	if color == board.White {
+		return 7 - row
+	}
+	return row
+}
+
+// evaluatePawns scores color's pawn structure and passed pawns
+func evaluatePawns(g *game.GameState, color int) EvalScore {
+	entry := probePawnHash(g.Board)
+
+	// Passed pawn terms that depend on the pieces are worked out every time
+	return entry.score[color].Add(evaluatePassedPawns(g, color, entry.passed[color]))
+}
+
+// probePawnHash returns the cached structure evaluation, computing it on a miss
+func probePawnHash(b *board.Board) *pawnEntry {
+	key := pawnKey(b)
+	slot := &pawnHash[key&(pawnHashSize-1)]
+	if entry := slot.Load(); entry != nil && entry.key == key {
+		return entry
+	}
+
+	entry := &pawnEntry{key: key}
+	for color := board.White; color <= board.Black; color++ {
+		entry.score[color] = evaluatePawnStructure(b, color, &entry.passed[color])
+	}
+	slot.Store(entry)
+	return entry
+}
+
+// evaluatePawnStructure scores one side's pawns, recording its passed pawns
+func evaluatePawnStructure(b *board.Board, color int, passed *uint64) EvalScore {
+	var score EvalScore
+	enemy := 1 - color
+	forward := -1
+	if color == board.Black {
+		forward = 1
+	}
+
+	isPawn := func(row, col, c int) bool {
+		if !board.IsValidSquare(row, col) {
+			return false
+		}
+		piece := b.GetPiece(row, col)
+		return piece.Type == board.Pawn && piece.Color == c
+	}
+
+	var fileCount [8]int
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			if isPawn(row, col, color) {
+				fileCount[col]++
+			}
+		}
+	}
+	for col := 0; col < 8; col++ {
+		if fileCount[col] > 1 {
+			score = score.Sub(DoubledPawnPenalty.Scale(fileCount[col] - 1))
+		}
+	}
+
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			if !isPawn(row, col, color) {
+				continue
+			}
+
+			isolated := (col == 0 || fileCount[col-1] == 0) && (col == 7 || fileCount[col+1] == 0)
+			if isolated {
+				score = score.Sub(IsolatedPawnPenalty)
+			}
+
+			supported := isPawn(row-forward, col-1, color) || isPawn(row-forward, col+1, color)
+			phalanx := isPawn(row, col-1, color) || isPawn(row, col+1, color)
+			if supported || phalanx {
+				score = score.Add(ConnectedPawnBonus)
+			}
+
+			// Backward: every neighbouring pawn has already advanced past it, and
+			// an enemy pawn stops it from catching up
+			if !isolated && !supported && !phalanx {
+				canBeSupported := false
+				for r := row; board.IsValidSquare(r, col); r -= forward {
+					if isPawn(r, col-1, color) || isPawn(r, col+1, color) {
+						canBeSupported = true
+						break
+					}
+				}
+				stopRow := row + forward
+				if !canBeSupported && (isPawn(stopRow+forward, col-1, enemy) || isPawn(stopRow+forward, col+1, enemy)) {
+					score = score.Sub(BackwardPawnPenalty)
+				}
+			}
+
+			// Passed: no enemy pawns ahead on this or the neighbouring files
+			isPassed := true
+			for r := row + forward; board.IsValidSquare(r, col) && isPassed; r += forward {
+				for c := col - 1; c <= col+1; c++ {
+					if isPawn(r, c, enemy) {
+						isPassed = false
+						break
+					}
+				}
+			}
+			// Only the front pawn of doubled passers counts
+			for r := row + forward; board.IsValidSquare(r, col) && isPassed; r += forward {
+				if isPawn(r, col, color) {
+					isPassed = false
+				}
+			}
+			if isPassed {
+				score = score.Add(PassedPawnBonus[relativeRank(color, row)])
+				*passed |= 1 << uint(row*8+col)
+			}
+		}
+	}
+
+	return score
+}
+
+// evaluatePassedPawns adds the endgame terms for passed pawns that depend on
+// the other pieces: a clear path to promotion and the distance of the kings
+func evaluatePassedPawns(g *game.GameState, color int, passed uint64) EvalScore {
+	var score EvalScore
+	if passed == 0 {
+		return score
+	}
+
+	ownKingRow, ownKingCol := g.Board.FindKing(color)
+	enemyKingRow, enemyKingCol := g.Board.FindKing(1 - color)
+	forward := -1
+	if color == board.Black {
+		forward = 1
+	}
+
+	for sq := 0; sq < 64; sq++ {
+		if passed&(1<<uint(sq)) == 0 {
+			continue
+		}
+		row, col := sq/8, sq%8
+		advance := relativeRank(color, row) - 1 // 0 on the starting rank
+
+		freePath := true
+		for r := row + forward; board.IsValidSquare(r, col); r += forward {
+			if g.Board.GetPiece(r, col).Type != board.Empty {
+				freePath = false
+				break
+			}
+		}
+		if freePath {
+			score = score.Add(PassedPawnFreePath.Scale(advance))
+		}
+
+		// Kings are measured against the square in front of the pawn
+		stopRow := row + forward
+		if ownKingRow != -1 && enemyKingRow != -1 {
+			distance := kingDistance(enemyKingRow, enemyKingCol, stopRow, col) -
+				kingDistance(ownKingRow, ownKingCol, stopRow, col)
+			score = score.Add(PassedPawnKingDistance.Scale(distance * advance))
+		}
+	}
+	return score
+}
+
+// kingDistance is the number of king moves between two squares
+func kingDistance(row1, col1, row2, col2 int) int {
+	return max(abs(row1-row2), abs(col1-col2))
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,297 @@
+package eval
+
+import (
+	"math/bits"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+// Mobility bonuses by number of safe squares a piece attacks
+var (
+	KnightMobility [9]EvalScore
+	BishopMobility [14]EvalScore
+	RookMobility   [15]EvalScore
+	QueenMobility  [28]EvalScore
+)
+
+// Piece placement weights
+var (
+	BishopPairBonus       EvalScore
+	RookOpenFileBonus     EvalScore // No pawns on the file
+	RookSemiOpenFileBonus EvalScore // Only enemy pawns on the file
+	RookOnSeventhBonus    EvalScore // Trapping the king or attacking pawns on the seventh rank
+	KnightOutpostBonus    EvalScore // Pawn-supported square no enemy pawn can attack
+	BishopOutpostBonus    EvalScore
+	TrappedRookPenalty    EvalScore // Shut in by its own uncastled king
+	TrappedBishopPenalty  EvalScore // Cut off on a7/h7 by a pawn on b6/g6
+)
+
+// squareBit is the bitboard bit for a square, numbered row*8 + col
+func squareBit(row, col int) uint64 {
+	return 1 << uint(row*8+col)
+}
+
+var knightOffsets = [8][2]int{{-2, -1}, {-2, 1}, {-1, -2}, {-1, 2}, {1, -2}, {1, 2}, {2, -1}, {2, 1}}
+var rookDirections = [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+var bishopDirections = [4][2]int{{-1, -1}, {-1, 1}, {1, -1}, {1, 1}}
+
+// attackSet returns the squares a knight, bishop, rook, queen or king on a
+// square attacks, including squares of pieces it could capture or defend
+func attackSet(b *board.Board, row, col, pieceType int) uint64 {
+	var attacks uint64
+
+	slide := func(directions [4][2]int) {
+		for _, d := range directions {
+			for r, c := row+d[0], col+d[1]; board.IsValidSquare(r, c); r, c = r+d[0], c+d[1] {
+				attacks |= squareBit(r, c)
+				if b.GetPiece(r, c).Type != board.Empty {
+					break
+				}
+			}
+		}
+	}
+
+	switch pieceType {
+	case board.Knight:
+		for _, o := range knightOffsets {
+			if board.IsValidSquare(row+o[0], col+o[1]) {
+				attacks |= squareBit(row+o[0], col+o[1])
+			}
+		}
+	case board.Bishop:
+		slide(bishopDirections)
+	case board.Rook:
+		slide(rookDirections)
+	case board.Queen:
+		slide(bishopDirections)
+		slide(rookDirections)
+	case board.King:
+		for dr := -1; dr <= 1; dr++ {
+			for dc := -1; dc <= 1; dc++ {
+				if (dr != 0 || dc != 0) && board.IsValidSquare(row+dr, col+dc) {
+					attacks |= squareBit(row+dr, col+dc)
+				}
+			}
+		}
+	}
+	return attacks
+}
+
+// pawnAttacks returns every square attacked by color's pawns
+func pawnAttacks(b *board.Board, color int) uint64 {
+	var attacks uint64
+	forward := -1
+	if color == board.Black {
+		forward = 1
+	}
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			piece := b.GetPiece(row, col)
+			if piece.Type != board.Pawn || piece.Color != color {
+				continue
+			}
+			for _, dc := range []int{-1, 1} {
+				if board.IsValidSquare(row+forward, col+dc) {
+					attacks |= squareBit(row+forward, col+dc)
+				}
+			}
+		}
+	}
+	return attacks
+}
+
+// occupancy returns the squares holding color's pieces
+func occupancy(b *board.Board, color int) uint64 {
+	var occupied uint64
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			piece := b.GetPiece(row, col)
+			if piece.Type != board.Empty && piece.Color == color {
+				occupied |= squareBit(row, col)
+			}
+		}
+	}
+	return occupied
+}
+
+// evaluatePieces scores the mobility and placement of color's minor and major pieces
+func evaluatePieces(g *game.GameState, color int) (mobility, score EvalScore) {
+	b := g.Board
+	enemy := 1 - color
+
+	// Squares covered by enemy pawns or holding our own pieces don't count for mobility
+	enemyPawnAttacks := pawnAttacks(b, enemy)
+	ownPawnAttacks := pawnAttacks(b, color)
+	mobilityArea := ^(enemyPawnAttacks | occupancy(b, color))
+
+	bishops := 0
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			piece := b.GetPiece(row, col)
+			if piece.Color != color {
+				continue
+			}
+
+			switch piece.Type {
+			case board.Knight, board.Bishop, board.Rook, board.Queen:
+			default:
+				continue
+			}
+
+			squares := bits.OnesCount64(attackSet(b, row, col, piece.Type) & mobilityArea)
+
+			switch piece.Type {
+			case board.Knight:
+				mobility = mobility.Add(KnightMobility[squares])
+				if isOutpost(g, color, row, col, ownPawnAttacks) {
+					score = score.Add(KnightOutpostBonus)
+				}
+
+			case board.Bishop:
+				bishops++
+				mobility = mobility.Add(BishopMobility[squares])
+				if isOutpost(g, color, row, col, ownPawnAttacks) {
+					score = score.Add(BishopOutpostBonus)
+				}
+				if isTrappedBishop(g, color, row, col) {
+					score = score.Sub(TrappedBishopPenalty)
+				}
+
+			case board.Rook:
+				mobility = mobility.Add(RookMobility[squares])
+				score = score.Add(rookFileBonus(g, color, col))
+				if isRookOnSeventh(g, color, row) {
+					score = score.Add(RookOnSeventhBonus)
+				}
+				if squares <= 3 && isTrappedRook(g, color, row, col) {
+					score = score.Sub(TrappedRookPenalty)
+				}
+
+			case board.Queen:
+				mobility = mobility.Add(QueenMobility[squares])
+			}
+		}
+	}
+
+	if bishops >= 2 {
+		score = score.Add(BishopPairBonus)
+	}
+	return mobility, score
+}
+
+// isOutpost reports whether a square in the enemy half is defended by one of
+// our pawns and can never be attacked by an enemy pawn
+func isOutpost(g *game.GameState, color, row, col int, ownPawnAttacks uint64) bool {
+	rank := relativeRank(color, row)
+	if rank < 3 || rank > 5 || ownPawnAttacks&squareBit(row, col) == 0 {
+		return false
+	}
+
+	forward := -1
+	if color == board.Black {
+		forward = 1
+	}
+	for r := row + forward; board.IsValidSquare(r, col); r += forward {
+		for _, c := range []int{col - 1, col + 1} {
+			if !board.IsValidSquare(r, c) {
+				continue
+			}
+			piece := g.Board.GetPiece(r, c)
+			if piece.Type == board.Pawn && piece.Color != color {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// rookFileBonus rewards rooks on files free of their own pawns
+func rookFileBonus(g *game.GameState, color, col int) EvalScore {
+	ownPawns, enemyPawns := 0, 0
+	for row := 0; row < 8; row++ {
+		piece := g.Board.GetPiece(row, col)
+		if piece.Type != board.Pawn {
+			continue
+		}
+		if piece.Color == color {
+			ownPawns++
+		} else {
+			enemyPawns++
+		}
+	}
+
+	switch {
+	case ownPawns > 0:
+		return EvalScore{}
+	case enemyPawns > 0:
+		return RookSemiOpenFileBonus
+	default:
+		return RookOpenFileBonus
+	}
+}
+
+// isRookOnSeventh reports whether a rook on the seventh rank is doing
+// something there: attacking pawns or holding the king on the back rank
+func isRookOnSeventh(g *game.GameState, color, row int) bool {
+	if relativeRank(color, row) != 6 {
+		return false
+	}
+
+	backRow := 0
+	if color == board.Black {
+		backRow = 7
+	}
+	if kingRow, _ := g.Board.FindKing(1 - color); kingRow == backRow {
+		return true
+	}
+	for col := 0; col < 8; col++ {
+		piece := g.Board.GetPiece(row, col)
+		if piece.Type == board.Pawn && piece.Color != color {
+			return true
+		}
+	}
+	return false
+}
+
+// isTrappedRook reports whether a rook on its back rank is stuck in the
+// corner behind a king that has given up castling on that side
+func isTrappedRook(g *game.GameState, color, row, col int) bool {
+	kingRow, kingCol := g.Board.FindKing(color)
+	if relativeRank(color, row) != 0 || kingRow != row {
+		return false
+	}
+
+	canCastleK, canCastleQ := g.WhiteCanCastleK, g.WhiteCanCastleQ
+	if color == board.Black {
+		canCastleK, canCastleQ = g.BlackCanCastleK, g.BlackCanCastleQ
+	}
+
+	// King on the kingside with the rook further out, or the same on the queenside
+	if kingCol >= 4 && col > kingCol && !canCastleK {
+		return true
+	}
+	if kingCol <= 3 && col < kingCol && !canCastleQ {
+		return true
+	}
+	return false
+}
+
+// isTrappedBishop spots the bishop that took a rook pawn and is now shut in
+// by the neighbouring pawn, e.g. a white bishop on a7 with a black pawn on b6
+func isTrappedBishop(g *game.GameState, color, row, col int) bool {
+	if relativeRank(color, row) != 6 || (col != 0 && col != 7) {
+		return false
+	}
+
+	forward := -1
+	if color == board.Black {
+		forward = 1
+	}
+	blockCol := 1
+	if col == 7 {
+		blockCol = 6
+	}
+	piece := g.Board.GetPiece(row-forward, blockCol)
+	return piece.Type == board.Pawn && piece.Color != color
+}
@@ -0,0 +1,320 @@
+package eval
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+// TuningPosition is a quiet position labelled with the result of its game
+type TuningPosition struct {
+	Game   *game.GameState
+	Result float64 // 1 for a white win, 0.5 for a draw, 0 for a black win
+}
+
+// Game results as written in EPD, PGN or plain number form
+var tuningResult = regexp.MustCompile(`(1-0|0-1|1/2-1/2|1\.0|0\.5|0\.0)`)
+
+var tuningResultValues = map[string]float64{
+	"1-0": 1, "1.0": 1,
+	"1/2-1/2": 0.5, "0.5": 0.5,
+	"0-1": 0, "0.0": 0,
+}
+
+// LoadTuningData reads one position per line: a FEN followed by the game
+// result, e.g. `<fen> [0.5]`, `<fen> c9 "1-0";` or `<fen> | 0-1`.
+// Positions in check or with no legal moves aren't quiet and are skipped
+func LoadTuningData(path string) (positions []TuningPosition, skipped int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		pos, err := parseTuningLine(line)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		if pos.Game.Board.IsInCheck(pos.Game.CurrentPlayer) || len(pos.Game.GenerateAllLegalMoves()) == 0 {
+			skipped++
+			continue
+		}
+		positions = append(positions, pos)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	return positions, skipped, nil
+}
+
+// parseTuningLine splits a line into its position and the result that follows it
+func parseTuningLine(line string) (TuningPosition, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return TuningPosition{}, fmt.Errorf("expected a FEN and a result")
+	}
+
+	// The result comes after the first four FEN fields and the optional move counters
+	match := tuningResult.FindAllString(strings.Join(fields[4:], " "), -1)
+	if len(match) == 0 {
+		return TuningPosition{}, fmt.Errorf("no game result")
+	}
+	fenFields := fields[:4]
+	for _, field := range fields[4:min(6, len(fields))] {
+		if strings.Trim(field, "0123456789") != "" {
+			break
+		}
+		fenFields = append(fenFields, field)
+	}
+
+	game, err := game.NewGameFromFEN(strings.Join(fenFields, " "))
+	if err != nil {
+		return TuningPosition{}, err
+	}
+	return TuningPosition{Game: game, Result: tuningResultValues[match[len(match)-1]]}, nil
+}
+
+// TunerOptions control a tuning run
+type TunerOptions struct {
+	Threads   int     // Goroutines evaluating positions, 0 for one per CPU
+	MaxPasses int     // Passes over all weights, 0 to run until nothing improves
+	Step      int     // Amount each weight is moved by
+	K         float64 // Sigmoid scaling, 0 to fit it to the data first
+}
+
+// Tuner minimizes the difference between game results and the evaluation of
+// their positions mapped through a sigmoid, using Texel's local search: each
+// weight in turn is moved up or down by a step and kept if the error drops
+type Tuner struct {
+	Positions []TuningPosition
+	Options   TunerOptions
+	Log       io.Writer // Progress messages, nil for none
+
+	// Save is called after every pass with the best parameters so far
+	Save func(*EvalParams) error
+
+	weights []evalWeight
+}
+
+// evalWeight is one tunable number in the evaluation
+type evalWeight struct {
+	get func() int
+	set func(int)
+}
+
+// NewTuner sets up tuning of the current evaluation parameters
+func NewTuner(positions []TuningPosition, options TunerOptions) *Tuner {
+	if options.Threads <= 0 {
+		options.Threads = runtime.NumCPU()
+	}
+	if options.Step <= 0 {
+		options.Step = 1
+	}
+	return &Tuner{Positions: positions, Options: options}
+}
+
+// Run tunes the weights, leaving the best ones found in use, and returns them
+func (t *Tuner) Run() (*EvalParams, error) {
+	if len(t.Positions) == 0 {
+		return nil, fmt.Errorf("no positions to tune on")
+	}
+
+	params := CurrentEvalParams()
+	t.weights = tunableWeights(params)
+
+	scores := t.evaluate()
+	k := t.Options.K
+	if k <= 0 {
+		k = fitK(t.Positions, scores)
+	}
+	bestError := meanError(t.Positions, scores, k)
+	t.logf("%d positions, %d weights, K=%.4f, error %.6f\n", len(t.Positions), len(t.weights), k, bestError)
+
+	for pass := 1; t.Options.MaxPasses == 0 || pass <= t.Options.MaxPasses; pass++ {
+		improved := 0
+		for _, w := range t.weights {
+			value := w.get()
+			for _, delta := range []int{t.Options.Step, -t.Options.Step} {
+				w.set(value + delta)
+				if err := params.Apply(); err != nil {
+					// A divisor or similar can't take this value
+					continue
+				}
+				if e := meanError(t.Positions, t.evaluate(), k); e < bestError {
+					bestError = e
+					value += delta
+					improved++
+					break
+				}
+			}
+			w.set(value)
+		}
+
+		if err := params.Apply(); err != nil {
+			return nil, err
+		}
+		t.logf("Pass %d: error %.6f, %d weights changed\n", pass, bestError, improved)
+		if t.Save != nil {
+			if err := t.Save(params); err != nil {
+				return nil, err
+			}
+		}
+		if improved == 0 {
+			break
+		}
+	}
+	return params, nil
+}
+
+func (t *Tuner) logf(format string, args ...interface{}) {
+	if t.Log != nil {
+		fmt.Fprintf(t.Log, format, args...)
+	}
+}
+
+// evaluate scores every position with the weights in use, split across the threads
+func (t *Tuner) evaluate() []int {
+	scores := make([]int, len(t.Positions))
+	chunk := (len(t.Positions) + t.Options.Threads - 1) / t.Options.Threads
+
+	var wg sync.WaitGroup
+	for start := 0; start < len(t.Positions); start += chunk {
+		end := min(start+chunk, len(t.Positions))
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				trace := evaluateTerms(t.Positions[i].Game)
+				scores[i] = trace.Total
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return scores
+}
+
+// sigmoid maps a score in centipawns to an expected result for white
+func sigmoid(score int, k float64) float64 {
+	return 1 / (1 + math.Pow(10, -k*float64(score)/400))
+}
+
+// meanError is the mean squared difference between results and expected results
+func meanError(positions []TuningPosition, scores []int, k float64) float64 {
+	sum := 0.0
+	for i, pos := range positions {
+		diff := pos.Result - sigmoid(scores[i], k)
+		sum += diff * diff
+	}
+	return sum / float64(len(positions))
+}
+
+// fitK finds the sigmoid scaling that best matches the untuned evaluation to
+// the results, narrowing the step each time it can't improve further
+func fitK(positions []TuningPosition, scores []int) float64 {
+	best := 1.0
+	bestError := meanError(positions, scores, best)
+	for step := 0.1; step > 0.00005; step /= 10 {
+		for improved := true; improved; {
+			improved = false
+			for _, k := range []float64{best - step, best + step} {
+				if k <= 0 {
+					continue
+				}
+				if e := meanError(positions, scores, k); e < bestError {
+					best, bestError, improved = k, e, true
+				}
+			}
+		}
+	}
+	return best
+}
+
+// tunableWeights lists every weight in the parameters except the king danger
+// divisors, which only scale the other king safety weights
+func tunableWeights(p *EvalParams) []evalWeight {
+	var weights []evalWeight
+	addInt := func(v *int) {
+		weights = append(weights, evalWeight{
+			get: func() int { return *v },
+			set: func(n int) { *v = n },
+		})
+	}
+	addScore := func(s *EvalScore) {
+		addInt(&s.MG)
+		addInt(&s.EG)
+	}
+	addMapped := func(m map[string]int, key string) {
+		weights = append(weights, evalWeight{
+			get: func() int { return m[key] },
+			set: func(n int) { m[key] = n },
+		})
+	}
+
+	// Map values can't be addressed, so piece values are read and written back whole
+	for pieceType := board.Pawn; pieceType <= board.Queen; pieceType++ {
+		name := pieceNames[pieceType]
+		weights = append(weights,
+			evalWeight{
+				get: func() int { return p.PieceValues[name].MG },
+				set: func(n int) { p.PieceValues[name] = EvalScore{n, p.PieceValues[name].EG} },
+			},
+			evalWeight{
+				get: func() int { return p.PieceValues[name].EG },
+				set: func(n int) { p.PieceValues[name] = EvalScore{p.PieceValues[name].MG, n} },
+			})
+	}
+	for pieceType := board.Pawn; pieceType <= board.King; pieceType++ {
+		for _, table := range [][][]int{p.PieceSquareMG[pieceNames[pieceType]], p.PieceSquareEG[pieceNames[pieceType]]} {
+			for row := range table {
+				for col := range table[row] {
+					addInt(&table[row][col])
+				}
+			}
+		}
+	}
+	addScore(&p.Castling)
+
+	for _, s := range []*EvalScore{&p.DoubledPawn, &p.IsolatedPawn, &p.BackwardPawn, &p.ConnectedPawn,
+		&p.PassedPawnFreePath, &p.PassedPawnKingDistance} {
+		addScore(s)
+	}
+	for _, list := range [][]EvalScore{p.PassedPawn, p.KnightMobility, p.BishopMobility, p.RookMobility, p.QueenMobility} {
+		for i := range list {
+			addScore(&list[i])
+		}
+	}
+	for _, s := range []*EvalScore{&p.BishopPair, &p.RookOpenFile, &p.RookSemiOpenFile, &p.RookOnSeventh,
+		&p.KnightOutpost, &p.BishopOutpost, &p.TrappedRook, &p.TrappedBishop} {
+		addScore(s)
+	}
+
+	for _, pieceType := range kingAttackerTypes {
+		addMapped(p.KingAttackerWeight, pieceNames[pieceType])
+		addMapped(p.KingSafeCheckWeight, pieceNames[pieceType])
+	}
+	for _, v := range []*int{&p.KingZoneAttack, &p.KingShieldMissing, &p.KingShieldAdvanced,
+		&p.KingOpenFile, &p.KingSemiOpenFile} {
+		addInt(v)
+	}
+	for i := range p.KingPawnStorm {
+		addInt(&p.KingPawnStorm[i])
+	}
+	return weights
+}
@@ -0,0 +1,125 @@
+package eval
+
+import (
+	"math"
+	"testing"
+
+	"chess-engine/game"
+)
+
+func TestSigmoid(t *testing.T) {
+	if got := sigmoid(0, 1.3); got != 0.5 {
+		t.Errorf("even score maps to %v, want 0.5", got)
+	}
+	// 400 centipawns at K=1 is ten to one
+	if got := sigmoid(400, 1); math.Abs(got-10.0/11) > 1e-9 {
+		t.Errorf("sigmoid(400, 1) = %v, want 10/11", got)
+	}
+	for _, score := range []int{35, 250, 1200} {
+		if sum := sigmoid(score, 0.8) + sigmoid(-score, 0.8); math.Abs(sum-1) > 1e-9 {
+			t.Errorf("sigmoid(%d) and sigmoid(%d) add up to %v", score, -score, sum)
+		}
+	}
+}
+
+// TestFitK fits K to results that follow a sigmoid exactly
+func TestFitK(t *testing.T) {
+	const k = 1.37
+	scores := []int{-600, -250, -90, -20, 0, 15, 60, 140, 380, 900}
+	positions := make([]TuningPosition, len(scores))
+	for i, score := range scores {
+		positions[i].Result = sigmoid(score, k)
+	}
+	if got := fitK(positions, scores); math.Abs(got-k) > 0.001 {
+		t.Errorf("fitted K=%.4f, want %.4f", got, k)
+	}
+}
+
+func TestTunerLowersError(t *testing.T) {
+	saved := CurrentEvalParams()
+	defer saved.Apply()
+
+	// White wins the games with more material, draws the level ones and loses
+	// a game where it was ahead, so no setting of the weights fits them all
+	data := []struct {
+		fen    string
+		result float64
+	}{
+		{"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", 1},
+		{"4k3/4p3/8/8/8/8/4P3/4K3 w - - 0 1", 0.5},
+		{"4k3/8/8/8/8/8/3NP3/4K3 w - - 0 1", 1},
+		{"4k3/4p3/8/8/8/8/8/4K3 b - - 0 1", 0},
+		{"r3k3/8/8/8/8/8/8/4K2R w - - 0 1", 0.5},
+		{"4k3/pp6/8/8/8/8/PPP5/4K3 b - - 0 1", 0},
+	}
+	positions := make([]TuningPosition, len(data))
+	for i, d := range data {
+		g, err := game.NewGameFromFEN(d.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		positions[i] = TuningPosition{Game: g, Result: d.result}
+	}
+
+	tuner := NewTuner(positions, TunerOptions{Threads: 2, MaxPasses: 1, Step: 10, K: 1})
+	before := meanError(positions, tuner.evaluate(), 1)
+	saves := 0
+	tuner.Save = func(*EvalParams) error {
+		saves++
+		return nil
+	}
+	params, err := tuner.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := meanError(positions, tuner.evaluate(), 1)
+	if after >= before {
+		t.Errorf("error %.6f after tuning, %.6f before", after, before)
+	}
+	if saves != 1 {
+		t.Errorf("saved %d times in one pass", saves)
+	}
+
+	// The tuned weights are the ones left in use
+	if current := CurrentEvalParams(); current.PieceValues["pawn"] != params.PieceValues["pawn"] {
+		t.Errorf("pawn value %v in use, tuned to %v", current.PieceValues["pawn"], params.PieceValues["pawn"])
+	}
+}
+
+func TestGamePhase(t *testing.T) {
+	saved := CurrentEvalParams()
+	defer saved.Apply()
+
+	tests := []struct {
+		name string
+		fen  string
+		want float64
+	}{
+		{"starting position", game.StartingFEN, 1},
+		{"bare kings", "8/8/8/4k3/8/8/3K4/8 w - - 0 1", 0},
+		{"promoted queens", "QQQQkQQQ/8/8/8/8/8/8/QQQQKQQQ w - - 0 1", 1},
+	}
+	check := func(values string) {
+		t.Helper()
+		for _, test := range tests {
+			g, err := game.NewGameFromFEN(test.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := GetGamePhase(g); got != test.want {
+				t.Errorf("%s, %s: phase %v, want %v", values, test.name, got, test.want)
+			}
+		}
+	}
+	check("default values")
+
+	// The starting position is still the opening with every value halved
+	params := CurrentEvalParams()
+	for name, value := range params.PieceValues {
+		params.PieceValues[name] = EvalScore{value.MG / 2, value.EG / 2}
+	}
+	if err := params.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	check("halved values")
+}
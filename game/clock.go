@@ -0,0 +1,205 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"chess-engine/board"
+)
+
+// TimeControl describes how much time each side gets
+type TimeControl struct {
+	Base            time.Duration // Time for each control period
+	Increment       time.Duration // Added after every move
+	Delay           time.Duration // Time each move can use before the clock starts counting down
+	MovesPerControl int           // Moves in each period, 0 for the whole game
+}
+
+// ParseTimeControl reads a time control of the form [moves/]minutes[+increment][d<delay>],
+// with increment and delay in seconds. For example "5+3", "40/90+30" or "15d5"
+func ParseTimeControl(text string) (TimeControl, error) {
+	var tc TimeControl
+	rest := strings.TrimSpace(text)
+
+	if i := strings.Index(rest, "/"); i >= 0 {
+		moves, err := strconv.Atoi(rest[:i])
+		if err != nil || moves <= 0 {
+			return tc, fmt.Errorf("invalid moves per control in %q", text)
+		}
+		tc.MovesPerControl = moves
+		rest = rest[i+1:]
+	}
+
+	if i := strings.Index(rest, "d"); i >= 0 {
+		delay, err := strconv.ParseFloat(rest[i+1:], 64)
+		if err != nil || delay < 0 {
+			return tc, fmt.Errorf("invalid delay in %q", text)
+		}
+		tc.Delay = time.Duration(delay * float64(time.Second))
+		rest = rest[:i]
+	}
+
+	if i := strings.Index(rest, "+"); i >= 0 {
+		increment, err := strconv.ParseFloat(rest[i+1:], 64)
+		if err != nil || increment < 0 {
+			return tc, fmt.Errorf("invalid increment in %q", text)
+		}
+		tc.Increment = time.Duration(increment * float64(time.Second))
+		rest = rest[:i]
+	}
+
+	minutes, err := strconv.ParseFloat(rest, 64)
+	if err != nil || minutes <= 0 {
+		return tc, fmt.Errorf("invalid base time in %q", text)
+	}
+	tc.Base = time.Duration(minutes * float64(time.Minute))
+
+	return tc, nil
+}
+
+// String formats the time control the way ParseTimeControl reads it
+func (tc TimeControl) String() string {
+	s := strconv.FormatFloat(tc.Base.Minutes(), 'f', -1, 64)
+	if tc.MovesPerControl > 0 {
+		s = strconv.Itoa(tc.MovesPerControl) + "/" + s
+	}
+	if tc.Increment > 0 {
+		s += "+" + strconv.FormatFloat(tc.Increment.Seconds(), 'f', -1, 64)
+	}
+	if tc.Delay > 0 {
+		s += "d" + strconv.FormatFloat(tc.Delay.Seconds(), 'f', -1, 64)
+	}
+	return s
+}
+
+// GameClock is a chess clock for both players
+type GameClock struct {
+	Control   TimeControl
+	Remaining [2]time.Duration
+	MovesMade [2]int
+
+	running int // Side whose clock is running, -1 if stopped
+	started time.Time
+}
+
+// NewGameClock creates a stopped clock with both sides on full time
+func NewGameClock(tc TimeControl) *GameClock {
+	return &GameClock{
+		Control:   tc,
+		Remaining: [2]time.Duration{tc.Base, tc.Base},
+		running:   -1,
+	}
+}
+
+// Start sets the clock running for color, stopping the other side's clock if needed
+func (c *GameClock) Start(color int) {
+	if c.running == color {
+		return
+	}
+	c.Stop()
+	c.running = color
+	c.started = time.Now()
+}
+
+// Stop stops the running clock without completing a move
+func (c *GameClock) Stop() {
+	if c.running == -1 {
+		return
+	}
+	c.Remaining[c.running] = c.TimeLeft(c.running)
+	c.running = -1
+}
+
+// TimeLeft returns the time color has left, counting a running clock
+func (c *GameClock) TimeLeft(color int) time.Duration {
+	remaining := c.Remaining[color]
+	if c.running == color {
+		used := time.Since(c.started) - c.Control.Delay
+		if used > 0 {
+			remaining -= used
+		}
+	}
+	return remaining
+}
+
+// Flagged reports whether color has run out of time
+func (c *GameClock) Flagged(color int) bool {
+	return c.TimeLeft(color) <= 0
+}
+
+// Punch ends the move of the side whose clock is running and starts the opponent's.
+// It returns true if the mover's flag fell before the move was completed
+func (c *GameClock) Punch() bool {
+	color := c.running
+	if color == -1 {
+		return false
+	}
+
+	c.Stop()
+	if c.Remaining[color] <= 0 {
+		c.Remaining[color] = 0
+		return true
+	}
+
+	c.Remaining[color] += c.Control.Increment
+	c.MovesMade[color]++
+	if c.Control.MovesPerControl > 0 && c.MovesMade[color]%c.Control.MovesPerControl == 0 {
+		// Reached the time control, the next period starts
+		c.Remaining[color] += c.Control.Base
+	}
+
+	c.Start(1 - color)
+	return false
+}
+
+// TakeBack uncounts a move of color's that was taken back, so the moves left
+// to the time control stay in step with the game. Time used is not refunded
+func (c *GameClock) TakeBack(color int) {
+	if c.MovesMade[color] > 0 {
+		c.MovesMade[color]--
+	}
+}
+
+// MovesToGo returns the moves color has left before the next time control, 0 for sudden death
+func (c *GameClock) MovesToGo(color int) int {
+	if c.Control.MovesPerControl == 0 {
+		return 0
+	}
+	return c.Control.MovesPerControl - c.MovesMade[color]%c.Control.MovesPerControl
+}
+
+// String shows both clocks, marking the one that is running
+func (c *GameClock) String() string {
+	parts := make([]string, 2)
+	for color := board.White; color <= board.Black; color++ {
+		marker := " "
+		if c.running == color {
+			marker = "*"
+		}
+		parts[color] = fmt.Sprintf("%s%s %s", marker, board.ColorName(color), formatClockTime(c.TimeLeft(color)))
+	}
+	return parts[board.White] + "   " + parts[board.Black] + "   (" + c.Control.String() + ")"
+}
+
+// formatClockTime shows a duration as h:mm:ss, or m:ss.t when under an hour
+func formatClockTime(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	if d >= time.Hour {
+		return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%d:%02d.%d", int(d.Minutes()), int(d.Seconds())%60, int(d.Milliseconds()/100)%10)
+}
+
+// TimeForfeitResult is the result when color's flag falls. The game is drawn
+// instead if the opponent has nothing left that could deliver mate
+func (g *GameState) TimeForfeitResult(color int) string {
+	if !g.Board.HasMatingMaterial(1 - color) {
+		return fmt.Sprintf("Draw: %s ran out of time but %s cannot checkmate",
+			board.ColorName(color), board.ColorName(1-color))
+	}
+	return fmt.Sprintf("%s wins on time", board.ColorName(1-color))
+}
@@ -0,0 +1,48 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"chess-engine/board"
+)
+
+func TestClockMovesToGo(t *testing.T) {
+	clock := NewGameClock(TimeControl{Base: time.Minute, MovesPerControl: 3})
+	clock.Start(board.White)
+	for i := 0; i < 2; i++ {
+		clock.Punch() // White
+		clock.Punch() // Black
+	}
+	if got := clock.MovesToGo(board.White); got != 1 {
+		t.Fatalf("after 2 moves: %d to go, want 1", got)
+	}
+
+	// Taking back a full move puts both sides one move further from the control
+	clock.TakeBack(board.Black)
+	clock.TakeBack(board.White)
+	if got := clock.MovesToGo(board.White); got != 2 {
+		t.Errorf("white after takeback: %d to go, want 2", got)
+	}
+	if got := clock.MovesToGo(board.Black); got != 2 {
+		t.Errorf("black after takeback: %d to go, want 2", got)
+	}
+
+	// Playing on reaches the control on the third move, not the second
+	clock.Start(board.White)
+	clock.Punch()
+	if got := clock.MovesToGo(board.White); got != 1 {
+		t.Errorf("white after replaying: %d to go, want 1", got)
+	}
+	if clock.Remaining[board.White] > time.Minute {
+		t.Errorf("white got the next period's time a move early: %v", clock.Remaining[board.White])
+	}
+}
+
+func TestClockTakeBackAtStart(t *testing.T) {
+	clock := NewGameClock(TimeControl{Base: time.Minute, MovesPerControl: 40})
+	clock.TakeBack(board.White)
+	if got := clock.MovesToGo(board.White); got != 40 {
+		t.Errorf("%d to go, want 40", got)
+	}
+}
@@ -0,0 +1,246 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"chess-engine/board"
+)
+
+// StartingFEN is the FEN string of the standard starting position
+const StartingFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+var fenPieceTypes = map[byte]int{
+	'p': board.Pawn, 'r': board.Rook, 'b': board.Bishop, 'n': board.Knight, 'q': board.Queen, 'k': board.King,
+}
+
+var fenPieceLetters = map[int]byte{
+	board.Pawn: 'p', board.Rook: 'r', board.Bishop: 'b', board.Knight: 'n', board.Queen: 'q', board.King: 'k',
+}
+
+// NewGameFromFEN creates a game from a FEN string
+func NewGameFromFEN(fen string) (*GameState, error) {
+	fields := strings.Fields(fen)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid FEN %q: expected at least 4 fields", fen)
+	}
+
+	g := &GameState{
+		Board:           &board.Board{},
+		MoveHistory:     make([]board.Move, 0),
+		EnPassantSquare: [2]int{-1, -1},
+		FullMoveNumber:  1,
+	}
+
+	// Piece placement, rank 8 first
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != 8 {
+		return nil, fmt.Errorf("invalid FEN %q: expected 8 ranks", fen)
+	}
+	for row, rank := range ranks {
+		col := 0
+		for i := 0; i < len(rank); i++ {
+			c := rank[i]
+			if c >= '1' && c <= '8' {
+				col += int(c - '0')
+				continue
+			}
+			pieceType, ok := fenPieceTypes[toLower(c)]
+			if !ok {
+				return nil, fmt.Errorf("invalid FEN %q: unknown piece %q", fen, c)
+			}
+			if col >= 8 {
+				return nil, fmt.Errorf("invalid FEN %q: rank %d too long", fen, 8-row)
+			}
+			if pieceType == board.Pawn && (row == 0 || row == 7) {
+				return nil, fmt.Errorf("invalid FEN %q: pawn on rank %d", fen, 8-row)
+			}
+			color := board.Black
+			if c >= 'A' && c <= 'Z' {
+				color = board.White
+			}
+			g.Board.SetPiece(row, col, board.Piece{Type: pieceType, Color: color})
+			col++
+		}
+		if col != 8 {
+			return nil, fmt.Errorf("invalid FEN %q: rank %d has %d squares", fen, 8-row, col)
+		}
+	}
+
+	switch fields[1] {
+	case "w":
+		g.CurrentPlayer = board.White
+	case "b":
+		g.CurrentPlayer = board.Black
+	default:
+		return nil, fmt.Errorf("invalid FEN %q: bad side to move %q", fen, fields[1])
+	}
+
+	if fields[2] != "-" {
+		for i := 0; i < len(fields[2]); i++ {
+			switch fields[2][i] {
+			case 'K':
+				g.WhiteCanCastleK = true
+			case 'Q':
+				g.WhiteCanCastleQ = true
+			case 'k':
+				g.BlackCanCastleK = true
+			case 'q':
+				g.BlackCanCastleQ = true
+			default:
+				return nil, fmt.Errorf("invalid FEN %q: bad castling rights %q", fen, fields[2])
+			}
+		}
+	}
+
+	if fields[3] != "-" {
+		row, col, ok := parseSquare(fields[3])
+		if !ok {
+			return nil, fmt.Errorf("invalid FEN %q: bad en passant square %q", fen, fields[3])
+		}
+		g.EnPassantSquare = [2]int{row, col}
+	}
+
+	if len(fields) >= 5 {
+		halfMoves, err := strconv.Atoi(fields[4])
+		if err != nil || halfMoves < 0 {
+			return nil, fmt.Errorf("invalid FEN %q: bad halfmove clock %q", fen, fields[4])
+		}
+		g.HalfMoveClock = halfMoves
+	}
+	if len(fields) >= 6 {
+		fullMoves, err := strconv.Atoi(fields[5])
+		if err != nil || fullMoves < 1 {
+			return nil, fmt.Errorf("invalid FEN %q: bad fullmove number %q", fen, fields[5])
+		}
+		g.FullMoveNumber = fullMoves
+	}
+
+	whiteKings, blackKings := 0, 0
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			piece := g.Board.GetPiece(row, col)
+			if piece.Type == board.King {
+				if piece.Color == board.White {
+					whiteKings++
+				} else {
+					blackKings++
+				}
+			}
+		}
+	}
+	if whiteKings != 1 || blackKings != 1 {
+		return nil, fmt.Errorf("invalid FEN %q: each side needs exactly one king", fen)
+	}
+
+	// A castling right needs the king and that rook still on their first squares
+	rights := []struct {
+		letter  byte
+		allowed bool
+		row     int
+		rookCol int
+		color   int
+	}{
+		{'K', g.WhiteCanCastleK, 7, 7, board.White},
+		{'Q', g.WhiteCanCastleQ, 7, 0, board.White},
+		{'k', g.BlackCanCastleK, 0, 7, board.Black},
+		{'q', g.BlackCanCastleQ, 0, 0, board.Black},
+	}
+	for _, right := range rights {
+		if !right.allowed {
+			continue
+		}
+		king := g.Board.GetPiece(right.row, 4)
+		rook := g.Board.GetPiece(right.row, right.rookCol)
+		if king != (board.Piece{Type: board.King, Color: right.color}) || rook != (board.Piece{Type: board.Rook, Color: right.color}) {
+			return nil, fmt.Errorf("invalid FEN %q: castling right %c without the king and rook on their squares", fen, right.letter)
+		}
+	}
+
+	return g, nil
+}
+
+// FEN returns the FEN string for the current position
+func (g *GameState) FEN() string {
+	var sb strings.Builder
+
+	for row := 0; row < 8; row++ {
+		empty := 0
+		for col := 0; col < 8; col++ {
+			piece := g.Board.GetPiece(row, col)
+			if piece.Type == board.Empty {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			letter := fenPieceLetters[piece.Type]
+			if piece.Color == board.White {
+				letter -= 'a' - 'A'
+			}
+			sb.WriteByte(letter)
+		}
+		if empty > 0 {
+			sb.WriteByte(byte('0' + empty))
+		}
+		if row < 7 {
+			sb.WriteByte('/')
+		}
+	}
+
+	if g.CurrentPlayer == board.White {
+		sb.WriteString(" w ")
+	} else {
+		sb.WriteString(" b ")
+	}
+
+	castling := ""
+	if g.WhiteCanCastleK {
+		castling += "K"
+	}
+	if g.WhiteCanCastleQ {
+		castling += "Q"
+	}
+	if g.BlackCanCastleK {
+		castling += "k"
+	}
+	if g.BlackCanCastleQ {
+		castling += "q"
+	}
+	if castling == "" {
+		castling = "-"
+	}
+	sb.WriteString(castling)
+
+	if g.EnPassantSquare[0] == -1 {
+		sb.WriteString(" -")
+	} else {
+		sb.WriteString(" " + squareName(g.EnPassantSquare[0], g.EnPassantSquare[1]))
+	}
+
+	fmt.Fprintf(&sb, " %d %d", g.HalfMoveClock, g.FullMoveNumber)
+	return sb.String()
+}
+
+// parseSquare converts a square name like e4 into row and column
+func parseSquare(name string) (int, int, bool) {
+	if len(name) != 2 || name[0] < 'a' || name[0] > 'h' || name[1] < '1' || name[1] > '8' {
+		return -1, -1, false
+	}
+	return 8 - int(name[1]-'0'), int(name[0] - 'a'), true
+}
+
+// squareName converts a row and column into a square name like e4
+func squareName(row, col int) string {
+	return fmt.Sprintf("%c%d", "abcdefgh"[col], 8-row)
+}
+
+func toLower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
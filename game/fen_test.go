@@ -0,0 +1,38 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFENCastlingRights(t *testing.T) {
+	tests := []struct {
+		fen string
+		err string // Empty if the FEN is accepted, and written back unchanged
+	}{
+		{StartingFEN, ""},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", ""},
+		{"4k2r/8/8/8/8/8/8/R3K3 b Qk - 3 12", ""},
+		{"4k3/8/8/8/8/8/8/4K3 w - - 0 1", ""},
+		{"4k3/8/8/8/8/8/8/4K3 w KQkq - 0 1", "castling right K"},
+		{"r3k2r/8/8/8/8/8/8/R4K1R w K - 0 1", "castling right K"},
+		{"r3k2r/8/8/8/8/8/8/1R2K2R w KQ - 0 1", "castling right Q"},
+		{"r3k1r1/8/8/8/8/8/8/R3K2R w KQk - 0 1", "castling right k"},
+		{"R3k2r/8/8/8/8/8/8/R3K2R w KQq - 0 1", "castling right q"},
+		{"r3k2r/8/8/8/8/8/8/R3K2r w KQ - 0 1", "castling right K"},
+	}
+	for _, test := range tests {
+		g, err := NewGameFromFEN(test.fen)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.fen, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.fen, err)
+		} else if got := g.FEN(); got != test.fen {
+			t.Errorf("%s: written back as %s", test.fen, got)
+		}
+	}
+}
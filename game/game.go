@@ -0,0 +1,632 @@
+// Package game tracks a game in progress: castling and en passant rights, legal moves,
+// undo and redo, FEN, SAN, PGN and clocks.
+package game
+
+import (
+	"strings"
+
+	"chess-engine/board"
+)
+
+// GameState holds the state of the chess match
+type GameState struct {
+	Board           *board.Board
+	CurrentPlayer   int
+	MoveHistory     []board.Move
+	WhiteCanCastleK bool // Kingside castling
+	WhiteCanCastleQ bool // Queenside castling
+	BlackCanCastleK bool
+	BlackCanCastleQ bool
+	EnPassantSquare [2]int // Row , Col of EnPassant target square (-1, -1) if none
+	HalfMoveClock   int    // For 50 move rule
+	FullMoveNumber  int
+
+	undoStack []undoState  // State before each move in MoveHistory
+	redoStack []board.Move // Moves taken back that can be replayed
+}
+
+// undoState holds what a move destroys and can't be worked out from the move itself
+type undoState struct {
+	WhiteCanCastleK bool
+	WhiteCanCastleQ bool
+	BlackCanCastleK bool
+	BlackCanCastleQ bool
+	EnPassantSquare [2]int
+	HalfMoveClock   int
+	FullMoveNumber  int
+}
+
+// Creates new chess game
+func NewGame() *GameState {
+	return &GameState{
+		Board:           board.NewBoard(),
+		CurrentPlayer:   board.White,
+		MoveHistory:     make([]board.Move, 0),
+		WhiteCanCastleK: true,
+		WhiteCanCastleQ: true,
+		BlackCanCastleK: true,
+		BlackCanCastleQ: true,
+		EnPassantSquare: [2]int{-1, -1},
+		HalfMoveClock:   0,
+		FullMoveNumber:  1,
+	}
+}
+
+// Create copy of game state (deep copy)
+func (g *GameState) Copy() *GameState {
+	return g.copyWith(g.Board.Copy())
+}
+
+// DetachedCopy copies the game without its board's accumulator, for testing a
+// move rather than searching it. See Board.DetachedCopy
+func (g *GameState) DetachedCopy() *GameState {
+	return g.copyWith(g.Board.DetachedCopy())
+}
+
+// copyWith copies the game onto a copy of its board
+func (g *GameState) copyWith(b *board.Board) *GameState {
+	newGame := &GameState{
+		Board:           b,
+		CurrentPlayer:   g.CurrentPlayer,
+		MoveHistory:     make([]board.Move, len(g.MoveHistory)),
+		WhiteCanCastleK: g.WhiteCanCastleK,
+		WhiteCanCastleQ: g.WhiteCanCastleQ,
+		BlackCanCastleK: g.BlackCanCastleK,
+		BlackCanCastleQ: g.BlackCanCastleQ,
+		EnPassantSquare: g.EnPassantSquare,
+		HalfMoveClock:   g.HalfMoveClock,
+		FullMoveNumber:  g.FullMoveNumber,
+	}
+	copy(newGame.MoveHistory, g.MoveHistory) // What does this do, is it an inbuilt array function?
+
+	// Capping the capacity means an append in either game copies rather than overwrites
+	newGame.undoStack = g.undoStack[:len(g.undoStack):len(g.undoStack)]
+	newGame.redoStack = g.redoStack[:len(g.redoStack):len(g.redoStack)]
+	return newGame
+}
+
+// Check to see whether castling is possible?
+func (g *GameState) CanCastle(color, side int) bool {
+	// side: 0 = kingside, 1 = queenside
+
+	// check castling rights
+	var canCastle bool
+	if color == board.White {
+		canCastle = (side == 0 && g.WhiteCanCastleK) || (side == 1 && g.WhiteCanCastleQ)
+	} else {
+		canCastle = (side == 0 && g.BlackCanCastleK) || (side == 1 && g.BlackCanCastleQ)
+	}
+
+	if !canCastle {
+		return false
+	}
+
+	// King must not be in check
+	if g.Board.IsInCheck(color) {
+		return false
+	}
+
+	// White black rank
+	row := 7
+	if color == board.Black {
+		row = 0
+	}
+
+	var squares []int
+	if side == 0 { // Kingside
+		squares = []int{5, 6} //f1 f8, g1 g8
+	} else { // Queenside
+		squares = []int{1, 2, 3} // b1 b8, c1 c8, d1 d8
+	}
+
+	// check if squares are empty
+	for _, col := range squares {
+		if g.Board.GetPiece(row, col).Type != board.Empty {
+			return false
+		}
+	}
+
+	// King cannot pass through or end up in check
+	enemyColor := board.Black
+	if color == board.Black {
+		enemyColor = board.White
+	}
+
+	checkSquares := []int{4, 5, 6} // Kings path for kingside
+	if side == 1 {                 // Queenside
+		checkSquares = []int{4, 3, 2}
+	}
+
+	for _, col := range checkSquares {
+		if g.Board.IsSquareAttacked(row, col, enemyColor) {
+			return false
+		}
+	}
+
+	return true
+
+}
+
+// Adding castling moves to move list
+
+func (g *GameState) GenerateCastlingMoves(moves *[]board.Move) {
+	color := g.CurrentPlayer
+
+	// Kingside castling
+	if g.CanCastle(color, 0) {
+		row := 7
+		if color == board.Black {
+			row = 0
+		}
+
+		move := board.Move{
+			FromRow: row, FromCol: 4,
+			ToRow: row, ToCol: 6,
+			PieceType: board.King,
+			IsCastle:  true,
+		}
+
+		*moves = append(*moves, move)
+
+	}
+
+	// Queenside castling
+	if g.CanCastle(color, 1) {
+		row := 7
+		if color == board.Black {
+			row = 0
+		}
+
+		move := board.Move{
+			FromRow: row, FromCol: 4,
+			ToRow: row, ToCol: 2,
+			PieceType: board.King,
+			IsCastle:  true,
+		}
+
+		*moves = append(*moves, move)
+	}
+
+}
+
+func (g *GameState) GenerateEnPassantMoves(moves *[]board.Move) {
+	if g.EnPassantSquare[0] == -1 {
+		return // No EnPassant possible
+	}
+
+	targetRow := g.EnPassantSquare[0]
+	targetCol := g.EnPassantSquare[1]
+	color := g.CurrentPlayer
+
+	// Find pawns that can capture enpassant
+	pawnRow := targetRow + 1
+	if color == board.Black {
+		pawnRow = targetRow - 1
+	}
+
+	// Check left and right adjacent squares
+	for _, deltaCol := range []int{-1, 1} {
+		pawnCol := targetCol + deltaCol
+		if board.IsValidSquare(pawnRow, pawnCol) {
+			piece := g.Board.GetPiece(pawnRow, pawnCol)
+			if piece.Type == board.Pawn && piece.Color == color {
+				move := board.Move{FromRow: pawnRow, FromCol: pawnCol, ToRow: targetRow, ToCol: targetCol,
+					PieceType: board.Pawn, IsEnPassant: true, IsCapture: true,
+				}
+
+				*moves = append(*moves, move)
+			}
+		}
+	}
+
+}
+
+// Generate all legal moves including special moves
+
+func (g *GameState) GenerateAllLegalMoves() []board.Move {
+	moves := g.Board.GenerateAllMoves(g.CurrentPlayer)
+
+	// Adding castling moves
+	g.GenerateCastlingMoves(&moves) // can you explain how this works. It just appends the moves
+
+	// Adding en passant moves
+	g.GenerateEnPassantMoves(&moves)
+
+	// filter legal moves
+	var legalMoves []board.Move
+	for _, move := range moves {
+		if g.IsLegalMove(move) { // check this function
+			legalMoves = append(legalMoves, move)
+		}
+	}
+
+	return legalMoves
+}
+
+// Legal move from gamestate
+
+func (g *GameState) IsLegalMove(move board.Move) bool {
+	// Make a copy of game and try the move
+
+	testGame := g.DetachedCopy()
+	testGame.MakeMoveUnchecked(move)
+
+	return !testGame.Board.IsInCheck(g.CurrentPlayer)
+}
+
+// MakeMoveUnchecked executes a move and updates the game state without checking it is legal
+func (g *GameState) MakeMoveUnchecked(move board.Move) {
+	// Handle castling
+	if move.IsCastle {
+		// Move king
+		g.Board.MakeMove(move)
+
+		// Move rook
+		row := move.FromRow
+		if move.ToCol == 6 { // Kingside
+			rookMove := board.Move{
+				FromRow: row, FromCol: 7,
+				ToRow: row, ToCol: 5,
+				PieceType: board.Rook,
+			}
+			g.Board.MakeMove(rookMove)
+		} else {
+			// Queenside castling
+			rookMove := board.Move{
+				FromRow: row, FromCol: 0,
+				ToRow: row, ToCol: 3,
+				PieceType: board.Rook,
+			}
+			g.Board.MakeMove(rookMove)
+		}
+
+		return
+
+	}
+	if move.IsEnPassant {
+		g.Board.MakeMove(move)
+
+		// Remove Captured Pawn
+		capturedRow := move.ToRow + 1
+		if g.CurrentPlayer == board.Black {
+			capturedRow = move.ToRow - 1
+		}
+		g.Board.SetPiece(capturedRow, move.ToCol, board.Piece{Type: board.Empty, Color: board.White})
+
+		return
+	}
+
+	// Regular move
+
+	g.Board.MakeMove(move)
+}
+
+// Make move exceutes move and updates whole game state
+func (g *GameState) MakeMove(move board.Move) bool {
+	// Verify move is legal
+	legalMoves := g.GenerateAllLegalMoves()
+	isLegal := false
+
+	for _, legalMove := range legalMoves {
+		if move.FromRow == legalMove.FromRow && move.FromCol == legalMove.FromCol &&
+			move.ToRow == legalMove.ToRow && move.ToCol == legalMove.ToCol &&
+			samePromotion(move, legalMove) {
+			move = legalMove
+			isLegal = true
+			break
+		}
+	}
+
+	if !isLegal {
+		return false
+	}
+
+	g.undoStack = append(g.undoStack, undoState{
+		WhiteCanCastleK: g.WhiteCanCastleK,
+		WhiteCanCastleQ: g.WhiteCanCastleQ,
+		BlackCanCastleK: g.BlackCanCastleK,
+		BlackCanCastleQ: g.BlackCanCastleQ,
+		EnPassantSquare: g.EnPassantSquare,
+		HalfMoveClock:   g.HalfMoveClock,
+		FullMoveNumber:  g.FullMoveNumber,
+	})
+	g.redoStack = nil
+
+	// Update castling rights
+	if move.PieceType == board.King {
+		if g.CurrentPlayer == board.White {
+			g.WhiteCanCastleK = false
+			g.WhiteCanCastleQ = false
+		} else {
+			g.BlackCanCastleK = false
+			g.BlackCanCastleQ = false
+		}
+
+	}
+
+	if move.PieceType == board.Rook {
+		if g.CurrentPlayer == board.White {
+			if move.FromRow == 7 && move.FromCol == 0 {
+				g.WhiteCanCastleQ = false
+			} else if move.FromRow == 7 && move.FromCol == 7 {
+				g.WhiteCanCastleK = false
+			}
+		} else {
+			if move.FromRow == 0 && move.FromCol == 0 {
+				g.BlackCanCastleQ = false
+			} else if move.FromRow == 0 && move.FromCol == 7 {
+				g.BlackCanCastleK = false
+			}
+		}
+	}
+
+	// Capturing a rook on its home square removes the opponent's castling right
+	if move.IsCapture && move.CapturedPiece.Type == board.Rook {
+		if move.ToRow == 7 && move.ToCol == 0 {
+			g.WhiteCanCastleQ = false
+		} else if move.ToRow == 7 && move.ToCol == 7 {
+			g.WhiteCanCastleK = false
+		} else if move.ToRow == 0 && move.ToCol == 0 {
+			g.BlackCanCastleQ = false
+		} else if move.ToRow == 0 && move.ToCol == 7 {
+			g.BlackCanCastleK = false
+		}
+	}
+
+	// Update Enpassant square
+	g.EnPassantSquare = [2]int{-1, -1}
+
+	if move.PieceType == board.Pawn && abs(move.ToRow-move.FromRow) == 2 {
+		// double pawn move -> set enpassant square
+		g.EnPassantSquare[0] = (move.FromRow + move.ToRow) / 2
+		g.EnPassantSquare[1] = move.FromCol
+
+	}
+
+	// Update half move clock
+	if move.PieceType == board.Pawn || move.IsCapture {
+		g.HalfMoveClock = 0
+	} else {
+		g.HalfMoveClock++
+	}
+
+	// Execute move
+	g.MakeMoveUnchecked(move)
+
+	// Update move history
+	g.MoveHistory = append(g.MoveHistory, move)
+
+	// Update current player
+	g.CurrentPlayer = 1 - g.CurrentPlayer
+
+	// update full move number
+	if g.CurrentPlayer == board.White {
+		g.FullMoveNumber++
+	} // white starts so increment move each time it's white
+
+	return true
+
+}
+
+// samePromotion reports whether a requested move matches a generated move's promotion.
+// A promotion without a piece given defaults to a queen
+func samePromotion(move, legalMove board.Move) bool {
+	if move.PromotionPiece == board.Empty {
+		return legalMove.PromotionPiece == board.Empty || legalMove.PromotionPiece == board.Queen
+	}
+	return move.PromotionPiece == legalMove.PromotionPiece
+}
+
+// UndoMove takes back the last move, restoring the full game state.
+// The move can be replayed with RedoMove until a new move is made
+func (g *GameState) UndoMove() (board.Move, bool) {
+	if len(g.MoveHistory) == 0 || len(g.undoStack) == 0 {
+		return board.Move{}, false
+	}
+
+	n := len(g.undoStack) - 1
+	move := g.MoveHistory[len(g.MoveHistory)-1]
+	state := g.undoStack[n]
+	g.MoveHistory = g.MoveHistory[:len(g.MoveHistory)-1]
+	g.undoStack = g.undoStack[:n:n] // Stacks may be shared with copies, never append in place
+
+	// The player who made the move is to move again
+	g.CurrentPlayer = 1 - g.CurrentPlayer
+	color := g.CurrentPlayer
+
+	// Put the moving piece back, undoing any promotion
+	g.Board.SetPiece(move.FromRow, move.FromCol, board.Piece{Type: move.PieceType, Color: color})
+	g.Board.SetPiece(move.ToRow, move.ToCol, board.Piece{Type: board.Empty, Color: board.White})
+
+	if move.IsCastle {
+		row := move.FromRow
+		if move.ToCol == 6 {
+			g.Board.SetPiece(row, 5, board.Piece{Type: board.Empty, Color: board.White})
+			g.Board.SetPiece(row, 7, board.Piece{Type: board.Rook, Color: color})
+		} else {
+			g.Board.SetPiece(row, 3, board.Piece{Type: board.Empty, Color: board.White})
+			g.Board.SetPiece(row, 0, board.Piece{Type: board.Rook, Color: color})
+		}
+	} else if move.IsEnPassant {
+		capturedRow := move.ToRow + 1
+		if color == board.Black {
+			capturedRow = move.ToRow - 1
+		}
+		g.Board.SetPiece(capturedRow, move.ToCol, board.Piece{Type: board.Pawn, Color: 1 - color})
+	} else if move.IsCapture {
+		g.Board.SetPiece(move.ToRow, move.ToCol, move.CapturedPiece)
+	}
+
+	g.WhiteCanCastleK = state.WhiteCanCastleK
+	g.WhiteCanCastleQ = state.WhiteCanCastleQ
+	g.BlackCanCastleK = state.BlackCanCastleK
+	g.BlackCanCastleQ = state.BlackCanCastleQ
+	g.EnPassantSquare = state.EnPassantSquare
+	g.HalfMoveClock = state.HalfMoveClock
+	g.FullMoveNumber = state.FullMoveNumber
+
+	g.redoStack = append(g.redoStack, move)
+	return move, true
+}
+
+// RedoMove replays the last move taken back with UndoMove
+func (g *GameState) RedoMove() (board.Move, bool) {
+	if len(g.redoStack) == 0 {
+		return board.Move{}, false
+	}
+
+	n := len(g.redoStack) - 1
+	move := g.redoStack[n]
+	remaining := g.redoStack[:n:n]
+
+	if !g.MakeMove(move) {
+		return board.Move{}, false
+	}
+	g.redoStack = remaining // MakeMove clears the redo stack
+	return move, true
+}
+
+// InitialPosition returns a copy of the game with every move taken back
+func (g *GameState) InitialPosition() *GameState {
+	start := g.Copy()
+	for {
+		if _, ok := start.UndoMove(); !ok {
+			break
+		}
+	}
+	start.redoStack = nil
+	return start
+}
+
+// HistorySAN returns the moves played so far in standard algebraic notation
+func (g *GameState) HistorySAN() []string {
+	start := g.InitialPosition()
+	sans := make([]string, 0, len(g.MoveHistory))
+	for _, move := range g.MoveHistory {
+		sans = append(sans, start.MoveToSAN(move))
+		start.MakeMove(move)
+	}
+	return sans
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
+
+// Is Over checks if game has ended
+
+func (g *GameState) IsGameOver() (bool, string) {
+	legalMoves := g.GenerateAllLegalMoves()
+
+	if len(legalMoves) == 0 {
+		if g.Board.IsInCheck(g.CurrentPlayer) {
+			// Checkmate
+			winner := "White"
+			if g.CurrentPlayer == board.White {
+				winner = "Black"
+			}
+			return true, winner + " wins by checkmate!"
+		} else {
+			// Stalemate
+			return true, "Draw by stalemate."
+		}
+	}
+
+	// 50 mve rule
+	if g.HalfMoveClock >= 100 { // 50 moves each
+		return true, "Draw by 50 move rule"
+	}
+
+	return false, ""
+
+}
+
+// Return current player as string
+
+func (g *GameState) GetCurrentPlayerString() string {
+	if g.CurrentPlayer == board.White {
+		return "White"
+	}
+
+	return "Black"
+}
+
+// Parse move converts algebraic notation to a Move struct
+func (g *GameState) ParseMove(notation string) (board.Move, bool) {
+	notation = strings.TrimSpace(notation)
+
+	if notation == "O-O" || notation == "0-0" {
+		// kingside castling
+		row := 7
+		if g.CurrentPlayer == board.Black {
+			row = 0
+		}
+
+		return board.Move{
+			FromRow: row, FromCol: 4,
+			ToRow: row, ToCol: 6,
+			PieceType: board.King,
+			IsCastle:  true,
+		}, true
+	}
+	if notation == "O-O-O" || notation == "0-0-0" {
+		// Queenside castling
+		row := 7
+		if g.CurrentPlayer == board.Black {
+			row = 0
+		}
+
+		return board.Move{
+			FromRow: row, FromCol: 4,
+			ToRow: row, ToCol: 2,
+			PieceType: board.King,
+			IsCastle:  true,
+		}, true
+	}
+
+	// Simple format e2e4
+
+	if len(notation) >= 4 {
+		files := "abcdefgh"
+
+		fromCol := strings.IndexByte(files, notation[0])
+		fromRow := 8 - int(notation[1]-'0') // What does this mean?
+		toCol := strings.IndexByte(files, notation[2])
+		toRow := 8 - int(notation[3]-'0')
+
+		if fromCol >= 0 && fromRow >= 0 && fromRow < 8 && toCol >= 0 &&
+			toRow >= 0 && toRow < 8 {
+			piece := g.Board.GetPiece(fromRow, fromCol)
+			move := board.Move{
+				FromRow: fromRow, FromCol: fromCol,
+				ToRow: toRow, ToCol: toCol,
+				PieceType: piece.Type,
+			}
+
+			// Check promotion
+
+			if len(notation) >= 5 && piece.Type == board.Pawn {
+				switch notation[4] {
+				case 'q', 'Q':
+					move.PromotionPiece = board.Queen
+				case 'r', 'R':
+					move.PromotionPiece = board.Rook
+				case 'n', 'N':
+					move.PromotionPiece = board.Knight
+				case 'b', 'B':
+					move.PromotionPiece = board.Bishop
+				}
+			}
+
+			return move, true
+		}
+
+	}
+
+	return board.Move{}, false
+
+}
@@ -0,0 +1,87 @@
+package game
+
+import "testing"
+
+// snapshot is everything undo must put back, beyond the squares in the FEN
+type snapshot struct {
+	fen                                    string
+	castleWK, castleWQ, castleBK, castleBQ bool
+	enPassant                              [2]int
+	halfMove, fullMove, history            int
+}
+
+func takeSnapshot(g *GameState) snapshot {
+	return snapshot{
+		fen:       g.FEN(),
+		castleWK:  g.WhiteCanCastleK,
+		castleWQ:  g.WhiteCanCastleQ,
+		castleBK:  g.BlackCanCastleK,
+		castleBQ:  g.BlackCanCastleQ,
+		enPassant: g.EnPassantSquare,
+		halfMove:  g.HalfMoveClock,
+		fullMove:  g.FullMoveNumber,
+		history:   len(g.MoveHistory),
+	}
+}
+
+// TestUndoRedo plays through en passant, castling, a capturing promotion, a
+// double pawn push and a rook capture, then takes every move back and replays it
+func TestUndoRedo(t *testing.T) {
+	g, err := NewGameFromFEN("r3k2r/1P5p/8/3pP3/8/8/8/R3K2R w KQkq d6 0 20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves := []string{"e5d6", "e8g8", "b7a8q", "g8g7", "e1c1", "h7h5", "h1h5"}
+
+	states := []snapshot{takeSnapshot(g)}
+	for _, text := range moves {
+		move, ok := g.ParseMove(text)
+		if !ok || !g.MakeMove(move) {
+			t.Fatalf("%s isn't legal in %s", text, g.FEN())
+		}
+		states = append(states, takeSnapshot(g))
+	}
+	if got := states[len(states)-1].fen; got != "Q4r2/6k1/3P4/7R/8/8/8/2KR4 b - - 0 23" {
+		t.Fatalf("played out to %s", got)
+	}
+
+	for i := len(moves) - 1; i >= 0; i-- {
+		move, ok := g.UndoMove()
+		if !ok || move.String() != moves[i][:4] {
+			t.Fatalf("undo %d took back %s, %v, want %s", i+1, move.String(), ok, moves[i])
+		}
+		if got := takeSnapshot(g); got != states[i] {
+			t.Errorf("after taking back %s: %+v, want %+v", moves[i], got, states[i])
+		}
+	}
+	if _, ok := g.UndoMove(); ok {
+		t.Error("took back a move before the starting position")
+	}
+
+	for i := range moves {
+		move, ok := g.RedoMove()
+		if !ok || move.String() != moves[i][:4] {
+			t.Fatalf("redo %d replayed %s, %v, want %s", i+1, move.String(), ok, moves[i])
+		}
+		if got := takeSnapshot(g); got != states[i+1] {
+			t.Errorf("after replaying %s: %+v, want %+v", moves[i], got, states[i+1])
+		}
+	}
+	if _, ok := g.RedoMove(); ok {
+		t.Error("replayed a move that was never taken back")
+	}
+
+	// A new move in place of one taken back leaves nothing to replay
+	g.UndoMove()
+	g.UndoMove()
+	move, _ := g.ParseMove("g7g6")
+	if !g.MakeMove(move) {
+		t.Fatal("g7g6 isn't legal")
+	}
+	if move, ok := g.RedoMove(); ok {
+		t.Errorf("replayed %s after a new move", move.String())
+	}
+	if got := takeSnapshot(g); got.history != 6 || got.fen != "Q4r2/7p/3P2k1/8/8/8/8/2KR3R w - - 3 23" {
+		t.Errorf("after a new move: %+v", got)
+	}
+}
@@ -0,0 +1,53 @@
+package game
+
+import "testing"
+
+// perft counts the leaf positions of the legal move tree to depth
+func perft(g *GameState, depth int) int {
+	moves := g.GenerateAllLegalMoves()
+	if depth == 1 {
+		return len(moves)
+	}
+	nodes := 0
+	for _, move := range moves {
+		next := g.Copy()
+		next.MakeMove(move)
+		nodes += perft(next, depth-1)
+	}
+	return nodes
+}
+
+// The counts are the standard ones from the Chess Programming Wiki's perft
+// results page. Each position stresses a different part of move generation
+func TestPerft(t *testing.T) {
+	tests := []struct {
+		name   string
+		fen    string
+		counts []int // From depth 1
+	}{
+		{"start", StartingFEN, []int{20, 400, 8902, 197281}},
+		{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+			[]int{48, 2039, 97862}},
+		{"en passant and pins", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", []int{14, 191, 2812, 43238}},
+		{"promotions and castling", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+			[]int{6, 264, 9467}},
+		{"underpromotion", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", []int{44, 1486, 62379}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, want := range test.counts {
+				depth := i + 1
+				if testing.Short() && depth > 2 {
+					break
+				}
+				g, err := NewGameFromFEN(test.fen)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if nodes := perft(g, depth); nodes != want {
+					t.Errorf("depth %d: %d nodes, want %d", depth, nodes, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,561 @@
+package game
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"chess-engine/board"
+)
+
+// PGN token kinds
+const (
+	pgnTokenEOF = iota
+	pgnTokenTagOpen
+	pgnTokenTagClose
+	pgnTokenString
+	pgnTokenSymbol
+	pgnTokenPeriod
+	pgnTokenComment
+	pgnTokenNAG
+	pgnTokenVariationOpen
+	pgnTokenVariationClose
+	pgnTokenResult
+)
+
+// Suffix annotations and the NAGs they stand for
+var pgnSuffixNAGs = map[string]int{
+	"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6,
+}
+
+// PGNError reports a problem at a position in a PGN file
+type PGNError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *PGNError) Error() string {
+	return fmt.Sprintf("pgn:%d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+type pgnToken struct {
+	Kind   int
+	Text   string
+	Line   int
+	Column int
+}
+
+// pgnLexer splits a PGN stream into tokens, tracking line and column
+type pgnLexer struct {
+	r       *bufio.Reader
+	line    int
+	column  int
+	peeked  *pgnToken
+	lastErr error
+}
+
+func newPGNLexer(r io.Reader) *pgnLexer {
+	return &pgnLexer{r: bufio.NewReaderSize(r, 64*1024), line: 1}
+}
+
+func (l *pgnLexer) readByte() (byte, bool) {
+	c, err := l.r.ReadByte()
+	if err != nil {
+		if err != io.EOF {
+			l.lastErr = err
+		}
+		return 0, false
+	}
+	if c == '\n' {
+		l.line++
+		l.column = 0
+	} else {
+		l.column++
+	}
+	return c, true
+}
+
+func (l *pgnLexer) peekByte() (byte, bool) {
+	b, err := l.r.Peek(1)
+	if err != nil {
+		return 0, false
+	}
+	return b[0], true
+}
+
+func (l *pgnLexer) errorf(line, column int, format string, args ...interface{}) *PGNError {
+	return &PGNError{Line: line, Column: column, Msg: fmt.Sprintf(format, args...)}
+}
+
+// peek returns the next token without consuming it
+func (l *pgnLexer) peek() (pgnToken, error) {
+	if l.peeked == nil {
+		tok, err := l.scan()
+		if err != nil {
+			return tok, err
+		}
+		l.peeked = &tok
+	}
+	return *l.peeked, nil
+}
+
+// next consumes and returns the next token
+func (l *pgnLexer) next() (pgnToken, error) {
+	if l.peeked != nil {
+		tok := *l.peeked
+		l.peeked = nil
+		return tok, nil
+	}
+	return l.scan()
+}
+
+func isPGNSymbolByte(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+		strings.IndexByte("_+#=:-/", c) >= 0
+}
+
+func (l *pgnLexer) scan() (pgnToken, error) {
+	for {
+		c, ok := l.readByte()
+		if !ok {
+			if l.lastErr != nil {
+				return pgnToken{}, l.lastErr
+			}
+			return pgnToken{Kind: pgnTokenEOF, Line: l.line, Column: l.column}, nil
+		}
+		line, column := l.line, l.column
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			continue
+
+		case c == '%' && column == 1:
+			// Escape mechanism: the rest of the line is ignored
+			l.skipLine()
+			continue
+
+		case c == ';':
+			text := l.readLine()
+			return pgnToken{Kind: pgnTokenComment, Text: strings.TrimSpace(text), Line: line, Column: column}, nil
+
+		case c == '{':
+			var sb strings.Builder
+			for {
+				c, ok := l.readByte()
+				if !ok {
+					return pgnToken{}, l.errorf(line, column, "unterminated comment")
+				}
+				if c == '}' {
+					break
+				}
+				sb.WriteByte(c)
+			}
+			text := strings.Join(strings.Fields(sb.String()), " ")
+			return pgnToken{Kind: pgnTokenComment, Text: text, Line: line, Column: column}, nil
+
+		case c == '"':
+			var sb strings.Builder
+			for {
+				c, ok := l.readByte()
+				if !ok || c == '\n' {
+					return pgnToken{}, l.errorf(line, column, "unterminated string")
+				}
+				if c == '\\' {
+					escaped, ok := l.readByte()
+					if !ok {
+						return pgnToken{}, l.errorf(line, column, "unterminated string")
+					}
+					sb.WriteByte(escaped)
+					continue
+				}
+				if c == '"' {
+					break
+				}
+				sb.WriteByte(c)
+			}
+			return pgnToken{Kind: pgnTokenString, Text: sb.String(), Line: line, Column: column}, nil
+
+		case c == '[':
+			return pgnToken{Kind: pgnTokenTagOpen, Text: "[", Line: line, Column: column}, nil
+		case c == ']':
+			return pgnToken{Kind: pgnTokenTagClose, Text: "]", Line: line, Column: column}, nil
+		case c == '(':
+			return pgnToken{Kind: pgnTokenVariationOpen, Text: "(", Line: line, Column: column}, nil
+		case c == ')':
+			return pgnToken{Kind: pgnTokenVariationClose, Text: ")", Line: line, Column: column}, nil
+		case c == '.':
+			return pgnToken{Kind: pgnTokenPeriod, Text: ".", Line: line, Column: column}, nil
+		case c == '*':
+			return pgnToken{Kind: pgnTokenResult, Text: "*", Line: line, Column: column}, nil
+
+		case c == '$':
+			digits := ""
+			for {
+				d, ok := l.peekByte()
+				if !ok || d < '0' || d > '9' {
+					break
+				}
+				l.readByte()
+				digits += string(d)
+			}
+			if digits == "" {
+				return pgnToken{}, l.errorf(line, column, "NAG without a number")
+			}
+			return pgnToken{Kind: pgnTokenNAG, Text: digits, Line: line, Column: column}, nil
+
+		case c == '!' || c == '?':
+			text := string(c)
+			if d, ok := l.peekByte(); ok && (d == '!' || d == '?') {
+				l.readByte()
+				text += string(d)
+			}
+			nag, ok := pgnSuffixNAGs[text]
+			if !ok {
+				return pgnToken{}, l.errorf(line, column, "unknown annotation %q", text)
+			}
+			return pgnToken{Kind: pgnTokenNAG, Text: strconv.Itoa(nag), Line: line, Column: column}, nil
+
+		case isPGNSymbolByte(c):
+			text := string(c)
+			for {
+				d, ok := l.peekByte()
+				if !ok || !isPGNSymbolByte(d) {
+					break
+				}
+				l.readByte()
+				text += string(d)
+			}
+			kind := pgnTokenSymbol
+			if text == "1-0" || text == "0-1" || text == "1/2-1/2" {
+				kind = pgnTokenResult
+			}
+			return pgnToken{Kind: kind, Text: text, Line: line, Column: column}, nil
+
+		default:
+			return pgnToken{}, l.errorf(line, column, "unexpected character %q", c)
+		}
+	}
+}
+
+func (l *pgnLexer) readLine() string {
+	var sb strings.Builder
+	for {
+		c, ok := l.peekByte()
+		if !ok || c == '\n' {
+			return sb.String()
+		}
+		l.readByte()
+		sb.WriteByte(c)
+	}
+}
+
+func (l *pgnLexer) skipLine() {
+	l.readLine()
+}
+
+// PGNTag is a single tag pair from the header of a game
+type PGNTag struct {
+	Name  string
+	Value string
+}
+
+// PGNMove is one move of a game along with its annotations and alternatives
+type PGNMove struct {
+	Move       board.Move
+	SAN        string
+	NAGs       []int
+	Comment    string
+	Variations []*PGNVariation // Alternatives to this move, played from the position before it
+}
+
+// PGNVariation is an alternative line to a move
+type PGNVariation struct {
+	Comment string // Comment before the first move of the line
+	Moves   []*PGNMove
+}
+
+// PGNGame is a parsed game from a PGN file
+type PGNGame struct {
+	Tags     []PGNTag
+	Comment  string // Comment before the first move
+	Moves    []*PGNMove
+	Result   string
+	StartFEN string
+	Line     int // Line the game starts on
+}
+
+// Tag returns the value of a tag, or "" if the game doesn't have it
+func (pg *PGNGame) Tag(name string) string {
+	for _, tag := range pg.Tags {
+		if tag.Name == name {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// StartingPosition returns the position the game starts from
+func (pg *PGNGame) StartingPosition() (*GameState, error) {
+	if pg.StartFEN == "" {
+		return NewGame(), nil
+	}
+	return NewGameFromFEN(pg.StartFEN)
+}
+
+// GameState replays the main line and returns the final position
+func (pg *PGNGame) GameState() (*GameState, error) {
+	game, err := pg.StartingPosition()
+	if err != nil {
+		return nil, err
+	}
+	for _, pm := range pg.Moves {
+		if !game.MakeMove(pm.Move) {
+			return nil, fmt.Errorf("illegal move %s", pm.SAN)
+		}
+	}
+	return game, nil
+}
+
+// PGNReader reads games one at a time from a PGN stream, so files of any size
+// can be processed without loading them into memory
+type PGNReader struct {
+	lexer *pgnLexer
+}
+
+// NewPGNReader creates a reader over a PGN stream
+func NewPGNReader(r io.Reader) *PGNReader {
+	return &PGNReader{lexer: newPGNLexer(r)}
+}
+
+// Next returns the next game in the stream, or io.EOF when there are none left.
+// A malformed game returns a *PGNError; the reader then skips ahead so Next can
+// be called again to carry on with the following game.
+func (pr *PGNReader) Next() (*PGNGame, error) {
+	tok, err := pr.lexer.peek()
+	if err != nil {
+		pr.resync()
+		return nil, err
+	}
+	if tok.Kind == pgnTokenEOF {
+		return nil, io.EOF
+	}
+
+	pg, err := pr.readGame()
+	if err != nil {
+		var pgnErr *PGNError
+		if errors.As(err, &pgnErr) {
+			pr.resync()
+		}
+		return nil, err
+	}
+	return pg, nil
+}
+
+// resync skips tokens until the start of the next game's tag section
+func (pr *PGNReader) resync() {
+	for {
+		tok, err := pr.lexer.peek()
+		if err != nil {
+			// Drop the bad token and keep looking
+			if _, ok := err.(*PGNError); !ok {
+				return
+			}
+			pr.lexer.peeked = nil
+			continue
+		}
+		if tok.Kind == pgnTokenEOF || (tok.Kind == pgnTokenTagOpen && tok.Column == 1) {
+			return
+		}
+		pr.lexer.next()
+	}
+}
+
+func (pr *PGNReader) readGame() (*PGNGame, error) {
+	lexer := pr.lexer
+	first, _ := lexer.peek()
+	pg := &PGNGame{Line: first.Line}
+
+	// Tag pair section
+	for {
+		tok, err := lexer.peek()
+		if err != nil {
+			return nil, err
+		}
+		if tok.Kind != pgnTokenTagOpen {
+			break
+		}
+		lexer.next()
+
+		name, err := lexer.next()
+		if err != nil {
+			return nil, err
+		}
+		if name.Kind != pgnTokenSymbol {
+			return nil, lexer.errorf(name.Line, name.Column, "expected tag name, got %q", name.Text)
+		}
+		value, err := lexer.next()
+		if err != nil {
+			return nil, err
+		}
+		if value.Kind != pgnTokenString {
+			return nil, lexer.errorf(value.Line, value.Column, "expected tag value for %s", name.Text)
+		}
+		closing, err := lexer.next()
+		if err != nil {
+			return nil, err
+		}
+		if closing.Kind != pgnTokenTagClose {
+			return nil, lexer.errorf(closing.Line, closing.Column, "expected ] after tag %s", name.Text)
+		}
+		pg.Tags = append(pg.Tags, PGNTag{Name: name.Text, Value: value.Text})
+	}
+
+	pg.StartFEN = pg.Tag("FEN")
+	game, err := pg.StartingPosition()
+	if err != nil {
+		return nil, lexer.errorf(pg.Line, 1, "%v", err)
+	}
+
+	moves, result, err := pr.readMoves(game, &pg.Comment, 0)
+	if err != nil {
+		return nil, err
+	}
+	pg.Moves = moves
+	pg.Result = result
+	if pg.Result == "" {
+		pg.Result = pg.Tag("Result")
+	}
+	return pg, nil
+}
+
+// readMoves parses a line of movetext starting from game, recursing into variations.
+// It stops at the game result, the end of a variation, or the end of the stream.
+func (pr *PGNReader) readMoves(game *GameState, leadingComment *string, depth int) ([]*PGNMove, string, error) {
+	lexer := pr.lexer
+	var moves []*PGNMove
+	var before *GameState // Position before the last move, for variations
+
+	addComment := func(text string) {
+		target := leadingComment
+		if len(moves) > 0 {
+			target = &moves[len(moves)-1].Comment
+		}
+		if *target != "" {
+			*target += " "
+		}
+		*target += text
+	}
+
+	for {
+		tok, err := lexer.peek()
+		if err != nil {
+			return nil, "", err
+		}
+
+		switch tok.Kind {
+		case pgnTokenEOF:
+			if depth > 0 {
+				return nil, "", lexer.errorf(tok.Line, tok.Column, "unterminated variation")
+			}
+			return moves, "", nil
+
+		case pgnTokenTagOpen:
+			if depth > 0 {
+				return nil, "", lexer.errorf(tok.Line, tok.Column, "unterminated variation")
+			}
+			// Next game started without a result marker
+			return moves, "", nil
+
+		case pgnTokenResult:
+			lexer.next()
+			if depth > 0 {
+				return nil, "", lexer.errorf(tok.Line, tok.Column, "game result inside a variation")
+			}
+			return moves, tok.Text, nil
+
+		case pgnTokenVariationClose:
+			if depth == 0 {
+				return nil, "", lexer.errorf(tok.Line, tok.Column, "unexpected )")
+			}
+			lexer.next()
+			return moves, "", nil
+
+		case pgnTokenVariationOpen:
+			lexer.next()
+			if len(moves) == 0 {
+				return nil, "", lexer.errorf(tok.Line, tok.Column, "variation before any move")
+			}
+			variation := &PGNVariation{}
+			variation.Moves, _, err = pr.readMoves(before.Copy(), &variation.Comment, depth+1)
+			if err != nil {
+				return nil, "", err
+			}
+			last := moves[len(moves)-1]
+			last.Variations = append(last.Variations, variation)
+
+		case pgnTokenComment:
+			lexer.next()
+			addComment(tok.Text)
+
+		case pgnTokenNAG:
+			lexer.next()
+			if len(moves) == 0 {
+				return nil, "", lexer.errorf(tok.Line, tok.Column, "annotation before any move")
+			}
+			nag, _ := strconv.Atoi(tok.Text)
+			last := moves[len(moves)-1]
+			last.NAGs = append(last.NAGs, nag)
+
+		case pgnTokenPeriod:
+			lexer.next()
+
+		case pgnTokenSymbol:
+			lexer.next()
+			if isMoveNumber(tok.Text) {
+				continue
+			}
+			move, err := game.ParseSAN(tok.Text)
+			if err != nil {
+				return nil, "", lexer.errorf(tok.Line, tok.Column, "%v", err)
+			}
+			san := game.MoveToSAN(move)
+			before = game.Copy()
+			game.MakeMove(move)
+			moves = append(moves, &PGNMove{Move: move, SAN: san})
+
+		default:
+			lexer.next()
+			return nil, "", lexer.errorf(tok.Line, tok.Column, "unexpected %q in movetext", tok.Text)
+		}
+	}
+}
+
+func isMoveNumber(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] < '0' || text[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadPGNGame reads the game with the given 1-based index from a PGN stream
+func LoadPGNGame(r io.Reader, index int) (*PGNGame, error) {
+	reader := NewPGNReader(r)
+	for n := 1; ; n++ {
+		pg, err := reader.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("file has only %d games", n-1)
+		}
+		if n == index {
+			return pg, err
+		}
+		if _, ok := err.(*PGNError); err != nil && !ok {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package game
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPGNVariationComments(t *testing.T) {
+	text := `[Event "Test"]
+
+{Opening} 1. e4 {King's pawn} ({Or} 1. d4 {Queen's pawn} 1... d5) ({Also} 1. c4) 1... e5 *
+`
+	pg, err := NewPGNReader(strings.NewReader(text)).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pg.Comment != "Opening" {
+		t.Errorf("game comment %q, want %q", pg.Comment, "Opening")
+	}
+	if len(pg.Moves) != 2 {
+		t.Fatalf("got %d moves, want 2", len(pg.Moves))
+	}
+
+	e4 := pg.Moves[0]
+	if e4.Comment != "King's pawn" {
+		t.Errorf("e4 comment %q, want %q", e4.Comment, "King's pawn")
+	}
+	if len(e4.Variations) != 2 {
+		t.Fatalf("got %d variations, want 2", len(e4.Variations))
+	}
+	first, second := e4.Variations[0], e4.Variations[1]
+	if first.Comment != "Or" || second.Comment != "Also" {
+		t.Errorf("variation comments %q and %q, want %q and %q", first.Comment, second.Comment, "Or", "Also")
+	}
+	if len(first.Moves) != 2 || first.Moves[0].SAN != "d4" || first.Moves[0].Comment != "Queen's pawn" {
+		t.Errorf("first variation read wrongly: %+v", first.Moves)
+	}
+	if len(second.Moves) != 1 || second.Moves[0].SAN != "c4" {
+		t.Errorf("second variation read wrongly: %+v", second.Moves)
+	}
+}
+
+func TestPGNErrorPositions(t *testing.T) {
+	tests := []struct {
+		name         string
+		movetext     string
+		line, column int
+	}{
+		{"illegal move", "1. e4 e5 2. Ke3 *", 3, 13},
+		{"bad move on a later line", "1. e4 e5\n2. Nf3 Nc6\n3. Bb5 Qxz9 *", 5, 8},
+		{"unexpected close", "1. e4 ) *", 3, 7},
+		{"variation before any move", "( 1. e4 ) *", 3, 1},
+		{"result inside a variation", "1. e4 (1. d4 1-0) *", 3, 14},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The bad game is followed by a good one the reader must still find
+			text := "[Event \"Bad\"]\n\n" + test.movetext + "\n\n[Event \"Good\"]\n\n1. d4 d5 *\n"
+			reader := NewPGNReader(strings.NewReader(text))
+
+			_, err := reader.Next()
+			var pgnErr *PGNError
+			if !errors.As(err, &pgnErr) {
+				t.Fatalf("got %v, want a *PGNError", err)
+			}
+			if pgnErr.Line != test.line || pgnErr.Column != test.column {
+				t.Errorf("error at %d:%d, want %d:%d (%v)", pgnErr.Line, pgnErr.Column, test.line, test.column, err)
+			}
+
+			pg, err := reader.Next()
+			if err != nil {
+				t.Fatalf("game after the bad one: %v", err)
+			}
+			if pg.Tag("Event") != "Good" || len(pg.Moves) != 2 {
+				t.Errorf("game after the bad one read wrongly: %+v", pg)
+			}
+			if _, err := reader.Next(); err != io.EOF {
+				t.Errorf("got %v after the last game, want io.EOF", err)
+			}
+		})
+	}
+}
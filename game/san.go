@@ -0,0 +1,170 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+
+	"chess-engine/board"
+)
+
+var sanPieceLetters = map[int]string{
+	board.Pawn: "", board.Knight: "N", board.Bishop: "B", board.Rook: "R", board.Queen: "Q", board.King: "K",
+}
+
+var sanPieceTypes = map[byte]int{
+	'N': board.Knight, 'B': board.Bishop, 'R': board.Rook, 'Q': board.Queen, 'K': board.King,
+}
+
+// MoveToSAN returns the standard algebraic notation for a legal move in this position
+func (g *GameState) MoveToSAN(move board.Move) string {
+	var san string
+
+	if move.IsCastle {
+		san = "O-O"
+		if move.ToCol == 2 {
+			san = "O-O-O"
+		}
+	} else {
+		var sb strings.Builder
+		sb.WriteString(sanPieceLetters[move.PieceType])
+
+		if move.PieceType == board.Pawn {
+			if move.IsCapture {
+				sb.WriteByte("abcdefgh"[move.FromCol])
+			}
+		} else {
+			// Disambiguate between identical pieces that can reach the same square
+			sameFile, sameRank, ambiguous := false, false, false
+			for _, other := range g.GenerateAllLegalMoves() {
+				if other.PieceType != move.PieceType || other.ToRow != move.ToRow || other.ToCol != move.ToCol {
+					continue
+				}
+				if other.FromRow == move.FromRow && other.FromCol == move.FromCol {
+					continue
+				}
+				ambiguous = true
+				if other.FromCol == move.FromCol {
+					sameFile = true
+				}
+				if other.FromRow == move.FromRow {
+					sameRank = true
+				}
+			}
+			if ambiguous {
+				if !sameFile {
+					sb.WriteByte("abcdefgh"[move.FromCol])
+				} else if !sameRank {
+					sb.WriteByte(byte('0' + 8 - move.FromRow))
+				} else {
+					sb.WriteString(squareName(move.FromRow, move.FromCol))
+				}
+			}
+		}
+
+		if move.IsCapture {
+			sb.WriteByte('x')
+		}
+		sb.WriteString(squareName(move.ToRow, move.ToCol))
+
+		if move.PromotionPiece != board.Empty {
+			sb.WriteString("=" + sanPieceLetters[move.PromotionPiece])
+		}
+		san = sb.String()
+	}
+
+	// Check and checkmate suffixes
+	after := g.DetachedCopy()
+	if after.MakeMove(move) && after.Board.IsInCheck(after.CurrentPlayer) {
+		if len(after.GenerateAllLegalMoves()) == 0 {
+			san += "#"
+		} else {
+			san += "+"
+		}
+	}
+
+	return san
+}
+
+// ParseSAN converts standard algebraic notation into a legal move for this position
+func (g *GameState) ParseSAN(san string) (board.Move, error) {
+	text := strings.TrimRight(strings.TrimSpace(san), "+#!?")
+	if text == "" {
+		return board.Move{}, fmt.Errorf("empty move")
+	}
+
+	legalMoves := g.GenerateAllLegalMoves()
+
+	// Castling, accepting zeros as well as letters
+	castle := strings.ReplaceAll(text, "0", "O")
+	if castle == "O-O" || castle == "O-O-O" {
+		toCol := 6
+		if castle == "O-O-O" {
+			toCol = 2
+		}
+		for _, move := range legalMoves {
+			if move.IsCastle && move.ToCol == toCol {
+				return move, nil
+			}
+		}
+		return board.Move{}, fmt.Errorf("illegal move %q: castling not allowed", san)
+	}
+
+	pieceType := board.Pawn
+	if pt, ok := sanPieceTypes[text[0]]; ok {
+		pieceType = pt
+		text = text[1:]
+	}
+
+	// Promotion suffix, either e8=Q or e8Q
+	promotion := board.Empty
+	if n := len(text); n >= 2 {
+		if pt, ok := sanPieceTypes[text[n-1]]; ok && pt != board.King {
+			promotion = pt
+			text = strings.TrimSuffix(text[:n-1], "=")
+		}
+	}
+
+	if len(text) < 2 {
+		return board.Move{}, fmt.Errorf("invalid move %q", san)
+	}
+	toRow, toCol, ok := parseSquare(text[len(text)-2:])
+	if !ok {
+		return board.Move{}, fmt.Errorf("invalid move %q: bad destination square", san)
+	}
+
+	// Whatever is left over is disambiguation and an optional capture mark
+	fromRow, fromCol := -1, -1
+	for _, c := range []byte(strings.Replace(text[:len(text)-2], "x", "", 1)) {
+		switch {
+		case c >= 'a' && c <= 'h':
+			fromCol = int(c - 'a')
+		case c >= '1' && c <= '8':
+			fromRow = 8 - int(c-'0')
+		default:
+			return board.Move{}, fmt.Errorf("invalid move %q", san)
+		}
+	}
+
+	var matches []board.Move
+	for _, move := range legalMoves {
+		if move.IsCastle || move.PieceType != pieceType || move.ToRow != toRow || move.ToCol != toCol {
+			continue
+		}
+		if (fromRow != -1 && move.FromRow != fromRow) || (fromCol != -1 && move.FromCol != fromCol) {
+			continue
+		}
+		if move.PromotionPiece != promotion {
+			continue
+		}
+		matches = append(matches, move)
+	}
+
+	switch len(matches) {
+	case 0:
+		return board.Move{}, fmt.Errorf("illegal move %q", san)
+	case 1:
+		return matches[0], nil
+	default:
+		return board.Move{}, fmt.Errorf("ambiguous move %q", san)
+	}
+}
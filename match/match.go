@@ -0,0 +1,231 @@
+// Package match plays the engine against an external UCI engine, checking
+// every move the other engine makes with our own move generation.
+package match
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"chess-engine/board"
+	"chess-engine/game"
+	"chess-engine/search"
+	"chess-engine/uci"
+)
+
+// Options set up a match
+type Options struct {
+	Games    int              // Games to play, alternating colors, our engine white first
+	FEN      string           // Position every game starts from, empty for the starting position
+	Control  game.TimeControl // Clock for both sides; a zero Base plays by MoveTime instead
+	MoveTime time.Duration    // Time per move when there's no clock
+	MaxPlies int              // Games still going after this many plies are drawn, 0 for no limit
+}
+
+// moveTimeGrace is how much longer than its time the opponent may take to
+// answer before it loses the game
+const moveTimeGrace = 5 * time.Second
+
+// GameResult is how one game of a match ended
+type GameResult struct {
+	Number      int     // From 1
+	EngineColor int     // Color our engine played
+	Score       float64 // For our engine: 1 for a win, 0.5 for a draw, 0 for a loss
+	Reason      string
+	Moves       []string // SAN
+}
+
+// Stats tally a match from our engine's point of view
+type Stats struct {
+	Wins   int
+	Draws  int
+	Losses int
+}
+
+// Games is the number of games played
+func (s Stats) Games() int {
+	return s.Wins + s.Draws + s.Losses
+}
+
+// Score is the share of the points our engine won, from 0 to 1
+func (s Stats) Score() float64 {
+	if s.Games() == 0 {
+		return 0
+	}
+	return (float64(s.Wins) + float64(s.Draws)/2) / float64(s.Games())
+}
+
+// EloDifference estimates how much stronger our engine is from the score.
+// There's no estimate once one side has won every point
+func (s Stats) EloDifference() (float64, bool) {
+	score := s.Score()
+	switch {
+	case score <= 0 || score >= 1:
+		return 0, false
+	case score == 0.5:
+		return 0, true // Rather than -0
+	}
+	return -400 * math.Log10(1/score-1), true
+}
+
+// String shows the tally
+func (s Stats) String() string {
+	text := fmt.Sprintf("+%d -%d =%d (%.1f%%)", s.Wins, s.Losses, s.Draws, s.Score()*100)
+	if elo, ok := s.EloDifference(); ok {
+		text += fmt.Sprintf(", Elo difference %+.0f", elo)
+	}
+	return text
+}
+
+// Run plays a match between engine and opponent, passing each game to
+// progress as it ends. It stops early only if the opponent stops working
+func Run(engine *search.Engine, opponent *uci.Client, options Options, progress func(GameResult, Stats)) (Stats, error) {
+	var stats Stats
+	for number := 1; number <= options.Games; number++ {
+		color := board.White
+		if number%2 == 0 {
+			color = board.Black
+		}
+
+		result, err := playGame(engine, opponent, options, color)
+		if err != nil {
+			return stats, fmt.Errorf("game %d: %v", number, err)
+		}
+		result.Number = number
+
+		switch result.Score {
+		case 1:
+			stats.Wins++
+		case 0:
+			stats.Losses++
+		default:
+			stats.Draws++
+		}
+		if progress != nil {
+			progress(result, stats)
+		}
+	}
+	return stats, nil
+}
+
+// playGame plays one game with our engine as color. The error is for an
+// opponent that can't go on at all; an illegal or late move loses the game
+func playGame(engine *search.Engine, opponent *uci.Client, options Options, color int) (GameResult, error) {
+	result := GameResult{EngineColor: color}
+	g := game.NewGame()
+	if options.FEN != "" {
+		var err error
+		if g, err = game.NewGameFromFEN(options.FEN); err != nil {
+			return result, err
+		}
+	}
+	if err := opponent.NewGame(); err != nil {
+		return result, err
+	}
+
+	var clock *game.GameClock
+	if options.Control.Base > 0 {
+		clock = game.NewGameClock(options.Control)
+		clock.Start(g.CurrentPlayer)
+	}
+	timeLimit := engine.TimeLimit
+	defer func() { engine.TimeLimit = timeLimit }()
+
+	// win ends the game with a win for winner
+	win := func(winner int, reason string) (GameResult, error) {
+		result.Score = 0
+		if winner == color {
+			result.Score = 1
+		}
+		result.Reason = reason
+		return result, nil
+	}
+	draw := func(reason string) (GameResult, error) {
+		result.Score, result.Reason = 0.5, reason
+		return result, nil
+	}
+
+	var uciMoves []string
+	for ply := 0; ; ply++ {
+		if over, reason := g.IsGameOver(); over {
+			if g.Board.IsInCheck(g.CurrentPlayer) {
+				return win(1-g.CurrentPlayer, reason)
+			}
+			return draw(reason)
+		}
+		if !g.Board.HasMatingMaterial(board.White) && !g.Board.HasMatingMaterial(board.Black) {
+			return draw("Draw by insufficient material")
+		}
+		if options.MaxPlies > 0 && ply >= options.MaxPlies {
+			return draw(fmt.Sprintf("Draw after %d plies", options.MaxPlies))
+		}
+
+		mover := g.CurrentPlayer
+		var move board.Move
+		if mover == color {
+			engine.TimeLimit = options.MoveTime
+			if clock != nil {
+				engine.TimeLimit = engine.AllocateTime(clock.TimeLeft(mover),
+					clock.Control.Increment+clock.Control.Delay, clock.MovesToGo(mover))
+			}
+			move = engine.SearchBestMoveOrdered(g).BestMove
+		} else {
+			if err := opponent.Position(options.FEN, uciMoves); err != nil {
+				return result, err
+			}
+			limits, timeout := uci.Limits{MoveTime: options.MoveTime}, options.MoveTime+moveTimeGrace
+			if clock != nil {
+				limits = uci.Limits{
+					WTime:     clock.TimeLeft(board.White),
+					BTime:     clock.TimeLeft(board.Black),
+					WInc:      clock.Control.Increment,
+					BInc:      clock.Control.Increment,
+					MovesToGo: clock.MovesToGo(mover),
+				}
+				timeout = clock.TimeLeft(mover) + clock.Control.Delay + moveTimeGrace
+			}
+			answer, err := opponent.Go(limits, timeout)
+			if errors.Is(err, uci.ErrTimeout) {
+				// Wait out the late answer so it isn't taken for the next one
+				opponent.Stop()
+				if err := opponent.IsReady(); err != nil {
+					return result, err
+				}
+				return win(color, fmt.Sprintf("%s didn't move in time", opponent.Name))
+			}
+			if err != nil {
+				return result, err
+			}
+			var ok bool
+			if move, ok = findLegalMove(g, answer.Move); !ok {
+				return win(color, fmt.Sprintf("%s played the illegal move %s", opponent.Name, answer.Move))
+			}
+		}
+
+		san := g.MoveToSAN(move)
+		if !g.MakeMove(move) {
+			return result, fmt.Errorf("our engine chose the illegal move %s", uci.MoveString(move))
+		}
+		result.Moves = append(result.Moves, san)
+		uciMoves = append(uciMoves, uci.MoveString(move))
+
+		if clock != nil && clock.Punch() {
+			reason := g.TimeForfeitResult(mover)
+			if !g.Board.HasMatingMaterial(1 - mover) {
+				return draw(reason)
+			}
+			return win(1-mover, reason)
+		}
+	}
+}
+
+// findLegalMove finds the legal move written in coordinate notation
+func findLegalMove(g *game.GameState, text string) (board.Move, bool) {
+	for _, move := range g.GenerateAllLegalMoves() {
+		if uci.MoveString(move) == text {
+			return move, true
+		}
+	}
+	return board.Move{}, false
+}
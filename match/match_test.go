@@ -0,0 +1,142 @@
+package match
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"chess-engine/search"
+	"chess-engine/uci"
+)
+
+// standInVar names the environment variable that makes the test binary run
+// as the opponent rather than the tests, saying how it should play
+const standInVar = "CHESS_MATCH_OPPONENT"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(standInVar) {
+	case "":
+		os.Exit(m.Run())
+	case "illegal":
+		illegalOpponent()
+	default:
+		uci.Run(bufio.NewScanner(os.Stdin), os.Stdout, false)
+	}
+	os.Exit(0)
+}
+
+// illegalOpponent goes through the handshake and then answers every search
+// with a move no position allows
+func illegalOpponent() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		command, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		switch command {
+		case "uci":
+			fmt.Println("id name Cheat")
+			fmt.Println("uciok")
+		case "isready":
+			fmt.Println("readyok")
+		case "go":
+			fmt.Println("bestmove a1a1")
+		case "quit":
+			return
+		}
+	}
+}
+
+// startOpponent runs the test binary as the opponent
+func startOpponent(t *testing.T, behaviour string) *uci.Client {
+	t.Helper()
+	t.Setenv(standInVar, behaviour)
+	opponent, err := uci.StartEngine(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { opponent.Quit() })
+	return opponent
+}
+
+func TestRunIllegalMoves(t *testing.T) {
+	opponent := startOpponent(t, "illegal")
+	engine := search.NewEngine()
+	engine.MaxDepth = 1
+
+	// The opponent loses as white on its first move, and as black on its reply
+	var results []GameResult
+	stats, err := Run(engine, opponent, Options{Games: 2, MoveTime: 50 * time.Millisecond},
+		func(result GameResult, _ Stats) { results = append(results, result) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Wins != 2 || len(results) != 2 {
+		t.Fatalf("%v over %d games, want two wins", stats, len(results))
+	}
+	for i, result := range results {
+		if result.Number != i+1 || !strings.Contains(result.Reason, "Cheat played the illegal move a1a1") {
+			t.Errorf("game %d: %q", result.Number, result.Reason)
+		}
+		if want := 1 - i; len(result.Moves) != want {
+			t.Errorf("game %d: %d moves played, want %d", result.Number, len(result.Moves), want)
+		}
+	}
+}
+
+func TestRunMaxPlies(t *testing.T) {
+	opponent := startOpponent(t, "uci")
+	engine := search.NewEngine()
+	engine.MaxDepth = 1
+
+	var results []GameResult
+	stats, err := Run(engine, opponent, Options{Games: 2, MoveTime: 50 * time.Millisecond, MaxPlies: 6},
+		func(result GameResult, _ Stats) { results = append(results, result) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Draws != 2 {
+		t.Fatalf("%v, want two draws", stats)
+	}
+	for _, result := range results {
+		if len(result.Moves) != 6 || result.Reason != "Draw after 6 plies" {
+			t.Errorf("game %d: %d moves, %q", result.Number, len(result.Moves), result.Reason)
+		}
+	}
+	if results[0].EngineColor == results[1].EngineColor {
+		t.Error("our engine played the same color twice")
+	}
+}
+
+func TestEloDifference(t *testing.T) {
+	tests := []struct {
+		stats Stats
+		elo   float64
+		ok    bool
+	}{
+		{Stats{}, 0, false},
+		{Stats{Wins: 3}, 0, false},
+		{Stats{Losses: 3}, 0, false},
+		{Stats{Wins: 2, Losses: 2}, 0, true},
+		{Stats{Draws: 5}, 0, true},
+		{Stats{Wins: 3, Losses: 1}, 190.85, true},
+		{Stats{Wins: 1, Losses: 3}, -190.85, true},
+		{Stats{Wins: 1, Draws: 2, Losses: 1}, 0, true},
+		{Stats{Wins: 9, Losses: 1}, 381.70, true},
+	}
+	for _, test := range tests {
+		elo, ok := test.stats.EloDifference()
+		if ok != test.ok || math.Abs(elo-test.elo) > 0.01 {
+			t.Errorf("%+v: got %.2f, %v, want %.2f, %v", test.stats, elo, ok, test.elo, test.ok)
+		}
+		if math.Signbit(elo) && elo == 0 {
+			t.Errorf("%+v: Elo difference is -0", test.stats)
+		}
+	}
+
+	if got := (Stats{Wins: 3, Losses: 1}).String(); got != "+3 -1 =0 (75.0%), Elo difference +191" {
+		t.Errorf("String() = %q", got)
+	}
+}
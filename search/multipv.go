@@ -0,0 +1,75 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"chess-engine/board"
+	"chess-engine/eval"
+	"chess-engine/game"
+)
+
+// SearchMultiPV searches every root move with a full window, so each gets an
+// exact score rather than a bound, and returns up to count results with the
+// best move first. Moves not finished before the time limit are left out
+func (e *Engine) SearchMultiPV(game *game.GameState, count int) []SearchResult {
+	e.startSearch()
+	eval.PrepareEvaluation(game)
+
+	moves := game.GenerateAllLegalMoves()
+	if len(moves) == 0 || count <= 0 {
+		return nil
+	}
+	moves = e.OrderMoves(game, moves)
+	maximizing := game.CurrentPlayer == board.White
+
+	e.notify(SearchStarted{Algorithm: "multipv", Depth: e.MaxDepth, Moves: len(moves)})
+
+	var results []SearchResult
+	e.clearLine(0)
+	cutShort := false
+	for i, move := range moves {
+		e.notify(CurrentMove{Move: move, Number: i + 1, Total: len(moves), Elapsed: time.Since(e.StartTime)})
+
+		newGame := game.Copy()
+		newGame.MakeMove(move)
+		score := e.AlphaBetaOrdered(newGame, e.MaxDepth-1, math.MinInt32, math.MaxInt32, !maximizing)
+
+		// A move whose search was cut short has an unreliable score. It is kept
+		// only when it is the first, so there is always a move to return
+		cutShort = e.timeRanOut()
+		if cutShort && len(results) > 0 {
+			break
+		}
+
+		best := len(results) == 0 ||
+			(maximizing && score > results[0].Score) || (!maximizing && score < results[0].Score)
+		line := append([]board.Move{move}, e.pv[1]...)
+		results = append(results, SearchResult{BestMove: move, Score: score, Depth: e.MaxDepth, PV: line})
+		if best {
+			e.updateLine(0, move)
+		}
+		sort.SliceStable(results, func(a, b int) bool {
+			if maximizing {
+				return results[a].Score > results[b].Score
+			}
+			return results[a].Score < results[b].Score
+		})
+		e.rootMoveSearched(move, i+1, len(moves), score, best)
+
+		if cutShort {
+			break
+		}
+	}
+
+	best := e.finishSearch(results[0].BestMove, results[0].Score, !cutShort)
+	if len(results) > count {
+		results = results[:count]
+	}
+	for i := range results {
+		results[i].NodesVisited = best.NodesVisited
+		results[i].Duration = best.Duration
+	}
+	return results
+}
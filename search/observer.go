@@ -0,0 +1,198 @@
+package search
+
+import (
+	"time"
+
+	"chess-engine/board"
+)
+
+// Observer receives events while the engine searches. Events arrive on the
+// searching goroutine, so Observe should return quickly. Scores are positive
+// for white, as in SearchResult
+type Observer interface {
+	Observe(event Event)
+}
+
+// ObserverFunc lets a plain function observe a search
+type ObserverFunc func(Event)
+
+// Observe calls f
+func (f ObserverFunc) Observe(event Event) {
+	f(event)
+}
+
+// Event is one of the event types below
+type Event interface {
+	searchEvent()
+}
+
+// SearchStarted is sent once the root moves are known and searching begins
+type SearchStarted struct {
+	Algorithm string // "minimax", "alpha-beta" or "ordered alpha-beta"
+	Depth     int
+	Moves     int // Legal moves at the root
+}
+
+// CurrentMove is sent as the search starts on a root move
+type CurrentMove struct {
+	Move    board.Move
+	Number  int // 1 for the first root move searched
+	Total   int
+	Elapsed time.Duration
+}
+
+// MoveSearched is sent when a root move has been searched
+type MoveSearched struct {
+	Move   board.Move
+	Number int
+	Total  int
+	Score  int
+}
+
+// NewBestMove is sent when a root move scores better than those before it
+type NewBestMove struct {
+	Move    board.Move
+	PV      []board.Move // Best line, starting with Move
+	Score   int
+	Depth   int
+	Nodes   int
+	Elapsed time.Duration
+}
+
+// Progress is sent about once a second while searching. There is no
+// transposition table, so no hash usage is reported
+type Progress struct {
+	Nodes   int
+	NPS     int // Nodes per second
+	Elapsed time.Duration
+}
+
+// TimeLimitReached is sent when the search stops early because the time is up
+// or the engine's Stop channel was closed
+type TimeLimitReached struct {
+	Elapsed time.Duration
+	Stopped bool // Stopped through the Stop channel rather than the clock
+}
+
+// IterationComplete is sent when a search to Depth has finished. PV starts
+// with BestMove; the minimax and plain alpha-beta searches keep no longer line
+type IterationComplete struct {
+	Depth    int
+	Score    int
+	BestMove board.Move
+	PV       []board.Move
+	Nodes    int
+	NPS      int
+	Elapsed  time.Duration
+}
+
+func (SearchStarted) searchEvent()     {}
+func (CurrentMove) searchEvent()       {}
+func (MoveSearched) searchEvent()      {}
+func (NewBestMove) searchEvent()       {}
+func (Progress) searchEvent()          {}
+func (TimeLimitReached) searchEvent()  {}
+func (IterationComplete) searchEvent() {}
+
+// progressInterval is how often Progress events are sent
+const progressInterval = time.Second
+
+// notify passes an event to the observer, if there is one
+func (e *Engine) notify(event Event) {
+	if e.Observer != nil {
+		e.Observer.Observe(event)
+	}
+}
+
+// countNode counts a node searched and sends progress when it is due
+func (e *Engine) countNode() {
+	e.NodesVisited++
+	if e.Observer == nil || e.NodesVisited%1024 != 0 {
+		return
+	}
+	if elapsed := time.Since(e.StartTime); elapsed-e.lastProgress >= progressInterval {
+		e.lastProgress = elapsed
+		e.notify(Progress{Nodes: e.NodesVisited, NPS: nodesPerSecond(e.NodesVisited, elapsed), Elapsed: elapsed})
+	}
+}
+
+// startSearch resets the counters for a new search
+func (e *Engine) startSearch() {
+	e.StartTime = time.Now()
+	e.NodesVisited = 0
+	e.lastProgress = 0
+	for ply := range e.pv {
+		e.pv[ply] = e.pv[ply][:0]
+	}
+}
+
+// outOfTime reports whether the search must end, the time being up or the
+// search stopped
+func (e *Engine) outOfTime() bool {
+	return time.Since(e.StartTime) > e.TimeLimit || e.stopped()
+}
+
+// stopped reports whether the Stop channel has been closed
+func (e *Engine) stopped() bool {
+	select {
+	case <-e.Stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// timeRanOut reports whether the search must end, sending TimeLimitReached if so.
+// It is checked after each root move, whose search is then known to be cut short
+func (e *Engine) timeRanOut() bool {
+	if !e.outOfTime() {
+		return false
+	}
+	e.notify(TimeLimitReached{Elapsed: time.Since(e.StartTime), Stopped: e.stopped()})
+	return true
+}
+
+// rootMoveSearched sends the events for a root move, best being whether it
+// is the new best move
+func (e *Engine) rootMoveSearched(move board.Move, number, total, score int, best bool) {
+	e.notify(MoveSearched{Move: move, Number: number, Total: total, Score: score})
+	if best {
+		e.notify(NewBestMove{Move: move, PV: e.rootLine(move), Score: score, Depth: e.MaxDepth,
+			Nodes: e.NodesVisited, Elapsed: time.Since(e.StartTime)})
+	}
+}
+
+// finishSearch sends IterationComplete, if every root move was searched in
+// full, and builds the result
+func (e *Engine) finishSearch(bestMove board.Move, bestScore int, complete bool) SearchResult {
+	duration := time.Since(e.StartTime)
+	pv := e.rootLine(bestMove)
+	if complete {
+		e.notify(IterationComplete{
+			Depth:    e.MaxDepth,
+			Score:    bestScore,
+			BestMove: bestMove,
+			PV:       pv,
+			Nodes:    e.NodesVisited,
+			NPS:      nodesPerSecond(e.NodesVisited, duration),
+			Elapsed:  duration,
+		})
+	}
+
+	return SearchResult{
+		BestMove:     bestMove,
+		Score:        bestScore,
+		Depth:        e.MaxDepth,
+		NodesVisited: e.NodesVisited,
+		Duration:     duration,
+		PV:           pv,
+	}
+}
+
+// nodesPerSecond is the search speed, 0 before any time has passed
+func nodesPerSecond(nodes int, elapsed time.Duration) int {
+	if elapsed <= 0 {
+		return 0
+	}
+	return int(float64(nodes) / elapsed.Seconds())
+}
@@ -0,0 +1,29 @@
+package search
+
+import "chess-engine/board"
+
+// The principal variation is kept in a triangular table: pv[ply] is the best
+// line found from the node at ply, its best move followed by the line of the
+// child that move leads to. Lines are copied up one ply as the search backs up
+
+// clearLine empties the line at ply as its node is entered
+func (e *Engine) clearLine(ply int) {
+	for len(e.pv) <= ply+1 {
+		e.pv = append(e.pv, nil)
+	}
+	e.pv[ply] = e.pv[ply][:0]
+}
+
+// updateLine makes move, followed by the line found below it, the line at ply
+func (e *Engine) updateLine(ply int, move board.Move) {
+	e.pv[ply] = append(append(e.pv[ply][:0], move), e.pv[ply+1]...)
+}
+
+// rootLine is a copy of the line from the root starting with move, or move
+// alone when the search kept no line for it
+func (e *Engine) rootLine(move board.Move) []board.Move {
+	if len(e.pv) == 0 || len(e.pv[0]) == 0 || e.pv[0][0] != move {
+		return []board.Move{move}
+	}
+	return append([]board.Move(nil), e.pv[0]...)
+}
@@ -0,0 +1,676 @@
+// Package search holds the engine, which searches for the best move using the
+// evaluation, an opening book and endgame tablebases.
+package search
+
+import (
+	"math"
+	"time"
+
+	"chess-engine/board"
+	"chess-engine/book"
+	"chess-engine/eval"
+	"chess-engine/game"
+	"chess-engine/tablebase"
+)
+
+// result of a search
+type SearchResult struct {
+	BestMove      board.Move
+	Score         int
+	Depth         int
+	NodesVisited  int
+	Duration      time.Duration
+	FromBook      bool         // Move came from the opening book without searching
+	FromTablebase bool         // Move came from the endgame tablebases without searching
+	PV            []board.Move // Best line found, starting with BestMove; nil for book and tablebase moves
+}
+
+// Engine represents the chess engine
+type Engine struct {
+	MaxDepth     int
+	TimeLimit    time.Duration
+	NodesVisited int
+	StartTime    time.Time
+
+	Book          *book.PolyglotBook // Opening book, nil to always search
+	BookDepth     int                // Stop using the book after this many moves, 0 for no limit
+	BookSelection int                // BookWeighted or BookBest
+
+	Tablebases       *tablebase.Syzygy // Endgame tablebases, nil to always search
+	SyzygyProbeDepth int               // Only probe the tablebases inside search with at least this much depth left
+
+	Observer     Observer        // Receives search events, nil for none
+	Stop         <-chan struct{} // Closing it stops the search as if the time were up, nil for none
+	lastProgress time.Duration   // Elapsed time of the last Progress event
+	pv           [][]board.Move  // Best line below each ply, see clearLine
+}
+
+// tablebaseWinScore is the score for a tablebase win, below any found mate
+const tablebaseWinScore = 20000
+
+// creates new chess engine
+func NewEngine() *Engine {
+	return &Engine{
+		MaxDepth:      5,
+		TimeLimit:     5 * time.Second,
+		BookDepth:     20,
+		BookSelection: book.BookWeighted,
+
+		SyzygyProbeDepth: 1,
+	}
+}
+
+// bookMove looks the position up in the opening book, if there is one
+func (e *Engine) bookMove(game *game.GameState) (SearchResult, bool) {
+	if e.Book == nil {
+		return SearchResult{}, false
+	}
+	if e.BookDepth > 0 && game.FullMoveNumber > e.BookDepth {
+		return SearchResult{}, false
+	}
+
+	move, ok := e.Book.PickMove(game, e.BookSelection)
+	if !ok {
+		return SearchResult{}, false
+	}
+	return SearchResult{BestMove: move, FromBook: true, Duration: time.Since(e.StartTime)}, true
+}
+
+// tablebaseMove picks the tablebase-optimal move when the position is in the tables
+func (e *Engine) tablebaseMove(game *game.GameState) (SearchResult, bool) {
+	if e.Tablebases == nil {
+		return SearchResult{}, false
+	}
+
+	ranked, ok := e.Tablebases.RankRootMoves(game)
+	if !ok {
+		return SearchResult{}, false
+	}
+	best := ranked[0]
+	return SearchResult{
+		BestMove:      best.Move,
+		Score:         e.tablebaseScore(best.WDL, game.CurrentPlayer, e.MaxDepth),
+		FromTablebase: true,
+		Duration:      time.Since(e.StartTime),
+	}, true
+}
+
+// probeTablebase looks up the WDL result of a position during search. KPK
+// draws come from the built-in bitbase, so search doesn't chase them
+func (e *Engine) probeTablebase(game *game.GameState, depth int) (int, bool) {
+	if win, ok := eval.ProbeKPK(game); ok && !win {
+		return 0, true
+	}
+	if e.Tablebases == nil || depth < e.SyzygyProbeDepth || game.HalfMoveClock != 0 {
+		return 0, false
+	}
+	wdl, ok := e.Tablebases.ProbeWDL(game)
+	if !ok {
+		return 0, false
+	}
+	return e.tablebaseScore(wdl, game.CurrentPlayer, depth), true
+}
+
+// tablebaseScore converts a WDL result for color into a white-positive score.
+// Wins found with more depth left are nearer the root, so score higher
+func (e *Engine) tablebaseScore(wdl, color, depth int) int {
+	score := 0
+	switch wdl {
+	case tablebase.WDLWin:
+		score = tablebaseWinScore + depth
+	case tablebase.WDLCursedWin:
+		score = 1
+	case tablebase.WDLBlessedLoss:
+		score = -1
+	case tablebase.WDLLoss:
+		score = -tablebaseWinScore - depth
+	}
+	if color == board.Black {
+		return -score
+	}
+	return score
+}
+
+// AllocateTime decides how long to think about the next move given the time left
+// on the clock. movesToGo is the number of moves until the next time control,
+// or 0 when the rest of the game must be played in the remaining time
+func (e *Engine) AllocateTime(remaining, increment time.Duration, movesToGo int) time.Duration {
+	if movesToGo <= 0 {
+		movesToGo = 30 // Assume the game lasts a while longer
+	}
+
+	// Keep a margin for overhead so the engine never flags itself
+	margin := 50 * time.Millisecond
+	if remaining/20 < margin {
+		margin = remaining / 20
+	}
+	available := remaining - margin
+
+	allotted := available/time.Duration(movesToGo) + increment*3/4
+	if allotted > available/2 && movesToGo > 1 {
+		allotted = available / 2
+	}
+	if allotted > available {
+		allotted = available
+	}
+	if allotted < 10*time.Millisecond {
+		allotted = 10 * time.Millisecond
+	}
+	return allotted
+}
+
+// Minimax implements the minimax algorithm
+func (e *Engine) Minimax(game *game.GameState, depth int, maximizingPlayer bool) int {
+	e.countNode()
+
+	// Check time limit and stop
+	if e.outOfTime() {
+		return eval.Evaluate(game)
+	}
+
+	// Base case: maximum depth reached or game over
+	if depth == 0 {
+		return eval.Evaluate(game)
+	}
+
+	if score, ok := e.probeTablebase(game, depth); ok {
+		return score
+	}
+
+	moves := game.GenerateAllLegalMoves()
+	if len(moves) == 0 {
+		// Game over
+		if game.Board.IsInCheck(game.CurrentPlayer) {
+			// Checkmate - return a score based on depth to prefer quicker mates
+			if maximizingPlayer {
+				return -30000 + depth
+			}
+			return 30000 - depth
+		}
+		// Stalemate
+		return 0
+	}
+
+	if maximizingPlayer {
+		maxEval := math.MinInt32
+		for _, move := range moves {
+			// Make the move
+			newGame := game.Copy()
+			newGame.MakeMove(move)
+
+			// Recursive call
+			eval := e.Minimax(newGame, depth-1, false)
+			maxEval = max(maxEval, eval)
+		}
+		return maxEval
+	} else {
+		minEval := math.MaxInt32
+		for _, move := range moves {
+			// Make the move
+			newGame := game.Copy()
+			newGame.MakeMove(move)
+
+			// Recursive call
+			eval := e.Minimax(newGame, depth-1, true)
+			minEval = min(minEval, eval)
+		}
+		return minEval
+	}
+}
+
+// SearchBestMove finds the best move using minimax
+func (e *Engine) SearchBestMove(game *game.GameState) SearchResult {
+	e.startSearch()
+	eval.PrepareEvaluation(game)
+
+	if result, ok := e.bookMove(game); ok {
+		return result
+	}
+
+	if result, ok := e.tablebaseMove(game); ok {
+		return result
+	}
+
+	moves := game.GenerateAllLegalMoves()
+	if len(moves) == 0 {
+		return SearchResult{}
+	}
+
+	bestMove := moves[0]
+	bestScore := math.MinInt32
+
+	// Determine if we're maximizing (White) or minimizing (Black)
+	maximizing := game.CurrentPlayer == board.White
+	if !maximizing {
+		bestScore = math.MaxInt32
+	}
+
+	e.notify(SearchStarted{Algorithm: "minimax", Depth: e.MaxDepth, Moves: len(moves)})
+
+	cutShort := false
+	for i, move := range moves {
+		e.notify(CurrentMove{Move: move, Number: i + 1, Total: len(moves), Elapsed: time.Since(e.StartTime)})
+
+		// Make the move
+		newGame := game.Copy()
+		newGame.MakeMove(move)
+
+		// Search
+		score := e.Minimax(newGame, e.MaxDepth-1, !maximizing)
+
+		// A move whose search the time limit cut short has a partial score. It
+		// only stands when there is no other, so there is always a move to play
+		cutShort = e.timeRanOut()
+		if cutShort && i > 0 {
+			break
+		}
+
+		improved := false
+
+		// Check if this is the best move
+		if maximizing {
+			if score > bestScore {
+				bestScore = score
+				bestMove = move
+				improved = true
+			}
+		} else {
+			if score < bestScore {
+				bestScore = score
+				bestMove = move
+				improved = true
+			}
+		}
+
+		e.rootMoveSearched(move, i+1, len(moves), score, improved)
+
+		// Check time limit and stop
+		if cutShort {
+			break
+		}
+	}
+
+	return e.finishSearch(bestMove, bestScore, !cutShort)
+}
+
+// Helper functions
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Search with alpha beta pruning
+func (e *Engine) AlphaBeta(game *game.GameState, depth int, alpha, beta int, maximizingPlayer bool) int {
+	e.countNode()
+
+	// Check time limit and stop
+	if e.outOfTime() {
+		return eval.Evaluate(game)
+	}
+
+	// Base case
+	if depth == 0 {
+		return eval.Evaluate(game)
+	}
+
+	if score, ok := e.probeTablebase(game, depth); ok {
+		return score
+	}
+
+	moves := game.GenerateAllLegalMoves()
+	if len(moves) == 0 {
+		// Game over
+		if game.Board.IsInCheck(game.CurrentPlayer) {
+			if maximizingPlayer {
+				return -30000 + depth
+			}
+			return 30000 - depth
+		}
+		return 0
+	}
+
+	if maximizingPlayer {
+		maxEval := math.MinInt32
+		for _, move := range moves {
+			newGame := game.Copy()
+			newGame.MakeMove(move)
+
+			eval := e.AlphaBeta(newGame, depth-1, alpha, beta, false)
+			maxEval = max(maxEval, eval)
+			alpha = max(alpha, eval)
+
+			// Beta cutoff
+			if beta <= alpha {
+				break
+			}
+		}
+		return maxEval
+	} else {
+		minEval := math.MaxInt32
+		for _, move := range moves {
+			newGame := game.Copy()
+			newGame.MakeMove(move)
+
+			eval := e.AlphaBeta(newGame, depth-1, alpha, beta, true)
+			minEval = min(minEval, eval)
+			beta = min(beta, eval)
+
+			// Alpha cutoff
+			if beta <= alpha {
+				break
+			}
+		}
+		return minEval
+	}
+}
+
+// SearchBestMoveAB finds the best move using alpha-beta pruning
+func (e *Engine) SearchBestMoveAB(game *game.GameState) SearchResult {
+	e.startSearch()
+	eval.PrepareEvaluation(game)
+
+	if result, ok := e.bookMove(game); ok {
+		return result
+	}
+
+	if result, ok := e.tablebaseMove(game); ok {
+		return result
+	}
+
+	moves := game.GenerateAllLegalMoves()
+	if len(moves) == 0 {
+		return SearchResult{}
+	}
+
+	bestMove := moves[0]
+	bestScore := math.MinInt32
+
+	maximizing := game.CurrentPlayer == board.White
+	if !maximizing {
+		bestScore = math.MaxInt32
+	}
+
+	e.notify(SearchStarted{Algorithm: "alpha-beta", Depth: e.MaxDepth, Moves: len(moves)})
+
+	alpha := math.MinInt32
+	beta := math.MaxInt32
+
+	cutShort := false
+	for i, move := range moves {
+		e.notify(CurrentMove{Move: move, Number: i + 1, Total: len(moves), Elapsed: time.Since(e.StartTime)})
+
+		newGame := game.Copy()
+		newGame.MakeMove(move)
+
+		score := e.AlphaBeta(newGame, e.MaxDepth-1, alpha, beta, !maximizing)
+
+		// A move whose search the time limit cut short has a partial score. It
+		// only stands when there is no other, so there is always a move to play
+		cutShort = e.timeRanOut()
+		if cutShort && i > 0 {
+			break
+		}
+
+		improved := false
+
+		if maximizing {
+			if score > bestScore {
+				bestScore = score
+				bestMove = move
+				improved = true
+			}
+			alpha = max(alpha, score)
+		} else {
+			if score < bestScore {
+				bestScore = score
+				bestMove = move
+				improved = true
+			}
+			beta = min(beta, score)
+		}
+		e.rootMoveSearched(move, i+1, len(moves), score, improved)
+
+		if cutShort {
+			break
+		}
+	}
+
+	return e.finishSearch(bestMove, bestScore, !cutShort)
+}
+
+// ScoreMove assigns a score to a move for ordering purposes
+func (e *Engine) ScoreMove(game *game.GameState, move board.Move) int {
+	score := 0
+
+	// Prioritize captures
+	if move.IsCapture {
+		// MVV-LVA (Most Valuable Victim - Least Valuable Attacker)
+		victimValue := eval.PieceValues[move.CapturedPiece.Type]
+		attackerValue := eval.PieceValues[move.PieceType]
+		score += victimValue - attackerValue/10
+	}
+
+	// Prioritize promotions
+	if move.PromotionPiece != board.Empty {
+		score += eval.PieceValues[move.PromotionPiece]
+	}
+
+	// Prioritize checks
+	newGame := game.DetachedCopy()
+	newGame.MakeMoveUnchecked(move)
+	enemyColor := 1 - game.CurrentPlayer
+	if newGame.Board.IsInCheck(enemyColor) {
+		score += 50
+	}
+
+	// Prioritize castling
+	if move.IsCastle {
+		score += 40
+	}
+
+	// Penalize moving to attacked squares
+	enemyMoves := game.Board.GenerateAllMoves(1 - game.CurrentPlayer)
+	for _, enemyMove := range enemyMoves {
+		if enemyMove.ToRow == move.ToRow && enemyMove.ToCol == move.ToCol {
+			score -= 10
+			break
+		}
+	}
+
+	return score
+}
+
+// OrderMoves sorts moves by their estimated value
+func (e *Engine) OrderMoves(game *game.GameState, moves []board.Move) []board.Move {
+	// Create a slice of move-score pairs
+	type MoveScore struct {
+		Move  board.Move
+		Score int
+	}
+
+	moveScores := make([]MoveScore, len(moves))
+	for i, move := range moves {
+		moveScores[i] = MoveScore{
+			Move:  move,
+			Score: e.ScoreMove(game, move),
+		}
+	}
+
+	// Sort by score (descending)
+	for i := 0; i < len(moveScores)-1; i++ {
+		for j := i + 1; j < len(moveScores); j++ {
+			if moveScores[j].Score > moveScores[i].Score {
+				moveScores[i], moveScores[j] = moveScores[j], moveScores[i]
+			}
+		}
+	}
+
+	// Extract sorted moves
+	orderedMoves := make([]board.Move, len(moves))
+	for i, ms := range moveScores {
+		orderedMoves[i] = ms.Move
+	}
+
+	return orderedMoves
+}
+
+// AlphaBetaOrdered implements alpha-beta with move ordering, keeping the best
+// line below each node in the engine's PV table
+func (e *Engine) AlphaBetaOrdered(game *game.GameState, depth int, alpha, beta int, maximizingPlayer bool) int {
+	e.countNode()
+	ply := e.MaxDepth - depth
+	e.clearLine(ply)
+
+	if e.outOfTime() {
+		return eval.Evaluate(game)
+	}
+
+	if depth == 0 {
+		return eval.Evaluate(game)
+	}
+
+	if score, ok := e.probeTablebase(game, depth); ok {
+		return score
+	}
+
+	moves := game.GenerateAllLegalMoves()
+	if len(moves) == 0 {
+		if game.Board.IsInCheck(game.CurrentPlayer) {
+			if maximizingPlayer {
+				return -30000 + depth
+			}
+			return 30000 - depth
+		}
+		return 0
+	}
+
+	// Order moves for better pruning
+	moves = e.OrderMoves(game, moves)
+
+	if maximizingPlayer {
+		maxEval := math.MinInt32
+		for _, move := range moves {
+			newGame := game.Copy()
+			newGame.MakeMove(move)
+
+			eval := e.AlphaBetaOrdered(newGame, depth-1, alpha, beta, false)
+			if eval > maxEval {
+				maxEval = eval
+				e.updateLine(ply, move)
+			}
+			alpha = max(alpha, eval)
+
+			if beta <= alpha {
+				break // Beta cutoff
+			}
+		}
+		return maxEval
+	} else {
+		minEval := math.MaxInt32
+		for _, move := range moves {
+			newGame := game.Copy()
+			newGame.MakeMove(move)
+
+			eval := e.AlphaBetaOrdered(newGame, depth-1, alpha, beta, true)
+			if eval < minEval {
+				minEval = eval
+				e.updateLine(ply, move)
+			}
+			beta = min(beta, eval)
+
+			if beta <= alpha {
+				break // Alpha cutoff
+			}
+		}
+		return minEval
+	}
+}
+
+// SearchBestMoveOrdered finds the best move using ordered alpha-beta
+func (e *Engine) SearchBestMoveOrdered(game *game.GameState) SearchResult {
+	e.startSearch()
+	eval.PrepareEvaluation(game)
+
+	if result, ok := e.bookMove(game); ok {
+		return result
+	}
+
+	if result, ok := e.tablebaseMove(game); ok {
+		return result
+	}
+
+	moves := game.GenerateAllLegalMoves()
+	if len(moves) == 0 {
+		return SearchResult{}
+	}
+
+	// Order moves at root level
+	moves = e.OrderMoves(game, moves)
+
+	bestMove := moves[0]
+	bestScore := math.MinInt32
+
+	maximizing := game.CurrentPlayer == board.White
+	if !maximizing {
+		bestScore = math.MaxInt32
+	}
+
+	e.notify(SearchStarted{Algorithm: "ordered alpha-beta", Depth: e.MaxDepth, Moves: len(moves)})
+
+	alpha := math.MinInt32
+	beta := math.MaxInt32
+
+	e.clearLine(0)
+	cutShort := false
+	for i, move := range moves {
+		e.notify(CurrentMove{Move: move, Number: i + 1, Total: len(moves), Elapsed: time.Since(e.StartTime)})
+
+		newGame := game.Copy()
+		newGame.MakeMove(move)
+
+		score := e.AlphaBetaOrdered(newGame, e.MaxDepth-1, alpha, beta, !maximizing)
+
+		// A move whose search the time limit cut short has a partial score. It
+		// only stands when there is no other, so there is always a move to play
+		cutShort = e.timeRanOut()
+		if cutShort && i > 0 {
+			break
+		}
+
+		improved := false
+
+		if maximizing {
+			if score > bestScore {
+				bestScore = score
+				bestMove = move
+				improved = true
+				e.updateLine(0, move)
+			}
+			alpha = max(alpha, score)
+		} else {
+			if score < bestScore {
+				bestScore = score
+				bestMove = move
+				improved = true
+				e.updateLine(0, move)
+			}
+			beta = min(beta, score)
+		}
+		e.rootMoveSearched(move, i+1, len(moves), score, improved)
+
+		if cutShort {
+			break
+		}
+	}
+
+	return e.finishSearch(bestMove, bestScore, !cutShort)
+}
@@ -0,0 +1,126 @@
+package search
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"chess-engine/game"
+)
+
+func TestBackRankPawnFEN(t *testing.T) {
+	fen := "8/8/8/8/8/8/k7/2K4P b - - 0 1"
+	if _, err := game.NewGameFromFEN(fen); err == nil {
+		t.Fatalf("%s was accepted", fen)
+	}
+
+	// The same KPK position with the pawn where it can be is searched as usual
+	g, err := game.NewGameFromFEN("8/8/8/8/8/8/k6P/2K5 b - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine()
+	engine.MaxDepth = 3
+	if result := engine.SearchBestMoveOrdered(g); !g.MakeMove(result.BestMove) {
+		t.Errorf("best move %s isn't legal", result.BestMove.String())
+	}
+}
+
+func TestMultiPVDropsCutShortMoves(t *testing.T) {
+	engine := NewEngine()
+	engine.MaxDepth = 4
+	engine.TimeLimit = time.Nanosecond
+
+	// Only the first move, needed to have any answer at all, may be cut short
+	results := engine.SearchMultiPV(game.NewGame(), 5)
+	if len(results) != 1 {
+		t.Fatalf("got %d results out of time, want 1", len(results))
+	}
+
+	engine.TimeLimit = time.Minute
+	engine.MaxDepth = 2
+	if results := engine.SearchMultiPV(game.NewGame(), 5); len(results) != 5 {
+		t.Errorf("got %d results with time to spare, want 5", len(results))
+	}
+}
+
+func TestCutShortSearch(t *testing.T) {
+	searches := map[string]func(*Engine, *game.GameState) SearchResult{
+		"minimax":    (*Engine).SearchBestMove,
+		"alpha-beta": (*Engine).SearchBestMoveAB,
+		"ordered":    (*Engine).SearchBestMoveOrdered,
+	}
+	for name, searchBestMove := range searches {
+		t.Run(name, func(t *testing.T) {
+			var searched, timeUp, iterations int
+			engine := NewEngine()
+			engine.Observer = ObserverFunc(func(event Event) {
+				switch event.(type) {
+				case MoveSearched:
+					searched++
+				case TimeLimitReached:
+					timeUp++
+				case IterationComplete:
+					iterations++
+				}
+			})
+
+			// Out of time, only the first move is searched and the iteration
+			// isn't finished
+			engine.MaxDepth = 4
+			engine.TimeLimit = time.Nanosecond
+			g := game.NewGame()
+			result := searchBestMove(engine, g)
+			if searched != 1 || timeUp != 1 || iterations != 0 {
+				t.Errorf("out of time: %d moves searched, %d time limits, %d iterations, want 1, 1 and 0",
+					searched, timeUp, iterations)
+			}
+			if !g.Copy().MakeMove(result.BestMove) {
+				t.Errorf("best move %s isn't legal", result.BestMove.String())
+			}
+
+			searched, timeUp, iterations = 0, 0, 0
+			engine.MaxDepth = 2
+			engine.TimeLimit = time.Minute
+			searchBestMove(engine, g)
+			if searched != 20 || timeUp != 0 || iterations != 1 {
+				t.Errorf("with time to spare: %d moves searched, %d time limits, %d iterations, want 20, 0 and 1",
+					searched, timeUp, iterations)
+			}
+		})
+	}
+}
+
+func TestPrincipalVariation(t *testing.T) {
+	engine := NewEngine()
+	engine.MaxDepth = 3
+	var iteration IterationComplete
+	engine.Observer = ObserverFunc(func(event Event) {
+		if ev, ok := event.(IterationComplete); ok {
+			iteration = ev
+		}
+	})
+
+	g := game.NewGame()
+	result := engine.SearchBestMoveOrdered(g)
+	if len(result.PV) != engine.MaxDepth || result.PV[0] != result.BestMove {
+		t.Fatalf("line of %d moves for best move %s, want %d starting with it",
+			len(result.PV), result.BestMove.String(), engine.MaxDepth)
+	}
+	if !slices.Equal(iteration.PV, result.PV) {
+		t.Errorf("iteration line differs from the result's")
+	}
+	line := g.Copy()
+	for _, move := range result.PV {
+		if !line.MakeMove(move) {
+			t.Fatalf("%s in the line isn't legal", move.String())
+		}
+	}
+
+	// Each multi-PV line follows its own move
+	for _, result := range engine.SearchMultiPV(g, 3) {
+		if len(result.PV) != engine.MaxDepth || result.PV[0] != result.BestMove {
+			t.Errorf("line of %d moves for %s", len(result.PV), result.BestMove.String())
+		}
+	}
+}
@@ -0,0 +1,309 @@
+// Package selfplay generates training positions from engine self-play games.
+package selfplay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"math/rand"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"chess-engine/board"
+	"chess-engine/eval"
+	"chess-engine/game"
+	"chess-engine/search"
+)
+
+// Training data formats
+const (
+	SfenText   = iota // "<fen> | <score> | <result>" per line
+	SfenBinary        // Packed 28-byte records, see TrainingPosition.MarshalBinary
+)
+
+// GenSfensOptions control self-play data generation
+type GenSfensOptions struct {
+	Games       int   // Games to play
+	Threads     int   // Games played at once, 0 for one per CPU
+	Depth       int   // Search depth for every move
+	RandomPlies int   // Random moves played from the start position before the engines take over
+	MaxPlies    int   // Games still going after this many plies are scored as draws
+	MaxScore    int   // Positions scored beyond this aren't recorded, and the game is adjudicated
+	Format      int   // SfenText or SfenBinary
+	Seed        int64 // Seed for the random openings, 0 to use the time
+}
+
+// DefaultGenSfensOptions are fast settings that still give sensible scores
+func DefaultGenSfensOptions() GenSfensOptions {
+	return GenSfensOptions{
+		Games:       100,
+		Depth:       4,
+		RandomPlies: 8,
+		MaxPlies:    400,
+		MaxScore:    3000,
+		Format:      SfenText,
+	}
+}
+
+// TrainingPosition is a position from self-play with its search score and the game result
+type TrainingPosition struct {
+	Game   *game.GameState
+	Score  int     // Search score, positive for white
+	Result float64 // 1 for a white win, 0.5 for a draw, 0 for a black win
+}
+
+// trainingRecordSize is the size of a binary training record
+const trainingRecordSize = 28
+
+// MarshalBinary packs a position as: occupied squares uint64 (bit row*8+col,
+// row 0 being rank 8), one nibble per occupied square in bit order holding
+// color<<3 | piece type, side to move uint8, score int16 and result uint8
+// (0 black win, 1 draw, 2 white win), all little endian. Castling and en
+// passant rights are not kept
+func (tp TrainingPosition) MarshalBinary() ([]byte, error) {
+	data := make([]byte, trainingRecordSize)
+	var occupied uint64
+	n := 0
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			piece := tp.Game.Board.GetPiece(row, col)
+			if piece.Type == board.Empty {
+				continue
+			}
+			if n == 32 {
+				return nil, fmt.Errorf("more than 32 pieces")
+			}
+			occupied |= 1 << uint(row*8+col)
+			data[8+n/2] |= byte(piece.Color<<3|piece.Type) << (4 * uint(n%2))
+			n++
+		}
+	}
+	binary.LittleEndian.PutUint64(data, occupied)
+	data[24] = byte(tp.Game.CurrentPlayer)
+	binary.LittleEndian.PutUint16(data[25:], uint16(int16(max(-32767, min(32767, tp.Score)))))
+	data[27] = byte(tp.Result * 2)
+	return data, nil
+}
+
+// UnmarshalBinary unpacks a record written by MarshalBinary
+func (tp *TrainingPosition) UnmarshalBinary(data []byte) error {
+	if len(data) != trainingRecordSize {
+		return fmt.Errorf("training record should be %d bytes, got %d", trainingRecordSize, len(data))
+	}
+	g := &game.GameState{
+		Board:           &board.Board{},
+		MoveHistory:     make([]board.Move, 0),
+		EnPassantSquare: [2]int{-1, -1},
+		FullMoveNumber:  1,
+	}
+	occupied := binary.LittleEndian.Uint64(data)
+	for n := 0; occupied != 0; n++ {
+		sq := bits.TrailingZeros64(occupied)
+		occupied &= occupied - 1
+		nibble := data[8+n/2] >> (4 * uint(n%2)) & 0xF
+		g.Board.SetPiece(sq/8, sq%8, board.Piece{Type: int(nibble & 7), Color: int(nibble >> 3)})
+	}
+	g.CurrentPlayer = int(data[24])
+	tp.Game = g
+	tp.Score = int(int16(binary.LittleEndian.Uint16(data[25:])))
+	tp.Result = float64(data[27]) / 2
+	return nil
+}
+
+// GenSfensStats counts what a generation run has done so far
+type GenSfensStats struct {
+	Games     int64
+	Positions int64
+}
+
+// GenerateSfens plays engine-vs-engine games from random openings on several
+// goroutines and writes quiet positions from them to w. progress, if not nil,
+// is called after every game
+func GenerateSfens(w io.Writer, options GenSfensOptions, progress func(GenSfensStats)) (GenSfensStats, error) {
+	if options.Threads <= 0 {
+		options.Threads = runtime.NumCPU()
+	}
+	if options.Seed == 0 {
+		options.Seed = time.Now().UnixNano()
+	}
+
+	var stats GenSfensStats
+	var gamesStarted atomic.Int64
+	games := make(chan []TrainingPosition)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < options.Threads; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(options.Seed + int64(worker)))
+			engine := search.NewEngine()
+			engine.MaxDepth = options.Depth
+			engine.TimeLimit = time.Hour // Depth bounds the search
+			for gamesStarted.Add(1) <= int64(options.Games) {
+				games <- playTrainingGame(engine, r, options)
+			}
+		}(worker)
+	}
+	go func() {
+		wg.Wait()
+		close(games)
+	}()
+
+	// One writer, so records from different games never interleave
+	out := bufio.NewWriter(w)
+	var writeErr error
+	for positions := range games {
+		for _, tp := range positions {
+			if writeErr == nil {
+				writeErr = writeTrainingPosition(out, tp, options.Format)
+			}
+		}
+		stats.Games++
+		stats.Positions += int64(len(positions))
+		if progress != nil {
+			progress(stats)
+		}
+	}
+	if writeErr != nil {
+		return stats, writeErr
+	}
+	return stats, out.Flush()
+}
+
+// playTrainingGame plays one game and returns its quiet positions labelled with the result
+func playTrainingGame(engine *search.Engine, r *rand.Rand, options GenSfensOptions) []TrainingPosition {
+	game := randomOpening(r, options.RandomPlies)
+	var positions []TrainingPosition
+	result := 0.5
+
+	for ply := 0; ply < options.MaxPlies; ply++ {
+		if over, _ := game.IsGameOver(); over {
+			if game.Board.IsInCheck(game.CurrentPlayer) {
+				result = float64(game.CurrentPlayer) // The side to move is mated
+			}
+			break
+		}
+		if !game.Board.HasMatingMaterial(board.White) && !game.Board.HasMatingMaterial(board.Black) {
+			break
+		}
+
+		searched := engine.SearchBestMoveOrdered(game)
+		if searched.Score > options.MaxScore || searched.Score < -options.MaxScore {
+			// Adjudicate clear wins rather than play them out
+			if searched.Score > 0 {
+				result = 1
+			} else {
+				result = 0
+			}
+			break
+		}
+
+		// Positions in check, where the best move captures or promotes, or with
+		// a capture that wins material pending aren't quiet
+		move := searched.BestMove
+		if !game.Board.IsInCheck(game.CurrentPlayer) && !move.IsCapture && move.PromotionPiece == board.Empty && isQuiet(game) {
+			positions = append(positions, TrainingPosition{Game: game.Copy(), Score: searched.Score})
+		}
+		if !game.MakeMove(move) {
+			break
+		}
+	}
+
+	for i := range positions {
+		positions[i].Result = result
+	}
+	return positions
+}
+
+// quiescenceDepth bounds the capture sequences followed by isQuiet
+const quiescenceDepth = 8
+
+// isQuiet reports whether the static evaluation can be trusted: no capture
+// or promotion sequence does better for the side to move than standing pat
+func isQuiet(game *game.GameState) bool {
+	static := sideScore(game)
+	return quiescence(game, static, static+1, quiescenceDepth) <= static
+}
+
+// quiescence searches captures and promotions only, scoring from the side to move
+func quiescence(game *game.GameState, alpha, beta, depth int) int {
+	standPat := sideScore(game)
+	if standPat >= beta || depth == 0 {
+		return standPat
+	}
+	alpha = max(alpha, standPat)
+
+	for _, move := range game.GenerateAllLegalMoves() {
+		if !move.IsCapture && move.PromotionPiece == board.Empty {
+			continue
+		}
+		next := game.Copy()
+		if !next.MakeMove(move) {
+			continue
+		}
+		score := -quiescence(next, -beta, -alpha, depth-1)
+		if score >= beta {
+			return score
+		}
+		alpha = max(alpha, score)
+	}
+	return alpha
+}
+
+// sideScore is the static evaluation from the side to move's point of view
+func sideScore(game *game.GameState) int {
+	if game.CurrentPlayer == board.Black {
+		return -eval.Evaluate(game)
+	}
+	return eval.Evaluate(game)
+}
+
+// randomOpening plays random legal moves from the start, trying again if the game ends
+func randomOpening(r *rand.Rand, plies int) *game.GameState {
+	for {
+		game := game.NewGame()
+		for ply := 0; ply < plies; ply++ {
+			moves := game.GenerateAllLegalMoves()
+			if len(moves) == 0 {
+				break
+			}
+			game.MakeMove(moves[r.Intn(len(moves))])
+		}
+		if len(game.GenerateAllLegalMoves()) > 0 {
+			return game
+		}
+	}
+}
+
+// writeTrainingPosition writes one position in the chosen format
+func writeTrainingPosition(w io.Writer, tp TrainingPosition, format int) error {
+	if format == SfenBinary {
+		data, err := tp.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s | %d | %.1f\n", tp.Game.FEN(), tp.Score, tp.Result)
+	return err
+}
+
+// GenerateSfensFile runs GenerateSfens into a new file
+func GenerateSfensFile(path string, options GenSfensOptions, progress func(GenSfensStats)) (GenSfensStats, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return GenSfensStats{}, err
+	}
+	stats, err := GenerateSfens(f, options, progress)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return stats, err
+}
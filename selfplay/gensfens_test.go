@@ -0,0 +1,29 @@
+package selfplay
+
+import (
+	"testing"
+
+	"chess-engine/game"
+)
+
+func TestIsQuiet(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		quiet bool
+	}{
+		{"start", game.StartingFEN, true},
+		{"hanging queen", "rnb1kbnr/pppp1ppp/8/4p1q1/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3", false},
+		{"defended pawn", "rnbqkbnr/ppp2ppp/3p4/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 0 3", true},
+		{"promotion", "8/4P1k1/8/8/8/8/5PK1/8 w - - 0 1", false},
+	}
+	for _, test := range tests {
+		g, err := game.NewGameFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := isQuiet(g); got != test.quiet {
+			t.Errorf("%s: quiet %v, want %v", test.name, got, test.quiet)
+		}
+	}
+}
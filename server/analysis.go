@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"chess-engine/board"
+	"chess-engine/game"
+	"chess-engine/search"
+)
+
+// handleAnalysis upgrades to a WebSocket on which a client analyses positions
+// live, getting each finished depth as it comes
+func (s *Server) handleAnalysis(w http.ResponseWriter, r *http.Request) {
+	select {
+	case s.streams <- struct{}{}:
+		defer func() { <-s.streams }()
+	default:
+		writeError(w, http.StatusServiceUnavailable, errors.New("too many analysis connections, try again later"))
+		return
+	}
+
+	ws, ok := upgradeWebsocket(w, r, s.options.MaxBodyBytes)
+	if !ok {
+		return
+	}
+	session := &analysisSession{server: s, ws: ws, engine: search.NewEngine()}
+	session.engine.Observer = session
+	session.run()
+}
+
+// analysisSession is one analysis connection. Messages are read on the
+// connection's goroutine and each analysis runs on a goroutine of its own,
+// one at a time
+type analysisSession struct {
+	server *Server
+	ws     *websocketConn
+	engine *search.Engine // The connection's own, shared with no other
+
+	stop chan struct{} // Closed to stop the running analysis, nil before the first
+	done chan struct{} // Closed when the analysis has ended
+
+	// Used only by the analysis goroutine
+	game     *game.GameState
+	start    time.Time
+	nodes    int  // Nodes of the finished iterations
+	cutShort bool // The current iteration ran out of time or was stopped
+}
+
+// run reads messages until the client closes the connection or goes quiet
+func (a *analysisSession) run() {
+	defer a.ws.Close(closeNormal, "")
+	defer a.stopAnalysis()
+
+	for {
+		if a.server.options.IdleTimeout > 0 {
+			a.ws.SetReadDeadline(time.Now().Add(a.server.options.MaxTime + a.server.options.IdleTimeout))
+		}
+		data, err := a.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var req AnalysisRequest
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			a.sendError(fmt.Errorf("invalid message: %v", err))
+			continue
+		}
+
+		switch req.Type {
+		case "position":
+			a.stopAnalysis()
+			if err := a.startAnalysis(req); err != nil {
+				a.sendError(err)
+			}
+		case "stop":
+			a.stopAnalysis()
+		default:
+			a.sendError(fmt.Errorf("unknown message type %q", req.Type))
+		}
+	}
+}
+
+// startAnalysis checks a position request and starts analysing it
+func (a *analysisSession) startAnalysis(req AnalysisRequest) error {
+	g, err := parsePosition(&req.FEN)
+	if err != nil {
+		return err
+	}
+	if err := a.server.checkLimits(req.Depth, req.TimeMS, 0); err != nil {
+		return err
+	}
+	if over, result := g.IsGameOver(); over {
+		return fmt.Errorf("game is over: %s", result)
+	}
+
+	depth, timeLimit := a.server.options.MaxDepth, a.server.options.MaxTime
+	if req.Depth > 0 {
+		depth = req.Depth
+	}
+	if req.TimeMS > 0 {
+		timeLimit = time.Duration(req.TimeMS) * time.Millisecond
+	}
+
+	a.stop, a.done = make(chan struct{}), make(chan struct{})
+	a.engine.Stop = a.stop
+	go a.analyse(g, depth, timeLimit, a.stop, a.done)
+	return nil
+}
+
+// stopAnalysis stops the running analysis, if any, and waits for it to end
+func (a *analysisSession) stopAnalysis() {
+	if a.stop == nil {
+		return
+	}
+	select {
+	case <-a.stop:
+	default:
+		close(a.stop)
+	}
+	<-a.done
+}
+
+// analyse deepens the search a ply at a time, the observer sending each
+// finished iteration, until it reaches depth, runs out of time or is stopped
+func (a *analysisSession) analyse(g *game.GameState, depth int, timeLimit time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	a.game, a.start, a.nodes = g, time.Now(), 0
+
+	end := AnalysisEnd{Type: "done"}
+	for d := 1; d <= depth; d++ {
+		remaining := timeLimit - time.Since(a.start)
+		if remaining <= 0 {
+			break
+		}
+		a.engine.MaxDepth, a.engine.TimeLimit = d, remaining
+		a.cutShort = false
+		result := a.engine.SearchBestMoveOrdered(g)
+		if a.cutShort {
+			break
+		}
+		a.nodes += result.NodesVisited
+		end.Depth, end.Best = d, &SearchLine{Move: moveInfo(g, result.BestMove), Score: result.Score}
+	}
+
+	select {
+	case <-stop:
+		end.Type = "stopped"
+	default:
+	}
+	a.ws.WriteJSON(end)
+}
+
+// Observe sends finished iterations to the client, leaving out those cut short
+func (a *analysisSession) Observe(event search.Event) {
+	switch event := event.(type) {
+	case search.TimeLimitReached:
+		a.cutShort = true
+	case search.IterationComplete:
+		if a.cutShort {
+			return
+		}
+		elapsed := time.Since(a.start)
+		nodes := a.nodes + event.Nodes
+		a.ws.WriteJSON(AnalysisIteration{
+			Type:   "iteration",
+			Depth:  event.Depth,
+			Score:  event.Score,
+			PV:     lineInfo(a.game, event.PV),
+			Nodes:  nodes,
+			NPS:    int(float64(nodes) / max(elapsed.Seconds(), 0.001)),
+			TimeMS: elapsed.Milliseconds(),
+		})
+	}
+}
+
+// sendError tells the client a message couldn't be acted on
+func (a *analysisSession) sendError(err error) {
+	a.ws.WriteJSON(AnalysisError{Type: "error", Error: err.Error()})
+}
+
+// lineInfo writes a line of moves in both notations, playing them out on a copy
+func lineInfo(g *game.GameState, moves []board.Move) []MoveInfo {
+	line := make([]MoveInfo, len(moves))
+	g = g.Copy()
+	for i, move := range moves {
+		line[i] = moveInfo(g, move)
+		g.MakeMove(move)
+	}
+	return line
+}
@@ -0,0 +1,129 @@
+package server
+
+import "chess-engine/eval"
+
+// The request and response bodies of the API. schema.json describes the same
+// shapes as JSON Schema. Scores are in centipawns, positive for white
+
+// PositionRequest names a position, for /api/moves and /api/eval
+type PositionRequest struct {
+	FEN string `json:"fen"`
+}
+
+// MoveRequest asks for a move to be played, given in coordinate notation
+// (e2e4, e7e8q) or SAN (Nf3, O-O)
+type MoveRequest struct {
+	FEN  string `json:"fen"`
+	Move string `json:"move"`
+}
+
+// SearchRequest asks for a bounded search. Zero values take the server's defaults
+type SearchRequest struct {
+	FEN     string `json:"fen"`
+	Depth   int    `json:"depth,omitempty"`
+	TimeMS  int    `json:"timeMs,omitempty"`
+	MultiPV int    `json:"multiPv,omitempty"`
+}
+
+// MoveInfo is a move in both notations
+type MoveInfo struct {
+	UCI string `json:"uci"`
+	SAN string `json:"san"`
+}
+
+// PositionInfo describes a position
+type PositionInfo struct {
+	FEN        string `json:"fen"`
+	SideToMove string `json:"sideToMove"` // "white" or "black"
+	InCheck    bool   `json:"inCheck"`
+	GameOver   bool   `json:"gameOver"`
+	Result     string `json:"result,omitempty"` // Why the game is over
+}
+
+// MovesResponse lists the legal moves of a position
+type MovesResponse struct {
+	PositionInfo
+	Moves []MoveInfo `json:"moves"`
+}
+
+// MoveResponse is the position after a move
+type MoveResponse struct {
+	Move MoveInfo `json:"move"`
+	PositionInfo
+}
+
+// EvalTerm is one term of the evaluation, each side's from its own point of view
+type EvalTerm struct {
+	Name  string         `json:"name"`
+	White eval.EvalScore `json:"white"` // [middlegame, endgame]
+	Black eval.EvalScore `json:"black"`
+	Net   eval.EvalScore `json:"net"` // White minus black
+}
+
+// EvalResponse breaks down the static evaluation of a position
+type EvalResponse struct {
+	Score    int        `json:"score"`
+	Phase    float64    `json:"phase"` // 1 for the opening, 0 for the endgame
+	Terms    []EvalTerm `json:"terms"`
+	Terminal bool       `json:"terminal,omitempty"` // Checkmate or stalemate
+	Endgame  string     `json:"endgame,omitempty"`  // Known ending scored by its own evaluator
+	Scale    int        `json:"scale"`              // Endgame scale factor, 64 for none
+	ScaleBy  string     `json:"scaleBy,omitempty"`
+	NNUE     *int       `json:"nnue,omitempty"` // Network score when a network is loaded
+}
+
+// SearchLine is one searched move with its score
+type SearchLine struct {
+	Move  MoveInfo `json:"move"`
+	Score int      `json:"score"`
+}
+
+// SearchResponse holds the best lines found, best first
+type SearchResponse struct {
+	Lines  []SearchLine `json:"lines"`
+	Depth  int          `json:"depth"`
+	Nodes  int          `json:"nodes"`
+	TimeMS int64        `json:"timeMs"`
+}
+
+// AnalysisRequest is a message a client sends on /api/analysis. "position"
+// starts analysing a position, stopping any analysis already running, and
+// "stop" stops it. Zero limits take the server's maximums
+type AnalysisRequest struct {
+	Type   string `json:"type"` // "position" or "stop"
+	FEN    string `json:"fen,omitempty"`
+	Depth  int    `json:"depth,omitempty"`
+	TimeMS int    `json:"timeMs,omitempty"`
+}
+
+// AnalysisIteration is sent on /api/analysis each time a depth is finished.
+// Nodes and TimeMS count from the start of the analysis
+type AnalysisIteration struct {
+	Type   string     `json:"type"` // "iteration"
+	Depth  int        `json:"depth"`
+	Score  int        `json:"score"`
+	PV     []MoveInfo `json:"pv"` // Principal variation, starting with the best move
+	Nodes  int        `json:"nodes"`
+	NPS    int        `json:"nps"`
+	TimeMS int64      `json:"timeMs"`
+}
+
+// AnalysisEnd is sent on /api/analysis when an analysis ends, with the
+// result of its deepest finished iteration
+type AnalysisEnd struct {
+	Type  string      `json:"type"` // "done" at its depth or time limit, "stopped" when cut short
+	Depth int         `json:"depth"`
+	Best  *SearchLine `json:"best,omitempty"` // Missing when no iteration finished
+}
+
+// AnalysisError is sent on /api/analysis when a message can't be acted on.
+// The connection stays open
+type AnalysisError struct {
+	Type  string `json:"type"` // "error"
+	Error string `json:"error"`
+}
+
+// ErrorResponse is the body of every error the handlers send
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,321 @@
+// Package server serves the engine's analysis over HTTP as JSON: legal moves,
+// playing a move, the evaluation breakdown and bounded searches, and live
+// analysis streamed over a WebSocket.
+package server
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"chess-engine/board"
+	"chess-engine/eval"
+	"chess-engine/game"
+	"chess-engine/search"
+	"chess-engine/uci"
+)
+
+//go:embed schema.json
+var schema []byte
+
+// Options limit what requests may ask for
+type Options struct {
+	Engines      int           // Searches run at once, 0 for one per CPU
+	DefaultDepth int           // Depth when a search request doesn't give one
+	MaxDepth     int           // Deepest search a request may ask for
+	MaxTime      time.Duration // Longest search a request may ask for, also the default
+	MaxMultiPV   int           // Most lines a search request may ask for
+	MaxBodyBytes int64         // Largest request body accepted
+	QueueTimeout time.Duration // How long a search waits for a free engine before giving up
+	MaxStreams   int           // Live analysis connections open at once, 0 for one per engine
+	IdleTimeout  time.Duration // How long a live analysis connection may stay silent after its analysis, 0 for no limit
+}
+
+// DefaultOptions are limits suited to an interactive front-end
+func DefaultOptions() Options {
+	return Options{
+		DefaultDepth: 4,
+		MaxDepth:     8,
+		MaxTime:      10 * time.Second,
+		MaxMultiPV:   5,
+		MaxBodyBytes: 16 << 10,
+		QueueTimeout: 5 * time.Second,
+		IdleTimeout:  2 * time.Minute,
+	}
+}
+
+// Server handles the API. Searches share a fixed pool of engines, so no more
+// than Options.Engines run at once. Each live analysis connection has an
+// engine of its own, and no more than Options.MaxStreams are open at once
+type Server struct {
+	options Options
+	engines chan *search.Engine
+	streams chan struct{} // Holds a token for each open analysis connection
+	mux     *http.ServeMux
+}
+
+// errBusy is returned when no engine becomes free within the queue timeout
+var errBusy = errors.New("all engines are busy, try again later")
+
+// New creates a server with its engine pool
+func New(options Options) *Server {
+	if options.Engines <= 0 {
+		options.Engines = runtime.NumCPU()
+	}
+	if options.MaxStreams <= 0 {
+		options.MaxStreams = options.Engines
+	}
+	s := &Server{
+		options: options,
+		engines: make(chan *search.Engine, options.Engines),
+		streams: make(chan struct{}, options.MaxStreams),
+		mux:     http.NewServeMux(),
+	}
+	for i := 0; i < options.Engines; i++ {
+		s.engines <- search.NewEngine()
+	}
+
+	s.mux.HandleFunc("POST /api/moves", s.handleMoves)
+	s.mux.HandleFunc("POST /api/move", s.handleMove)
+	s.mux.HandleFunc("POST /api/eval", s.handleEval)
+	s.mux.HandleFunc("POST /api/search", s.handleSearch)
+	s.mux.HandleFunc("GET /api/analysis", s.handleAnalysis)
+	s.mux.HandleFunc("GET /api/schema", s.handleSchema)
+	return s
+}
+
+// ServeHTTP routes a request to its handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handleMoves lists the legal moves of a position
+func (s *Server) handleMoves(w http.ResponseWriter, r *http.Request) {
+	var req PositionRequest
+	g, ok := s.readPosition(w, r, &req, &req.FEN)
+	if !ok {
+		return
+	}
+	moves := g.GenerateAllLegalMoves()
+	resp := MovesResponse{PositionInfo: positionInfo(g), Moves: make([]MoveInfo, len(moves))}
+	for i, move := range moves {
+		resp.Moves[i] = moveInfo(g, move)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleMove plays a move and returns the new position
+func (s *Server) handleMove(w http.ResponseWriter, r *http.Request) {
+	var req MoveRequest
+	g, ok := s.readPosition(w, r, &req, &req.FEN)
+	if !ok {
+		return
+	}
+	move, err := findMove(g, req.Move)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	info := moveInfo(g, move)
+	g.MakeMove(move)
+	writeJSON(w, http.StatusOK, MoveResponse{Move: info, PositionInfo: positionInfo(g)})
+}
+
+// handleEval breaks down the static evaluation of a position
+func (s *Server) handleEval(w http.ResponseWriter, r *http.Request) {
+	var req PositionRequest
+	g, ok := s.readPosition(w, r, &req, &req.FEN)
+	if !ok {
+		return
+	}
+	trace := eval.EvaluateTrace(g)
+	resp := EvalResponse{
+		Score:    eval.Evaluate(g),
+		Phase:    trace.Phase,
+		Terms:    []EvalTerm{},
+		Terminal: trace.Terminal,
+		Endgame:  trace.Endgame,
+		Scale:    trace.Scale,
+		ScaleBy:  trace.ScaleBy,
+	}
+	if !trace.Terminal && trace.Endgame == "" {
+		for term, name := range eval.EvalTermNames {
+			resp.Terms = append(resp.Terms, EvalTerm{
+				Name:  name,
+				White: trace.Terms[term][board.White],
+				Black: trace.Terms[term][board.Black],
+				Net:   trace.Net(term),
+			})
+		}
+	}
+	if eval.CurrentNetwork != nil {
+		score := eval.EvaluateNNUE(g, eval.CurrentNetwork)
+		resp.NNUE = &score
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleSearch runs a search bounded by depth and time on a pooled engine
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	var req SearchRequest
+	g, ok := s.readPosition(w, r, &req, &req.FEN)
+	if !ok {
+		return
+	}
+
+	if err := s.checkLimits(req.Depth, req.TimeMS, req.MultiPV); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	depth, timeLimit, multiPV := s.options.DefaultDepth, s.options.MaxTime, 1
+	if req.Depth > 0 {
+		depth = req.Depth
+	}
+	if req.TimeMS > 0 {
+		timeLimit = time.Duration(req.TimeMS) * time.Millisecond
+	}
+	if req.MultiPV > 0 {
+		multiPV = req.MultiPV
+	}
+
+	if over, result := g.IsGameOver(); over {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("game is over: %s", result))
+		return
+	}
+
+	engine, err := s.acquireEngine(r.Context())
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer s.releaseEngine(engine)
+
+	engine.MaxDepth, engine.TimeLimit = depth, timeLimit
+	results := engine.SearchMultiPV(g, multiPV)
+	resp := SearchResponse{Lines: make([]SearchLine, len(results)), Depth: depth}
+	for i, result := range results {
+		resp.Lines[i] = SearchLine{Move: moveInfo(g, result.BestMove), Score: result.Score}
+		resp.Nodes, resp.TimeMS = result.NodesVisited, result.Duration.Milliseconds()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleSchema sends the JSON Schema of the request and response bodies
+func (s *Server) handleSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(schema)
+}
+
+// checkLimits rejects a depth, time or line count beyond the server's limits.
+// Zero means the default and is always allowed
+func (s *Server) checkLimits(depth, timeMS, multiPV int) error {
+	switch {
+	case depth < 0 || depth > s.options.MaxDepth:
+		return fmt.Errorf("depth must be between 1 and %d", s.options.MaxDepth)
+	case timeMS < 0 || time.Duration(timeMS)*time.Millisecond > s.options.MaxTime:
+		return fmt.Errorf("timeMs must be between 1 and %d", s.options.MaxTime.Milliseconds())
+	case multiPV < 0 || multiPV > s.options.MaxMultiPV:
+		return fmt.Errorf("multiPv must be between 1 and %d", s.options.MaxMultiPV)
+	}
+	return nil
+}
+
+// acquireEngine takes an engine from the pool, waiting up to the queue timeout
+func (s *Server) acquireEngine(ctx context.Context) (*search.Engine, error) {
+	timer := time.NewTimer(s.options.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case engine := <-s.engines:
+		return engine, nil
+	case <-timer.C:
+		return nil, errBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// releaseEngine returns an engine to the pool
+func (s *Server) releaseEngine(engine *search.Engine) {
+	s.engines <- engine
+}
+
+// readPosition decodes a request body into req and parses the FEN it holds,
+// sending an error response if either fails
+func (s *Server) readPosition(w http.ResponseWriter, r *http.Request, req any, fen *string) (*game.GameState, bool) {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.options.MaxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body over %d bytes", tooLarge.Limit))
+		} else {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %v", err))
+		}
+		return nil, false
+	}
+
+	g, err := parsePosition(fen)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	return g, true
+}
+
+// parsePosition parses a FEN, taking an empty one as the starting position
+// and filling it in
+func parsePosition(fen *string) (*game.GameState, error) {
+	if strings.TrimSpace(*fen) == "" {
+		*fen = game.StartingFEN
+	}
+	g, err := game.NewGameFromFEN(*fen)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FEN: %v", err)
+	}
+	return g, nil
+}
+
+// findMove finds the legal move written in coordinate notation or SAN
+func findMove(g *game.GameState, text string) (board.Move, error) {
+	for _, move := range g.GenerateAllLegalMoves() {
+		if uci.MoveString(move) == text {
+			return move, nil
+		}
+	}
+	return g.ParseSAN(text)
+}
+
+// positionInfo describes a position
+func positionInfo(g *game.GameState) PositionInfo {
+	over, result := g.IsGameOver()
+	return PositionInfo{
+		FEN:        g.FEN(),
+		SideToMove: strings.ToLower(board.ColorName(g.CurrentPlayer)),
+		InCheck:    g.Board.IsInCheck(g.CurrentPlayer),
+		GameOver:   over,
+		Result:     result,
+	}
+}
+
+// moveInfo writes a move in both notations
+func moveInfo(g *game.GameState, move board.Move) MoveInfo {
+	return MoveInfo{UCI: uci.MoveString(move), SAN: g.MoveToSAN(move)}
+}
+
+// writeJSON sends a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError sends an error as JSON
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"chess-engine/game"
+)
+
+// testOptions are small limits so tests run quickly
+func testOptions() Options {
+	options := DefaultOptions()
+	options.Engines = 1
+	options.MaxDepth = 3
+	options.MaxTime = 2 * time.Second
+	options.MaxMultiPV = 3
+	options.MaxBodyBytes = 512
+	options.QueueTimeout = 100 * time.Millisecond
+	return options
+}
+
+// post sends body to path and decodes the response into resp, returning the status
+func post(t *testing.T, ts *httptest.Server, path, body string, resp any) int {
+	t.Helper()
+	r, err := http.Post(ts.URL+path, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode == http.StatusOK && resp != nil {
+		if err := json.NewDecoder(r.Body).Decode(resp); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	} else if r.StatusCode != http.StatusOK {
+		var errResp ErrorResponse
+		if err := json.NewDecoder(r.Body).Decode(&errResp); err != nil || errResp.Error == "" {
+			t.Errorf("%s: status %d without an error message", path, r.StatusCode)
+		}
+	}
+	return r.StatusCode
+}
+
+func TestMoves(t *testing.T) {
+	ts := httptest.NewServer(New(testOptions()))
+	defer ts.Close()
+
+	var resp MovesResponse
+	if status := post(t, ts, "/api/moves", `{}`, &resp); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if resp.FEN != game.StartingFEN || resp.SideToMove != "white" || len(resp.Moves) != 20 {
+		t.Errorf("start position: %s, %s to move, %d moves", resp.FEN, resp.SideToMove, len(resp.Moves))
+	}
+
+	mated := `{"fen": "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3"}`
+	if status := post(t, ts, "/api/moves", mated, &resp); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if !resp.GameOver || !resp.InCheck || len(resp.Moves) != 0 {
+		t.Errorf("mated position: game over %v, in check %v, %d moves", resp.GameOver, resp.InCheck, len(resp.Moves))
+	}
+}
+
+func TestMove(t *testing.T) {
+	ts := httptest.NewServer(New(testOptions()))
+	defer ts.Close()
+
+	for _, move := range []string{"g1f3", "Nf3"} {
+		var resp MoveResponse
+		if status := post(t, ts, "/api/move", `{"move": "`+move+`"}`, &resp); status != http.StatusOK {
+			t.Fatalf("%s: status %d", move, status)
+		}
+		if resp.Move.UCI != "g1f3" || resp.Move.SAN != "Nf3" || resp.SideToMove != "black" {
+			t.Errorf("%s: played %+v, %s to move", move, resp.Move, resp.SideToMove)
+		}
+	}
+}
+
+func TestBadInput(t *testing.T) {
+	ts := httptest.NewServer(New(testOptions()))
+	defer ts.Close()
+
+	tests := []struct {
+		path, body string
+	}{
+		{"/api/moves", `{"fen": "not a fen"}`},
+		{"/api/moves", `{"fen": "8/8/8/8/8/8/8/8 w - - 0 1"}`},
+		{"/api/moves", `{"fen": `},
+		{"/api/moves", `{"position": "startpos"}`},
+		{"/api/eval", `{"fen": "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP w KQkq - 0 1"}`},
+		{"/api/move", `{"move": "e2e5"}`},
+		{"/api/move", `{"move": "Nc6"}`},
+		{"/api/move", `{"move": ""}`},
+		{"/api/move", `{"fen": "nonsense", "move": "e2e4"}`},
+		{"/api/search", `{"fen": "x"}`},
+		{"/api/search", `{"depth": 4}`},
+		{"/api/search", `{"depth": -1}`},
+		{"/api/search", `{"timeMs": 2001}`},
+		{"/api/search", `{"multiPv": 4}`},
+		{"/api/search", `{"fen": "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3"}`},
+	}
+	for _, test := range tests {
+		if status := post(t, ts, test.path, test.body, nil); status != http.StatusBadRequest {
+			t.Errorf("%s %s: status %d, want %d", test.path, test.body, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	ts := httptest.NewServer(New(testOptions()))
+	defer ts.Close()
+
+	body := `{"fen": "` + strings.Repeat(" ", 1024) + `"}`
+	for _, path := range []string{"/api/moves", "/api/move", "/api/eval", "/api/search"} {
+		if status := post(t, ts, path, body, nil); status != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: status %d, want %d", path, status, http.StatusRequestEntityTooLarge)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	ts := httptest.NewServer(New(testOptions()))
+	defer ts.Close()
+
+	var resp EvalResponse
+	if status := post(t, ts, "/api/eval", `{}`, &resp); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if len(resp.Terms) == 0 || resp.Phase != 1 {
+		t.Errorf("start position: %d terms, phase %v", len(resp.Terms), resp.Phase)
+	}
+
+	if status := post(t, ts, "/api/eval", `{"fen": "8/8/8/4k3/8/8/3K4/8 w - - 0 1"}`, &resp); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if resp.Score != 0 || len(resp.Terms) != 0 || resp.Endgame == "" {
+		t.Errorf("bare kings: score %d, %d terms, endgame %q", resp.Score, len(resp.Terms), resp.Endgame)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ts := httptest.NewServer(New(testOptions()))
+	defer ts.Close()
+
+	var resp SearchResponse
+	if status := post(t, ts, "/api/search", `{"depth": 2, "multiPv": 3}`, &resp); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if resp.Depth != 2 || len(resp.Lines) != 3 {
+		t.Fatalf("depth %d with %d lines, want depth 2 with 3", resp.Depth, len(resp.Lines))
+	}
+	for i := 1; i < len(resp.Lines); i++ {
+		if resp.Lines[i].Score > resp.Lines[i-1].Score {
+			t.Errorf("lines out of order: %+v", resp.Lines)
+		}
+	}
+
+	// Mate in one for black, scored from white's side
+	mateIn1 := `{"fen": "rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq - 0 2", "depth": 2}`
+	if status := post(t, ts, "/api/search", mateIn1, &resp); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if resp.Lines[0].Move.SAN != "Qh4#" || resp.Lines[0].Score > -20000 {
+		t.Errorf("mate in one: %+v", resp.Lines[0])
+	}
+}
+
+func TestEnginesBusy(t *testing.T) {
+	s := New(testOptions())
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	// Hold the only engine, as a long search would
+	engine, err := s.acquireEngine(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if status := post(t, ts, "/api/search", `{"depth": 1}`, nil); status != http.StatusServiceUnavailable {
+		t.Errorf("status %d with no engine free, want %d", status, http.StatusServiceUnavailable)
+	}
+	if waited := time.Since(start); waited < s.options.QueueTimeout {
+		t.Errorf("gave up after %v, before the queue timeout", waited)
+	}
+
+	// Requests that don't search still work
+	if status := post(t, ts, "/api/moves", `{}`, nil); status != http.StatusOK {
+		t.Errorf("moves: status %d while engines are busy", status)
+	}
+
+	s.releaseEngine(engine)
+	if status := post(t, ts, "/api/search", `{"depth": 1}`, nil); status != http.StatusOK {
+		t.Errorf("status %d once the engine is free", status)
+	}
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The server side of the WebSocket protocol (RFC 6455), as much of it as the
+// analysis stream needs: the opening handshake, text messages that may come
+// in fragments, and the ping, pong and close control frames. Extensions and
+// subprotocols are never negotiated
+
+// websocketGUID is appended to the client's key to make the accept key
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Frame opcodes
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Close status codes
+const (
+	closeNormal        = 1000
+	closeProtocolError = 1002
+	closeUnsupported   = 1003
+	closeTooBig        = 1009
+)
+
+// websocketWriteTimeout bounds each write, so a client that stops reading
+// can't hold up the analysis sending to it
+const websocketWriteTimeout = 10 * time.Second
+
+// websocketConn is an open WebSocket. Reads must come from one goroutine;
+// writes may come from any
+type websocketConn struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	maxMessage int64 // Largest message accepted from the client
+	writeMu    sync.Mutex
+	closeSent  bool // Nothing may follow a close frame
+}
+
+// closeError is a protocol failure that ends the connection with a status code
+type closeError struct {
+	code   int
+	reason string
+}
+
+func (e *closeError) Error() string {
+	return fmt.Sprintf("websocket closed with %d: %s", e.code, e.reason)
+}
+
+// upgradeWebsocket answers the opening handshake and takes over the
+// connection, sending an error response if the request isn't a valid one
+func upgradeWebsocket(w http.ResponseWriter, r *http.Request, maxMessage int64) (*websocketConn, bool) {
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		writeError(w, http.StatusBadRequest, errors.New("expected a WebSocket upgrade request"))
+		return nil, false
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeError(w, http.StatusUpgradeRequired, errors.New("unsupported WebSocket version"))
+		return nil, false
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if nonce, err := base64.StdEncoding.DecodeString(key); err != nil || len(nonce) != 16 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid Sec-WebSocket-Key"))
+		return nil, false
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("can't take over the connection: %v", err))
+		return nil, false
+	}
+	conn.SetDeadline(time.Time{})
+
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, false
+	}
+	return &websocketConn{conn: conn, reader: rw.Reader, maxMessage: maxMessage}, true
+}
+
+// headerHasToken reports whether a comma-separated header holds a token,
+// ignoring case
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next text message, joining its fragments and
+// answering any control frames that come first. It returns io.EOF once the
+// client closes the connection
+func (c *websocketConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	fragmented := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, c.fail(err)
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			// Echo the client's status code, as the protocol asks
+			if len(payload) >= 2 {
+				payload = payload[:2]
+			}
+			c.writeFrame(opClose, payload)
+			return nil, io.EOF
+		case opText:
+			if fragmented {
+				return nil, c.fail(&closeError{closeProtocolError, "new message inside a fragmented one"})
+			}
+		case opBinary:
+			return nil, c.fail(&closeError{closeUnsupported, "only text messages are accepted"})
+		case opContinuation:
+			if !fragmented {
+				return nil, c.fail(&closeError{closeProtocolError, "continuation without a message"})
+			}
+		default:
+			return nil, c.fail(&closeError{closeProtocolError, fmt.Sprintf("unknown opcode %d", opcode)})
+		}
+
+		if int64(len(message)+len(payload)) > c.maxMessage {
+			return nil, c.fail(&closeError{closeTooBig, fmt.Sprintf("message over %d bytes", c.maxMessage)})
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+		fragmented = true
+	}
+}
+
+// readFrame reads one frame and unmasks its payload
+func (c *websocketConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, &closeError{closeProtocolError, "reserved bits set"}
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, &closeError{closeProtocolError, "client frames must be masked"}
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if opcode >= opClose && (!fin || length > 125) {
+		return false, 0, nil, &closeError{closeProtocolError, "invalid control frame"}
+	}
+	if length > uint64(c.maxMessage) {
+		return false, 0, nil, &closeError{closeTooBig, fmt.Sprintf("message over %d bytes", c.maxMessage)}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// fail closes the connection with the status code of a protocol failure
+func (c *websocketConn) fail(err error) error {
+	var failure *closeError
+	if errors.As(err, &failure) {
+		c.Close(failure.code, failure.reason)
+	}
+	return err
+}
+
+// WriteJSON sends a value as a JSON text message
+func (c *websocketConn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(opText, data)
+}
+
+// writeFrame sends one unfragmented frame. Server frames are never masked
+func (c *websocketConn) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	frame = append(frame, payload...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return net.ErrClosed
+	}
+	c.closeSent = opcode == opClose
+	c.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// SetReadDeadline limits how long the next reads may wait
+func (c *websocketConn) SetReadDeadline(deadline time.Time) error {
+	return c.conn.SetReadDeadline(deadline)
+}
+
+// Close sends a close frame with a status code and closes the connection
+func (c *websocketConn) Close(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	c.writeFrame(opClose, append(payload, reason...))
+	return c.conn.Close()
+}
@@ -0,0 +1,381 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"chess-engine/game"
+)
+
+// The key and accept key from the example in RFC 6455
+const (
+	testWebsocketKey    = "dGhlIHNhbXBsZSBub25jZQ=="
+	testWebsocketAccept = "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="
+)
+
+// wsClient is the client end of an analysis connection, speaking raw frames
+type wsClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dialAnalysis opens /api/analysis and checks the handshake
+func dialAnalysis(t *testing.T, ts *httptest.Server) *wsClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	request := "GET /api/analysis HTTP/1.1\r\n" +
+		"Host: " + conn.RemoteAddr().String() + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + testWebsocketKey + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		t.Fatal(err)
+	}
+	c := &wsClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	resp, err := http.ReadResponse(c.reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status %d", resp.StatusCode)
+	}
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != testWebsocketAccept {
+		t.Fatalf("accept key %q, want %q", accept, testWebsocketAccept)
+	}
+	return c
+}
+
+// writeFrame sends a masked frame
+func (c *wsClient) writeFrame(fin bool, opcode byte, payload []byte) {
+	c.t.Helper()
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, 0x80|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	mask := [4]byte{0x37, 0xFA, 0x21, 0x3D}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// send sends a value as one JSON text message
+func (c *wsClient) send(v any) {
+	c.t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.writeFrame(true, opText, data)
+}
+
+// readFrame reads one unmasked server frame
+func (c *wsClient) readFrame() (opcode byte, payload []byte) {
+	c.t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	if header[0]&0x80 == 0 || header[1]&0x80 != 0 {
+		c.t.Fatalf("server frames must be whole and unmasked: %x", header)
+	}
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		io.ReadFull(c.reader, extended[:])
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		io.ReadFull(c.reader, extended[:])
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+// streamMessage holds any message the analysis stream sends
+type streamMessage struct {
+	Type  string      `json:"type"`
+	Depth int         `json:"depth"`
+	PV    []MoveInfo  `json:"pv"`
+	Best  *SearchLine `json:"best"`
+	Error string      `json:"error"`
+}
+
+// receive reads the next text message
+func (c *wsClient) receive() streamMessage {
+	c.t.Helper()
+	opcode, payload := c.readFrame()
+	if opcode != opText {
+		c.t.Fatalf("got opcode %d (%q), want a text message", opcode, payload)
+	}
+	var msg streamMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// receiveAnalysis reads one analysis to its end, checking that the depths
+// streamed run from 1 without gaps, each with a line, and that the end reports
+// the best move of the last of them
+func (c *wsClient) receiveAnalysis() (iterations []streamMessage, end streamMessage) {
+	c.t.Helper()
+	for {
+		msg := c.receive()
+		switch msg.Type {
+		case "iteration":
+			if msg.Depth != len(iterations)+1 || len(msg.PV) == 0 {
+				c.t.Fatalf("iteration %+v after %d others", msg, len(iterations))
+			}
+			iterations = append(iterations, msg)
+		case "done", "stopped":
+			if msg.Depth != len(iterations) {
+				c.t.Errorf("%s at depth %d after %d iterations", msg.Type, msg.Depth, len(iterations))
+			}
+			if len(iterations) > 0 && (msg.Best == nil || msg.Best.Move != iterations[len(iterations)-1].PV[0]) {
+				c.t.Errorf("%s with best %+v, last iteration %+v", msg.Type, msg.Best, iterations[len(iterations)-1])
+			}
+			return iterations, msg
+		default:
+			c.t.Fatalf("unexpected message %+v", msg)
+		}
+	}
+}
+
+// checkLine plays a streamed line out from fen, checking each move is legal
+// and written the way the server writes it
+func checkLine(t *testing.T, fen string, line []MoveInfo) {
+	t.Helper()
+	g, err := game.NewGameFromFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, info := range line {
+		move, ok := g.ParseMove(info.UCI)
+		if !ok {
+			t.Fatalf("move %d of %+v isn't legal", i+1, line)
+		}
+		if want := moveInfo(g, move); info != want {
+			t.Errorf("move %d is %+v, want %+v", i+1, info, want)
+		}
+		g.MakeMove(move)
+	}
+}
+
+// expectClose reads frames up to the server's close frame and checks its code
+func (c *wsClient) expectClose(code int) {
+	c.t.Helper()
+	for {
+		opcode, payload := c.readFrame()
+		if opcode != opClose {
+			continue
+		}
+		if len(payload) < 2 {
+			c.t.Fatalf("close frame without a status code")
+		}
+		if got := int(binary.BigEndian.Uint16(payload)); got != code {
+			c.t.Errorf("closed with %d (%s), want %d", got, payload[2:], code)
+		}
+		return
+	}
+}
+
+func TestWebsocketHandshakeRejected(t *testing.T) {
+	ts := httptest.NewServer(New(testOptions()))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"not an upgrade", map[string]string{"Sec-WebSocket-Key": testWebsocketKey, "Sec-WebSocket-Version": "13"},
+			http.StatusBadRequest},
+		{"bad key", map[string]string{"Upgrade": "websocket", "Connection": "Upgrade",
+			"Sec-WebSocket-Key": "c2hvcnQ=", "Sec-WebSocket-Version": "13"}, http.StatusBadRequest},
+		{"missing key", map[string]string{"Upgrade": "websocket", "Connection": "Upgrade",
+			"Sec-WebSocket-Version": "13"}, http.StatusBadRequest},
+		{"old version", map[string]string{"Upgrade": "websocket", "Connection": "Upgrade",
+			"Sec-WebSocket-Key": testWebsocketKey, "Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", ts.URL+"/api/analysis", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, value := range test.headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, resp.StatusCode, test.status)
+		}
+		if test.status == http.StatusUpgradeRequired && resp.Header.Get("Sec-WebSocket-Version") != "13" {
+			t.Errorf("%s: supported version not given", test.name)
+		}
+	}
+}
+
+func TestWebsocketFrames(t *testing.T) {
+	ts := httptest.NewServer(New(testOptions()))
+	defer ts.Close()
+	c := dialAnalysis(t, ts)
+
+	// A fragmented message with a ping between its fragments
+	message := []byte(`{"type": "position", "depth": 2}`)
+	c.writeFrame(false, opText, message[:10])
+	c.writeFrame(true, opPing, []byte("are you there"))
+	c.writeFrame(false, opContinuation, message[10:20])
+	c.writeFrame(true, opContinuation, message[20:])
+
+	if opcode, payload := c.readFrame(); opcode != opPong || string(payload) != "are you there" {
+		t.Fatalf("got opcode %d (%q), want the pong", opcode, payload)
+	}
+	iterations, end := c.receiveAnalysis()
+	if len(iterations) != 2 || end.Type != "done" {
+		t.Errorf("%d iterations ending %s, want 2 ending done", len(iterations), end.Type)
+	}
+
+	// Nothing ends the lines early from the starting position, so each is
+	// as long as its depth
+	for _, iteration := range iterations {
+		if len(iteration.PV) != iteration.Depth {
+			t.Errorf("depth %d with a %d move line", iteration.Depth, len(iteration.PV))
+		}
+		checkLine(t, game.StartingFEN, iteration.PV)
+	}
+
+	// Unsolicited pongs are ignored, and bad messages answered without closing
+	c.writeFrame(true, opPong, nil)
+	c.send(map[string]string{"type": "analyse"})
+	if msg := c.receive(); msg.Type != "error" || msg.Error == "" {
+		t.Errorf("unknown type: got %+v, want an error", msg)
+	}
+	c.send(map[string]string{"type": "position", "fen": "8/8/8/8/8/8/8/8 w - - 0 1"})
+	if msg := c.receive(); msg.Type != "error" {
+		t.Errorf("bad FEN: got %+v, want an error", msg)
+	}
+
+	// The close handshake echoes the client's status code
+	c.writeFrame(true, opClose, binary.BigEndian.AppendUint16(nil, closeNormal))
+	c.expectClose(closeNormal)
+}
+
+func TestWebsocketProtocolErrors(t *testing.T) {
+	ts := httptest.NewServer(New(testOptions()))
+	defer ts.Close()
+	limit := int(testOptions().MaxBodyBytes)
+
+	tests := []struct {
+		name  string
+		write func(c *wsClient)
+		code  int
+	}{
+		{"oversized message", func(c *wsClient) {
+			c.writeFrame(true, opText, []byte(strings.Repeat(" ", limit+1)))
+		}, closeTooBig},
+		{"oversized fragments", func(c *wsClient) {
+			c.writeFrame(false, opText, []byte(strings.Repeat(" ", limit/2+1)))
+			c.writeFrame(true, opContinuation, []byte(strings.Repeat(" ", limit/2+1)))
+		}, closeTooBig},
+		{"binary message", func(c *wsClient) {
+			c.writeFrame(true, opBinary, []byte{1, 2, 3})
+		}, closeUnsupported},
+		{"continuation without a message", func(c *wsClient) {
+			c.writeFrame(true, opContinuation, []byte("{}"))
+		}, closeProtocolError},
+		{"unmasked frame", func(c *wsClient) {
+			c.conn.Write([]byte{0x80 | opText, 2, '{', '}'})
+		}, closeProtocolError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := dialAnalysis(t, ts)
+			test.write(c)
+			c.expectClose(test.code)
+		})
+	}
+}
+
+func TestAnalysisStopAndReplace(t *testing.T) {
+	options := testOptions()
+	options.MaxDepth = 20
+	options.MaxTime = 20 * time.Second
+	ts := httptest.NewServer(New(options))
+	defer ts.Close()
+	c := dialAnalysis(t, ts)
+
+	// An analysis far too deep to finish is cut short by stop, and the
+	// iteration it was in the middle of isn't streamed
+	c.send(AnalysisRequest{Type: "position"})
+	time.Sleep(200 * time.Millisecond)
+	c.send(AnalysisRequest{Type: "stop"})
+	if _, end := c.receiveAnalysis(); end.Type != "stopped" {
+		t.Errorf("ended %s after stop, want stopped", end.Type)
+	}
+
+	// A new position stops the running analysis and starts on the new one
+	c.send(AnalysisRequest{Type: "position"})
+	time.Sleep(200 * time.Millisecond)
+	mateIn1 := "rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq - 0 2"
+	c.send(AnalysisRequest{Type: "position", FEN: mateIn1, Depth: 2})
+	if _, end := c.receiveAnalysis(); end.Type != "stopped" {
+		t.Errorf("first analysis ended %s, want stopped", end.Type)
+	}
+	iterations, end := c.receiveAnalysis()
+	if end.Type != "done" || len(iterations) != 2 {
+		t.Fatalf("second analysis: %d iterations ending %s, want 2 ending done", len(iterations), end.Type)
+	}
+	if end.Best.Move.SAN != "Qh4#" {
+		t.Errorf("second analysis found %s, want Qh4#", end.Best.Move.SAN)
+	}
+	if pv := iterations[1].PV; len(pv) != 1 {
+		t.Errorf("line %+v goes on past mate", pv)
+	}
+
+	// Stop with nothing running does nothing
+	c.send(AnalysisRequest{Type: "stop"})
+	c.send(AnalysisRequest{Type: "position", FEN: mateIn1, Depth: 1})
+	if iterations, end := c.receiveAnalysis(); end.Type != "done" || len(iterations) != 1 {
+		t.Errorf("after an idle stop: %d iterations ending %s", len(iterations), end.Type)
+	}
+}
@@ -0,0 +1,1274 @@
+// Package tablebase probes Syzygy endgame tablebases.
+package tablebase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+// Syzygy WDL results, from the side to move's point of view
+const (
+	WDLLoss        = -2
+	WDLBlessedLoss = -1 // Lost, but saved by the 50 move rule
+	WDLDraw        = 0
+	WDLCursedWin   = 1 // Won, but spoilt by the 50 move rule
+	WDLWin         = 2
+)
+
+// Table kinds
+const (
+	tbWDL = iota
+	tbDTZ
+)
+
+// Per-table flags stored in the files
+const (
+	tbFlagSTM         = 1
+	tbFlagMapped      = 2
+	tbFlagWinPlies    = 4
+	tbFlagLossPlies   = 8
+	tbFlagWide        = 16
+	tbFlagSingleValue = 128
+)
+
+// Files are read into memory when smaller than this, otherwise read on demand
+const tbInMemoryLimit = 64 << 20
+
+var tbMagic = [2][4]byte{
+	{0x71, 0xE8, 0x23, 0x5D}, // WDL
+	{0xD7, 0x66, 0x0C, 0xA5}, // DTZ
+}
+
+// Piece codes used inside the files: 1-6 white pawn to king, 9-14 black
+var tbPieceCodes = map[int]int{
+	board.Pawn: 1, board.Knight: 2, board.Bishop: 3, board.Rook: 4, board.Queen: 5, board.King: 6,
+}
+
+// Encoding tables, filled in by init
+var (
+	tbBinomial      [6][64]uint64
+	tbMapPawns      [64]int
+	tbLeadPawnIdx   [6][64]uint64
+	tbLeadPawnsSize [6][4]uint64
+	tbMapB1H1H7     [64]int
+	tbMapA1D1D4     [64]int
+	tbMapKK         [10][64]int
+)
+
+// Squares here are numbered a1 = 0 to h8 = 63
+func tbFile(sq int) int         { return sq & 7 }
+func tbRank(sq int) int         { return sq >> 3 }
+func tbOffA1H8(sq int) int      { return tbRank(sq) - tbFile(sq) }
+func tbSquare(row, col int) int { return (7-row)*8 + col }
+
+func init() {
+	code := 0
+	for s := 0; s < 64; s++ {
+		if tbOffA1H8(s) < 0 {
+			tbMapB1H1H7[s] = code
+			code++
+		}
+	}
+
+	var diagonal []int
+	code = 0
+	for s := 0; s <= 27; s++ { // a1 to d4
+		if tbOffA1H8(s) < 0 && tbFile(s) <= 3 {
+			tbMapA1D1D4[s] = code
+			code++
+		} else if tbOffA1H8(s) == 0 && tbFile(s) <= 3 {
+			diagonal = append(diagonal, s)
+		}
+	}
+	for _, s := range diagonal {
+		tbMapA1D1D4[s] = code
+		code++
+	}
+
+	// All legal placements of two kings with the first in the a1-d1-d4 triangle
+	type kingPair struct{ idx, sq int }
+	var bothOnDiagonal []kingPair
+	code = 0
+	for idx := 0; idx < 10; idx++ {
+		for s1 := 0; s1 <= 27; s1++ {
+			if tbMapA1D1D4[s1] != idx || (idx == 0 && s1 != 1) { // b1 maps to 0
+				continue
+			}
+			for s2 := 0; s2 < 64; s2++ {
+				if abs(tbFile(s1)-tbFile(s2)) <= 1 && abs(tbRank(s1)-tbRank(s2)) <= 1 {
+					continue // Kings touching or on the same square
+				}
+				if tbOffA1H8(s1) == 0 && tbOffA1H8(s2) > 0 {
+					continue // First on the diagonal, second above it
+				}
+				if tbOffA1H8(s1) == 0 && tbOffA1H8(s2) == 0 {
+					bothOnDiagonal = append(bothOnDiagonal, kingPair{idx, s2})
+					continue
+				}
+				tbMapKK[idx][s2] = code
+				code++
+			}
+		}
+	}
+	for _, p := range bothOnDiagonal {
+		tbMapKK[p.idx][p.sq] = code
+		code++
+	}
+
+	tbBinomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < 6 && k <= n; k++ {
+			if k > 0 {
+				tbBinomial[k][n] += tbBinomial[k-1][n-1]
+			}
+			if k < n {
+				tbBinomial[k][n] += tbBinomial[k][n-1]
+			}
+		}
+	}
+
+	available := 47
+	for leadPawns := 1; leadPawns <= 5; leadPawns++ {
+		for f := 0; f < 4; f++ {
+			var idx uint64
+			for r := 1; r <= 6; r++ { // Ranks 2 to 7
+				sq := r*8 + f
+				if leadPawns == 1 {
+					tbMapPawns[sq] = available
+					available--
+					tbMapPawns[sq^7] = available
+					available--
+				}
+				tbLeadPawnIdx[leadPawns][sq] = idx
+				idx += tbBinomial[leadPawns-1][tbMapPawns[sq]]
+			}
+			tbLeadPawnsSize[leadPawns][f] = idx
+		}
+	}
+}
+
+// tbPairs is the compressed data for one side and pawn file of a table
+type tbPairs struct {
+	flags           byte
+	pieces          [7]int
+	groupLen        [8]int
+	groupIdx        [8]uint64
+	sizeofBlock     uint64
+	span            uint64
+	sparseIndexSize uint64
+	numBlocks       uint64
+	blockLengthSize uint64
+	maxSymLen       int
+	minSymLen       int
+	lowestSym       []uint16
+	base64          []uint64
+	symlen          []int
+	btree           []byte // Three bytes per symbol: left and right child, 12 bits each
+	sparseIndex     int64  // File offsets of the remaining data
+	blockLength     int64
+	data            int64
+	mapIdx          [4]int
+}
+
+func (d *tbPairs) left(sym int) int {
+	return int(d.btree[3*sym+1]&0xF)<<8 | int(d.btree[3*sym])
+}
+
+func (d *tbPairs) right(sym int) int {
+	return int(d.btree[3*sym+2])<<4 | int(d.btree[3*sym+1]>>4)
+}
+
+// syzygyTable is one WDL or DTZ file
+type syzygyTable struct {
+	kind            int
+	name            string
+	key, key2       string // Material with white as the listed side, and with colours swapped
+	pieceCount      int
+	hasPawns        bool
+	hasUniquePieces bool
+	pawnCount       [2]int // Leading colour first
+	path            string
+
+	once    sync.Once
+	loadErr error
+	file    io.ReaderAt
+	closer  io.Closer
+	items   [2][4]tbPairs // [side][file]
+	mapData int64         // DTZ value maps
+}
+
+func newSyzygyTable(kind int, name, path string) *syzygyTable {
+	t := &syzygyTable{kind: kind, name: name, path: path}
+	sides := strings.SplitN(name, "v", 2)
+	t.key = name
+	t.key2 = sides[1] + "v" + sides[0]
+
+	var counts [2]map[byte]int
+	for c, side := range sides {
+		counts[c] = make(map[byte]int)
+		for i := 0; i < len(side); i++ {
+			counts[c][side[i]]++
+			t.pieceCount++
+		}
+	}
+	t.hasPawns = counts[0]['P']+counts[1]['P'] > 0
+	for c := 0; c < 2; c++ {
+		for _, letter := range []byte("PNBRQ") {
+			if counts[c][letter] == 1 {
+				t.hasUniquePieces = true
+			}
+		}
+	}
+
+	// The side with fewer pawns leads, as that compresses better
+	white, black := counts[0]['P'], counts[1]['P']
+	if black == 0 || (white > 0 && black >= white) {
+		t.pawnCount = [2]int{white, black}
+	} else {
+		t.pawnCount = [2]int{black, white}
+	}
+	return t
+}
+
+func (t *syzygyTable) get(stm, file int) *tbPairs {
+	sides := 1
+	if t.kind == tbWDL && t.key != t.key2 {
+		sides = 2
+	}
+	if !t.hasPawns {
+		file = 0
+	}
+	return &t.items[stm%sides][file]
+}
+
+// tbCursor reads little-endian values sequentially from a table file
+type tbCursor struct {
+	r   io.ReaderAt
+	off int64
+	err error
+}
+
+func (c *tbCursor) bytes(n int) []byte {
+	buf := make([]byte, n)
+	if c.err == nil {
+		_, c.err = c.r.ReadAt(buf, c.off)
+	}
+	c.off += int64(n)
+	return buf
+}
+
+func (c *tbCursor) u8() int {
+	return int(c.bytes(1)[0])
+}
+
+func (c *tbCursor) u16() int {
+	return int(binary.LittleEndian.Uint16(c.bytes(2)))
+}
+
+func (c *tbCursor) u32() uint32 {
+	return binary.LittleEndian.Uint32(c.bytes(4))
+}
+
+// load opens the file and parses its header the first time the table is probed
+func (t *syzygyTable) load() error {
+	t.once.Do(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.loadErr = fmt.Errorf("%s: corrupt table header", t.path)
+			}
+		}()
+		t.loadErr = t.read()
+		if t.loadErr != nil {
+			t.loadErr = fmt.Errorf("%s: %v", t.path, t.loadErr)
+		}
+	})
+	return t.loadErr
+}
+
+func (t *syzygyTable) read() error {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if info.Size() < tbInMemoryLimit {
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		t.file = bytes.NewReader(data)
+	} else {
+		t.file = f
+		t.closer = f
+	}
+
+	c := &tbCursor{r: t.file}
+	if magic := c.bytes(4); c.err != nil || !bytes.Equal(magic, tbMagic[t.kind][:]) {
+		return errors.New("not a syzygy table or corrupt header")
+	}
+
+	const split, hasPawns = 1, 2
+	flags := c.u8()
+	if (flags&hasPawns != 0) != t.hasPawns || (t.kind == tbWDL && (flags&split != 0) != (t.key != t.key2)) {
+		return errors.New("table header does not match its name")
+	}
+
+	sides := 1
+	if t.kind == tbWDL && t.key != t.key2 {
+		sides = 2
+	}
+	maxFile := 0
+	if t.hasPawns {
+		maxFile = 3
+	}
+	pp := t.hasPawns && t.pawnCount[1] > 0 // Pawns on both sides
+
+	for f := 0; f <= maxFile; f++ {
+		first := c.u8()
+		second := 0xFF
+		if pp {
+			second = c.u8()
+		}
+		order := [2][2]int{{first & 0xF, second & 0xF}, {first >> 4, second >> 4}}
+
+		for k := 0; k < t.pieceCount; k++ {
+			b := c.u8()
+			for i := 0; i < sides; i++ {
+				if i == 0 {
+					t.items[i][f].pieces[k] = b & 0xF
+				} else {
+					t.items[i][f].pieces[k] = b >> 4
+				}
+			}
+		}
+		for i := 0; i < sides; i++ {
+			t.setGroups(&t.items[i][f], order[i], f)
+		}
+	}
+
+	c.off += c.off & 1 // Word alignment
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			t.setSizes(&t.items[i][f], c)
+		}
+	}
+
+	if t.kind == tbDTZ {
+		t.mapData = c.off
+		for f := 0; f <= maxFile; f++ {
+			d := &t.items[0][f]
+			if d.flags&tbFlagMapped == 0 {
+				continue
+			}
+			if d.flags&tbFlagWide != 0 {
+				c.off += c.off & 1
+				for i := 0; i < 4; i++ {
+					d.mapIdx[i] = int((c.off-t.mapData)/2) + 1
+					c.off += 2 * int64(c.u16())
+				}
+			} else {
+				for i := 0; i < 4; i++ {
+					d.mapIdx[i] = int(c.off-t.mapData) + 1
+					c.off += int64(c.u8())
+				}
+			}
+		}
+		c.off += c.off & 1
+	}
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			d := &t.items[i][f]
+			d.sparseIndex = c.off
+			c.off += int64(d.sparseIndexSize) * 6
+		}
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			d := &t.items[i][f]
+			d.blockLength = c.off
+			c.off += int64(d.blockLengthSize) * 2
+		}
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			d := &t.items[i][f]
+			c.off = (c.off + 0x3F) &^ 0x3F // 64 byte alignment
+			d.data = c.off
+			c.off += int64(d.numBlocks * d.sizeofBlock)
+		}
+	}
+
+	return c.err
+}
+
+// setGroups works out how the pieces are grouped for indexing and the size of each group
+func (t *syzygyTable) setGroups(d *tbPairs, order [2]int, f int) {
+	n := 0
+	firstLen := 2
+	if t.hasPawns {
+		firstLen = 0
+	} else if t.hasUniquePieces {
+		firstLen = 3
+	}
+
+	d.groupLen[n] = 1
+	for i := 1; i < t.pieceCount; i++ {
+		firstLen--
+		if firstLen > 0 || d.pieces[i] != d.pieces[i-1] {
+			n++
+			d.groupLen[n] = 1
+		} else {
+			d.groupLen[n]++
+		}
+	}
+	n++
+	d.groupLen[n] = 0
+
+	pp := t.hasPawns && t.pawnCount[1] > 0
+	next := 1
+	freeSquares := 64 - d.groupLen[0]
+	if pp {
+		next = 2
+		freeSquares -= d.groupLen[1]
+	}
+
+	idx := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		if k == order[0] { // Leading pawns or pieces
+			d.groupIdx[0] = idx
+			switch {
+			case t.hasPawns:
+				idx *= tbLeadPawnsSize[d.groupLen[0]][f]
+			case t.hasUniquePieces:
+				idx *= 31332
+			default:
+				idx *= 462
+			}
+		} else if k == order[1] { // Remaining pawns
+			d.groupIdx[1] = idx
+			idx *= tbBinomial[d.groupLen[1]][48-d.groupLen[0]]
+		} else { // Remaining pieces
+			d.groupIdx[next] = idx
+			idx *= tbBinomial[d.groupLen[next]][freeSquares]
+			freeSquares -= d.groupLen[next]
+			next++
+		}
+	}
+	d.groupIdx[n] = idx
+}
+
+// setSizes reads the block layout and Huffman code description of a table
+func (t *syzygyTable) setSizes(d *tbPairs, c *tbCursor) {
+	d.flags = byte(c.u8())
+
+	if d.flags&tbFlagSingleValue != 0 {
+		d.minSymLen = c.u8() // The single value
+		return
+	}
+
+	n := 0
+	for d.groupLen[n] != 0 {
+		n++
+	}
+	tbSize := d.groupIdx[n]
+
+	blockShift, spanShift := c.u8(), c.u8()
+	if blockShift > 30 || spanShift > 30 {
+		c.err = errors.New("bad block size")
+		return
+	}
+	d.sizeofBlock = 1 << uint(blockShift)
+	d.span = 1 << uint(spanShift)
+	d.sparseIndexSize = (tbSize + d.span - 1) / d.span
+	padding := uint64(c.u8())
+	d.numBlocks = uint64(c.u32())
+	d.blockLengthSize = d.numBlocks + padding
+	d.maxSymLen = c.u8()
+	d.minSymLen = c.u8()
+
+	count := d.maxSymLen - d.minSymLen + 1
+	if count <= 0 || c.err != nil {
+		c.err = errors.New("bad symbol lengths")
+		return
+	}
+	d.lowestSym = make([]uint16, count)
+	for i := range d.lowestSym {
+		d.lowestSym[i] = uint16(c.u16())
+	}
+
+	// Canonical Huffman codes: longer codes have lower values, so base64[i] >= base64[i+1]
+	d.base64 = make([]uint64, count)
+	for i := count - 2; i >= 0; i-- {
+		d.base64[i] = (d.base64[i+1] + uint64(d.lowestSym[i]) - uint64(d.lowestSym[i+1])) / 2
+	}
+	for i := 0; i < count; i++ {
+		d.base64[i] <<= uint(64 - i - d.minSymLen)
+	}
+
+	symbols := c.u16()
+	d.btree = c.bytes(3 * symbols)
+	if symbols&1 != 0 {
+		c.off++
+	}
+
+	d.symlen = make([]int, symbols)
+	visited := make([]bool, symbols)
+	for sym := 0; sym < symbols; sym++ {
+		if !visited[sym] {
+			d.symlen[sym] = d.setSymlen(sym, visited)
+		}
+	}
+}
+
+// setSymlen works out how many values a symbol expands to, less one
+func (d *tbPairs) setSymlen(sym int, visited []bool) int {
+	visited[sym] = true
+	right := d.right(sym)
+	if right == 0xFFF {
+		return 0
+	}
+	left := d.left(sym)
+	if !visited[left] {
+		d.symlen[left] = d.setSymlen(left, visited)
+	}
+	if !visited[right] {
+		d.symlen[right] = d.setSymlen(right, visited)
+	}
+	return d.symlen[left] + d.symlen[right] + 1
+}
+
+// decompress returns the value stored at idx
+func (t *syzygyTable) decompress(d *tbPairs, idx uint64) (int, error) {
+	if d.flags&tbFlagSingleValue != 0 {
+		return d.minSymLen, nil
+	}
+
+	// The sparse index points at a block and offset near idx
+	k := idx / d.span
+	entry := make([]byte, 6)
+	if _, err := t.file.ReadAt(entry, d.sparseIndex+int64(k)*6); err != nil {
+		return 0, err
+	}
+	block := int64(binary.LittleEndian.Uint32(entry[0:4]))
+	offset := int64(binary.LittleEndian.Uint16(entry[4:6]))
+	offset += int64(idx%d.span) - int64(d.span/2)
+
+	blockLength := func(b int64) (int64, error) {
+		buf := make([]byte, 2)
+		_, err := t.file.ReadAt(buf, d.blockLength+b*2)
+		return int64(binary.LittleEndian.Uint16(buf)), err
+	}
+
+	// Step to the block that holds idx
+	for offset < 0 {
+		block--
+		length, err := blockLength(block)
+		if err != nil {
+			return 0, err
+		}
+		offset += length + 1
+	}
+	for {
+		length, err := blockLength(block)
+		if err != nil {
+			return 0, err
+		}
+		if offset <= length {
+			break
+		}
+		offset -= length + 1
+		block++
+	}
+
+	data := make([]byte, d.sizeofBlock+8)
+	n, err := t.file.ReadAt(data[:d.sizeofBlock], d.data+block*int64(d.sizeofBlock))
+	if err != nil && !(err == io.EOF && n > 0) {
+		return 0, err
+	}
+
+	// Walk the Huffman coded symbols until the one covering offset
+	pos := 8
+	buf64 := binary.BigEndian.Uint64(data[0:8])
+	buf64Size := 64
+	var sym int
+	for {
+		length := 0
+		for buf64 < d.base64[length] {
+			length++
+		}
+		sym = int((buf64 - d.base64[length]) >> uint(64-length-d.minSymLen))
+		sym += int(d.lowestSym[length])
+		if sym >= len(d.symlen) {
+			return 0, errors.New("corrupt table data")
+		}
+
+		if offset < int64(d.symlen[sym])+1 {
+			break
+		}
+		offset -= int64(d.symlen[sym]) + 1
+		length += d.minSymLen
+		buf64 <<= uint(length)
+		buf64Size -= length
+
+		if buf64Size <= 32 {
+			buf64Size += 32
+			if pos+4 > len(data) {
+				return 0, errors.New("corrupt table data")
+			}
+			buf64 |= uint64(binary.BigEndian.Uint32(data[pos:pos+4])) << uint(64-buf64Size)
+			pos += 4
+		}
+	}
+
+	// Expand the pair symbols down to the single value at offset
+	for d.symlen[sym] != 0 {
+		left := d.left(sym)
+		if offset < int64(d.symlen[left])+1 {
+			sym = left
+		} else {
+			offset -= int64(d.symlen[left]) + 1
+			sym = d.right(sym)
+		}
+	}
+	return d.left(sym), nil
+}
+
+// tbPiece is a piece on a square, as the files see them
+type tbPiece struct {
+	code int
+	sq   int
+}
+
+// probe looks up the raw value for a position in this table. For DTZ tables,
+// ok is false with a nil error if the table stores the other side to move
+func (t *syzygyTable) probe(g *game.GameState, wdl int) (value int, ok bool, err error) {
+	if err := t.load(); err != nil {
+		return 0, false, err
+	}
+
+	// A damaged file can send the decoder out of range; report it rather than crash
+	defer func() {
+		if r := recover(); r != nil {
+			value, ok, err = 0, false, fmt.Errorf("%s: corrupt table data", t.path)
+		}
+	}()
+
+	var pieces []tbPiece
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			piece := g.Board.GetPiece(row, col)
+			if piece.Type == board.Empty {
+				continue
+			}
+			code := tbPieceCodes[piece.Type]
+			if piece.Color == board.Black {
+				code += 8
+			}
+			pieces = append(pieces, tbPiece{code, tbSquare(row, col)})
+		}
+	}
+	sort.Slice(pieces, func(i, j int) bool { return pieces[i].sq < pieces[j].sq })
+
+	// Tables are stored with white as the stronger side, and symmetric ones only
+	// with white to move, so flip the position into that form if needed
+	symmetricBlackToMove := t.key == t.key2 && g.CurrentPlayer == board.Black
+	blackStronger := g.Board.MaterialSignature() != t.key
+	flip := symmetricBlackToMove || blackStronger
+	flipColor, flipSquares, stm := 0, 0, g.CurrentPlayer
+	if flip {
+		flipColor, flipSquares, stm = 8, 56, 1-g.CurrentPlayer
+	}
+
+	squares := make([]int, 0, len(pieces))
+	codes := make([]int, 0, len(pieces))
+	leadPawns := 0
+	file := 0
+
+	if t.hasPawns {
+		// Pawns of the leading colour come first; the lead pawn is the one
+		// nearest the edge and lowest rank
+		leadCode := t.get(0, 0).pieces[0] ^ flipColor
+		for _, p := range pieces {
+			if p.code == leadCode {
+				squares = append(squares, p.sq^flipSquares)
+				codes = append(codes, p.code^flipColor)
+			}
+		}
+		leadPawns = len(squares)
+
+		best := 0
+		for i := 1; i < leadPawns; i++ {
+			if tbMapPawns[squares[i]] > tbMapPawns[squares[best]] {
+				best = i
+			}
+		}
+		squares[0], squares[best] = squares[best], squares[0]
+
+		file = tbFile(squares[0])
+		if file > 3 {
+			file = tbFile(squares[0] ^ 7)
+		}
+	}
+
+	if t.kind == tbDTZ {
+		d := t.get(stm, file)
+		if int(d.flags&tbFlagSTM) != stm && !(t.key == t.key2 && !t.hasPawns) {
+			return 0, false, nil
+		}
+	}
+
+	for _, p := range pieces {
+		if t.hasPawns && p.code == t.get(0, 0).pieces[0]^flipColor {
+			continue
+		}
+		squares = append(squares, p.sq^flipSquares)
+		codes = append(codes, p.code^flipColor)
+	}
+
+	d := t.get(stm, file)
+
+	// Put the pieces in the order the table was encoded with
+	for i := leadPawns; i < len(squares)-1; i++ {
+		for j := i + 1; j < len(squares); j++ {
+			if d.pieces[i] == codes[j] {
+				codes[i], codes[j] = codes[j], codes[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+
+	// Mirror so the lead piece is on files a-d
+	if tbFile(squares[0]) > 3 {
+		for i := range squares {
+			squares[i] ^= 7
+		}
+	}
+
+	var idx uint64
+	if t.hasPawns {
+		idx = tbLeadPawnIdx[leadPawns][squares[0]]
+		rest := squares[1:leadPawns]
+		sort.SliceStable(rest, func(i, j int) bool { return tbMapPawns[rest[i]] < tbMapPawns[rest[j]] })
+		for i := 1; i < leadPawns; i++ {
+			idx += tbBinomial[i][tbMapPawns[squares[i]]]
+		}
+	} else {
+		// Mirror so the lead piece is on ranks 1-4, then below the a1-h8 diagonal
+		if tbRank(squares[0]) > 3 {
+			for i := range squares {
+				squares[i] ^= 56
+			}
+		}
+		for i := 0; i < d.groupLen[0]; i++ {
+			if tbOffA1H8(squares[i]) == 0 {
+				continue
+			}
+			if tbOffA1H8(squares[i]) > 0 {
+				for j := i; j < len(squares); j++ {
+					squares[j] = ((squares[j] >> 3) | (squares[j] << 3)) & 63
+				}
+			}
+			break
+		}
+
+		if t.hasUniquePieces {
+			adjust1, adjust2 := 0, 0
+			if squares[1] > squares[0] {
+				adjust1++
+			}
+			if squares[2] > squares[0] {
+				adjust1++
+				adjust2++
+			}
+			if squares[2] > squares[1] {
+				adjust2++
+			}
+
+			switch {
+			case tbOffA1H8(squares[0]) != 0:
+				idx = uint64((tbMapA1D1D4[squares[0]]*63+(squares[1]-adjust1))*62 + squares[2] - adjust2)
+			case tbOffA1H8(squares[1]) != 0:
+				idx = uint64((6*63+tbRank(squares[0])*28+tbMapB1H1H7[squares[1]])*62 + squares[2] - adjust2)
+			case tbOffA1H8(squares[2]) != 0:
+				idx = uint64(6*63*62 + 4*28*62 + tbRank(squares[0])*7*28 +
+					(tbRank(squares[1])-adjust1)*28 + tbMapB1H1H7[squares[2]])
+			default:
+				idx = uint64(6*63*62 + 4*28*62 + 4*7*28 + tbRank(squares[0])*7*6 +
+					(tbRank(squares[1])-adjust1)*6 + (tbRank(squares[2]) - adjust2))
+			}
+		} else {
+			idx = uint64(tbMapKK[tbMapA1D1D4[squares[0]]][squares[1]])
+		}
+	}
+
+	// Encode the remaining groups, each in ascending square order
+	idx *= d.groupIdx[0]
+	start := d.groupLen[0]
+	remainingPawns := t.hasPawns && t.pawnCount[1] > 0
+	for next := 1; d.groupLen[next] != 0; next++ {
+		group := squares[start : start+d.groupLen[next]]
+		sort.Ints(group)
+
+		var n uint64
+		for i, sq := range group {
+			adjust := 0
+			for _, earlier := range squares[:start] {
+				if sq > earlier {
+					adjust++
+				}
+			}
+			s := sq - adjust
+			if remainingPawns {
+				s -= 8
+			}
+			n += tbBinomial[i+1][s]
+		}
+		remainingPawns = false
+		idx += n * d.groupIdx[next]
+		start += d.groupLen[next]
+	}
+
+	raw, err := t.decompress(d, idx)
+	if err != nil {
+		return 0, false, err
+	}
+	if t.kind == tbWDL {
+		return raw - 2, true, nil
+	}
+	value, err = t.mapDTZ(file, raw, wdl)
+	return value, err == nil, err
+}
+
+// mapDTZ converts a stored DTZ value into plies
+func (t *syzygyTable) mapDTZ(file, value, wdl int) (int, error) {
+	wdlMap := [5]int{1, 3, 0, 2, 0}
+	d := t.get(0, file)
+
+	if d.flags&tbFlagMapped != 0 {
+		i := d.mapIdx[wdlMap[wdl+2]] + value
+		if d.flags&tbFlagWide != 0 {
+			buf := make([]byte, 2)
+			if _, err := t.file.ReadAt(buf, t.mapData+int64(2*i)); err != nil {
+				return 0, err
+			}
+			value = int(binary.LittleEndian.Uint16(buf))
+		} else {
+			buf := make([]byte, 1)
+			if _, err := t.file.ReadAt(buf, t.mapData+int64(i)); err != nil {
+				return 0, err
+			}
+			value = int(buf[0])
+		}
+	}
+
+	// Values may be stored in moves rather than plies
+	if (wdl == WDLWin && d.flags&tbFlagWinPlies == 0) ||
+		(wdl == WDLLoss && d.flags&tbFlagLossPlies == 0) ||
+		wdl == WDLCursedWin || wdl == WDLBlessedLoss {
+		value *= 2
+	}
+	return value + 1, nil
+}
+
+// Syzygy is a set of Syzygy endgame tablebase files
+type Syzygy struct {
+	Path      string
+	MaxPieces int // Largest number of pieces covered by the loaded tables
+
+	tables [2]map[string]*syzygyTable // [kind][material key]
+}
+
+// OpenSyzygy finds the table files in a list of directories separated like $PATH.
+// Files are only read when first probed
+func OpenSyzygy(path string) (*Syzygy, error) {
+	tb := &Syzygy{
+		Path:   path,
+		tables: [2]map[string]*syzygyTable{make(map[string]*syzygyTable), make(map[string]*syzygyTable)},
+	}
+
+	for _, dir := range filepath.SplitList(path) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			ext := filepath.Ext(name)
+			kind := tbWDL
+			switch ext {
+			case ".rtbw":
+			case ".rtbz":
+				kind = tbDTZ
+			default:
+				continue
+			}
+			material := strings.TrimSuffix(name, ext)
+			if !validTableName(material) {
+				continue
+			}
+
+			t := newSyzygyTable(kind, material, filepath.Join(dir, name))
+			if _, exists := tb.tables[kind][t.key]; exists {
+				continue
+			}
+			tb.tables[kind][t.key] = t
+			tb.tables[kind][t.key2] = t
+			if t.pieceCount > tb.MaxPieces {
+				tb.MaxPieces = t.pieceCount
+			}
+		}
+	}
+
+	if len(tb.tables[tbWDL]) == 0 {
+		return nil, fmt.Errorf("no syzygy tables found in %s", path)
+	}
+	return tb, nil
+}
+
+// validTableName checks a name like KRPvKR, with a king on each side
+func validTableName(name string) bool {
+	sides := strings.Split(name, "v")
+	if len(sides) != 2 || len(name) > 8 {
+		return false
+	}
+	for _, side := range sides {
+		if len(side) == 0 || side[0] != 'K' || strings.Trim(side[1:], "QRBNP") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Close releases any open table files
+func (tb *Syzygy) Close() {
+	for _, tables := range tb.tables {
+		for _, t := range tables {
+			if t.closer != nil {
+				t.closer.Close()
+			}
+		}
+	}
+}
+
+// CanProbe reports whether a position is covered by the tables
+func (tb *Syzygy) CanProbe(g *game.GameState) bool {
+	if g.WhiteCanCastleK || g.WhiteCanCastleQ || g.BlackCanCastleK || g.BlackCanCastleQ {
+		return false
+	}
+	count := pieceCount(g.Board)
+	return count <= tb.MaxPieces
+}
+
+func pieceCount(b *board.Board) int {
+	count := 0
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			if b.GetPiece(row, col).Type != board.Empty {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// isZeroingMove reports whether a move resets the 50 move counter
+func isZeroingMove(move board.Move) bool {
+	return move.IsCapture || move.PieceType == board.Pawn
+}
+
+// probeTable looks a position up directly in a WDL or DTZ table
+func (tb *Syzygy) probeTable(g *game.GameState, kind, wdl int) (int, bool, error) {
+	signature := g.Board.MaterialSignature()
+	if signature == "KvK" {
+		return WDLDraw, true, nil
+	}
+	t := tb.tables[kind][signature]
+	if t == nil {
+		return 0, false, fmt.Errorf("no table for %s", signature)
+	}
+	return t.probe(g, wdl)
+}
+
+// search resolves captures (and pawn moves when checkZeroing is set), since
+// tables store "don't care" values where a capture is the best move.
+// zeroingBest is set when the best result comes from a zeroing move
+func (tb *Syzygy) search(g *game.GameState, checkZeroing bool) (wdl int, zeroingBest bool, err error) {
+	best := WDLLoss
+	moves := g.GenerateAllLegalMoves()
+	searched := 0
+
+	for _, move := range moves {
+		if !move.IsCapture && (!checkZeroing || move.PieceType != board.Pawn) {
+			continue
+		}
+		searched++
+
+		next := g.Copy()
+		next.MakeMove(move)
+		value, _, err := tb.search(next, false)
+		if err != nil {
+			return WDLDraw, false, err
+		}
+		value = -value
+
+		if value > best {
+			best = value
+			if value >= WDLWin {
+				return value, true, nil
+			}
+		}
+	}
+
+	// With every move already searched the stored value can't be trusted
+	// (for instance positions with en passant rights)
+	noMoreMoves := searched > 0 && searched == len(moves)
+
+	var value int
+	if noMoreMoves {
+		value = best
+	} else {
+		var ok bool
+		value, ok, err = tb.probeTable(g, tbWDL, WDLDraw)
+		if err != nil {
+			return WDLDraw, false, err
+		}
+		if !ok {
+			return WDLDraw, false, errors.New("probe failed")
+		}
+	}
+
+	if best >= value {
+		return best, best > WDLDraw || noMoreMoves, nil
+	}
+	return value, false, nil
+}
+
+// ProbeWDL returns the win/draw/loss result for the side to move
+func (tb *Syzygy) ProbeWDL(g *game.GameState) (int, bool) {
+	if !tb.CanProbe(g) {
+		return 0, false
+	}
+	wdl, _, err := tb.search(g, false)
+	if err != nil {
+		return 0, false
+	}
+	return wdl, true
+}
+
+// dtzBeforeZeroing is the DTZ of a position just before a zeroing move with the given result
+func dtzBeforeZeroing(wdl int) int {
+	switch wdl {
+	case WDLWin:
+		return 1
+	case WDLCursedWin:
+		return 101
+	case WDLBlessedLoss:
+		return -101
+	case WDLLoss:
+		return -1
+	}
+	return 0
+}
+
+func sign(x int) int {
+	if x > 0 {
+		return 1
+	}
+	if x < 0 {
+		return -1
+	}
+	return 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// ProbeDTZ returns the distance in plies to the next zeroing move in a
+// won or lost position, positive when the side to move wins and 0 for draws
+func (tb *Syzygy) ProbeDTZ(g *game.GameState) (int, bool) {
+	if !tb.CanProbe(g) {
+		return 0, false
+	}
+	dtz, err := tb.probeDTZ(g)
+	if err != nil {
+		return 0, false
+	}
+	return dtz, true
+}
+
+func (tb *Syzygy) probeDTZ(g *game.GameState) (int, error) {
+	wdl, zeroingBest, err := tb.search(g, true)
+	if err != nil {
+		return 0, err
+	}
+	if wdl == WDLDraw {
+		return 0, nil // DTZ tables don't store draws
+	}
+	if zeroingBest {
+		return dtzBeforeZeroing(wdl), nil
+	}
+
+	dtz, ok, err := tb.probeTable(g, tbDTZ, wdl)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		bonus := 0
+		if wdl == WDLBlessedLoss || wdl == WDLCursedWin {
+			bonus = 100
+		}
+		return (dtz + bonus) * sign(wdl), nil
+	}
+
+	// The table only stores the other side to move, so look one ply ahead
+	minDTZ := 0xFFFF
+	for _, move := range g.GenerateAllLegalMoves() {
+		zeroing := isZeroingMove(move)
+		next := g.Copy()
+		next.MakeMove(move)
+
+		if zeroing {
+			value, _, err := tb.search(next, false)
+			if err != nil {
+				return 0, err
+			}
+			dtz = -dtzBeforeZeroing(value)
+		} else {
+			dtz, err = tb.probeDTZ(next)
+			if err != nil {
+				return 0, err
+			}
+			dtz = -dtz
+		}
+
+		// A mating move counts as one ply
+		if dtz == 1 && next.Board.IsInCheck(next.CurrentPlayer) && len(next.GenerateAllLegalMoves()) == 0 {
+			minDTZ = 1
+		}
+		if !zeroing {
+			dtz += sign(dtz)
+		}
+		if dtz < minDTZ && sign(dtz) == sign(wdl) {
+			minDTZ = dtz
+		}
+	}
+
+	if minDTZ == 0xFFFF {
+		return -1, nil // No legal moves, we've been mated
+	}
+	return minDTZ, nil
+}
+
+// TablebaseMove is a root move ranked by the tables
+type TablebaseMove struct {
+	Move board.Move
+	WDL  int // Result after the move, for the side making it
+	DTZ  int // Plies to a zeroing move after this move, counted from the root
+	Rank int
+}
+
+// RankRootMoves scores every legal move using DTZ, taking the 50 move rule into account.
+// Moves are returned best first
+func (tb *Syzygy) RankRootMoves(g *game.GameState) ([]TablebaseMove, bool) {
+	if !tb.CanProbe(g) {
+		return nil, false
+	}
+
+	var ranked []TablebaseMove
+	for _, move := range g.GenerateAllLegalMoves() {
+		next := g.Copy()
+		next.MakeMove(move)
+
+		var dtz, wdl int
+		if next.HalfMoveClock == 0 {
+			value, _, err := tb.search(next, false)
+			if err != nil {
+				return nil, false
+			}
+			wdl = -value
+			dtz = dtzBeforeZeroing(wdl)
+		} else {
+			value, err := tb.probeDTZ(next)
+			if err != nil {
+				return nil, false
+			}
+			dtz = -value
+			if dtz > 0 {
+				dtz++
+			} else if dtz < 0 {
+				dtz--
+			}
+		}
+
+		// A mating move is one ply from the win
+		if next.Board.IsInCheck(next.CurrentPlayer) && dtz == 2 && len(next.GenerateAllLegalMoves()) == 0 {
+			dtz = 1
+		}
+
+		cnt50 := g.HalfMoveClock
+		rank := 0
+		switch {
+		case dtz > 0:
+			rank = 1000
+			if dtz+cnt50 > 99 {
+				rank = 1000 - (dtz + cnt50)
+			}
+		case dtz < 0:
+			rank = -1000
+			if -dtz*2+cnt50 >= 100 {
+				rank = -1000 + (-dtz + cnt50)
+			}
+		}
+
+		if next.HalfMoveClock != 0 {
+			switch {
+			case rank >= 1000 || rank > 0:
+				wdl = WDLWin
+				if rank < 1000 {
+					wdl = WDLCursedWin
+				}
+			case rank <= -1000:
+				wdl = WDLLoss
+			case rank < 0:
+				wdl = WDLBlessedLoss
+			}
+		}
+
+		ranked = append(ranked, TablebaseMove{Move: move, WDL: wdl, DTZ: dtz, Rank: rank})
+	}
+
+	// Best rank first; among equal ranks win quickly, or lose slowly
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Rank != ranked[j].Rank {
+			return ranked[i].Rank > ranked[j].Rank
+		}
+		if ranked[i].DTZ > 0 && ranked[j].DTZ > 0 {
+			return ranked[i].DTZ < ranked[j].DTZ
+		}
+		return abs(ranked[i].DTZ) > abs(ranked[j].DTZ)
+	})
+	return ranked, len(ranked) > 0
+}
@@ -0,0 +1,179 @@
+package tablebase
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"chess-engine/game"
+)
+
+func TestEncodingTables(t *testing.T) {
+	binomials := []struct {
+		k, n int
+		want uint64
+	}{
+		{0, 0, 1}, {1, 7, 7}, {2, 5, 10}, {3, 10, 120}, {4, 47, 178365}, {5, 63, 7028847}, {5, 4, 0},
+	}
+	for _, b := range binomials {
+		if got := tbBinomial[b.k][b.n]; got != b.want {
+			t.Errorf("%d choose %d is %d, want %d", b.n, b.k, got, b.want)
+		}
+	}
+
+	// Each map numbers its squares from 0 without gaps or repeats
+	distinct := func(name string, codes []int, want int) {
+		t.Helper()
+		seen := make(map[int]bool)
+		for _, code := range codes {
+			if code < 0 || code >= want || seen[code] {
+				t.Fatalf("%s: code %d repeated or outside 0 to %d", name, code, want-1)
+			}
+			seen[code] = true
+		}
+		if len(seen) != want {
+			t.Errorf("%s: %d codes, want %d", name, len(seen), want)
+		}
+	}
+	var triangle, lower, pawns, kings []int
+	for sq := 0; sq < 64; sq++ {
+		if tbFile(sq) <= 3 && tbRank(sq) <= tbFile(sq) {
+			triangle = append(triangle, tbMapA1D1D4[sq])
+		}
+		if tbOffA1H8(sq) < 0 {
+			lower = append(lower, tbMapB1H1H7[sq])
+		}
+		if tbRank(sq) >= 1 && tbRank(sq) <= 6 {
+			pawns = append(pawns, tbMapPawns[sq])
+		}
+	}
+	distinct("a1-d1-d4", triangle, 10)
+	distinct("b1-h1-h7", lower, 28)
+	distinct("pawns", pawns, 48)
+
+	// There are 462 ways to place two kings apart, up to symmetry
+	for sq1 := 0; sq1 <= 27; sq1++ {
+		if tbFile(sq1) > 3 || tbRank(sq1) > tbFile(sq1) {
+			continue
+		}
+		for sq2 := 0; sq2 < 64; sq2++ {
+			apart := abs(tbFile(sq1)-tbFile(sq2)) > 1 || abs(tbRank(sq1)-tbRank(sq2)) > 1
+			if apart && !(tbOffA1H8(sq1) == 0 && tbOffA1H8(sq2) > 0) {
+				kings = append(kings, tbMapKK[tbMapA1D1D4[sq1]][sq2])
+			}
+		}
+	}
+	distinct("kings", kings, 462)
+
+	// A single leading pawn is indexed by its rank alone
+	for f := 0; f < 4; f++ {
+		if size := tbLeadPawnsSize[1][f]; size != 6 {
+			t.Errorf("one leading pawn on file %d: %d placements, want 6", f, size)
+		}
+	}
+}
+
+func TestValidTableName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"KQvK", true},
+		{"KRPvKR", true},
+		{"KBNvK", true},
+		{"KvK", true},
+		{"KQRBvKN", true},
+		{"KQvKvK", false},
+		{"KQK", false},
+		{"QKvK", false},
+		{"KQvQ", false},
+		{"KXvK", false},
+		{"vK", false},
+		{"KQRBNPvKQ", false},
+		{"kqvk", false},
+	}
+	for _, test := range tests {
+		if got := validTableName(test.name); got != test.valid {
+			t.Errorf("validTableName(%q) = %v, want %v", test.name, got, test.valid)
+		}
+	}
+}
+
+func TestOpenSyzygy(t *testing.T) {
+	if _, err := OpenSyzygy(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("opened a missing directory")
+	}
+
+	dir := t.TempDir()
+	if _, err := OpenSyzygy(dir); err == nil || !strings.Contains(err.Error(), "no syzygy tables") {
+		t.Errorf("empty directory: got error %v", err)
+	}
+
+	// Files that aren't tables, or only DTZ tables, aren't enough
+	for _, name := range []string{"README.txt", "KQvK.rtbz", "KQK.rtbw", "notes.rtbw"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := OpenSyzygy(dir); err == nil {
+		t.Error("opened a directory without WDL tables")
+	}
+
+	// Tables are only read when probed, so a bad one opens but can't be probed
+	if err := os.WriteFile(filepath.Join(dir, "KQvK.rtbw"), []byte("not a table"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tb, err := OpenSyzygy(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tb.Close()
+	if tb.MaxPieces != 3 {
+		t.Errorf("max pieces %d, want 3", tb.MaxPieces)
+	}
+
+	tests := []struct {
+		name, fen string
+		ok        bool
+	}{
+		{"bare kings need no table", "8/8/8/4k3/8/8/3K4/8 w - - 0 1", true},
+		{"corrupt table", "8/8/8/4k3/8/8/3K4/7Q w - - 0 1", false},
+		{"corrupt table with colours swapped", "8/8/8/4k3/8/8/3K4/7q b - - 0 1", false},
+		{"no table", "8/8/8/4k3/8/8/3K4/7R w - - 0 1", false},
+		{"too many pieces", "8/8/8/4k3/8/8/3K4/6RR w - - 0 1", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, err := game.NewGameFromFEN(test.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wdl, ok := tb.ProbeWDL(g)
+			if ok != test.ok || wdl != WDLDraw {
+				t.Errorf("got %d, %v, want %d, %v", wdl, ok, WDLDraw, test.ok)
+			}
+			if _, ok := tb.ProbeDTZ(g); ok && !test.ok {
+				t.Error("DTZ probe succeeded")
+			}
+		})
+	}
+}
+
+// TestProbeWithoutTables checks a set with no tables loaded answers nothing
+func TestProbeWithoutTables(t *testing.T) {
+	tb := &Syzygy{}
+	g, err := game.NewGameFromFEN("8/8/8/4k3/8/8/3K4/7Q w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tb.ProbeWDL(g); ok {
+		t.Error("WDL probe succeeded")
+	}
+	if _, ok := tb.ProbeDTZ(g); ok {
+		t.Error("DTZ probe succeeded")
+	}
+	if _, ok := tb.RankRootMoves(g); ok {
+		t.Error("root moves were ranked")
+	}
+}
@@ -0,0 +1,361 @@
+package uci
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The other side of the protocol: driving an external engine, for matches
+// against it
+
+// handshakeTimeout bounds how long an engine may take to answer "uci" and "isready"
+const handshakeTimeout = 10 * time.Second
+
+// quitTimeout is how long an engine gets to exit after "quit" before it is killed
+const quitTimeout = 2 * time.Second
+
+// errEngineExited is returned when the engine's output ends
+var errEngineExited = errors.New("engine exited")
+
+// ErrTimeout is returned when the engine doesn't answer in time. It may
+// still be searching; Stop and IsReady bring it back in step
+var ErrTimeout = errors.New("engine didn't answer in time")
+
+// Client drives an external engine process over UCI
+type Client struct {
+	Name    string            // From "id name"
+	Author  string            // From "id author"
+	Options map[string]string // The options the engine declares, by name, with their types
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string // The engine's output, closed when it exits
+}
+
+// Limits bound a search. Zero fields are left out of "go"; with none set
+// the engine searches until it decides to stop, which few engines do
+type Limits struct {
+	Depth     int
+	MoveTime  time.Duration
+	WTime     time.Duration
+	BTime     time.Duration
+	WInc      time.Duration
+	BInc      time.Duration
+	MovesToGo int
+	Infinite  bool
+}
+
+// Info is what an "info" line says about the search. Scores are from the
+// side to move's point of view, as UCI sends them
+type Info struct {
+	Depth    int
+	SelDepth int
+	Score    int    // Centipawns, when Mate is 0
+	Mate     int    // Moves to mate, negative when being mated, 0 for none
+	Bound    string // "lowerbound" or "upperbound" when the score is only a bound
+	Nodes    int64
+	NPS      int64
+	Time     time.Duration
+	PV       []string
+}
+
+// BestMove is the engine's answer to "go"
+type BestMove struct {
+	Move   string // In coordinate notation, "0000" when the engine has no move
+	Ponder string
+	Info   Info // The last info line that gave a depth
+}
+
+// StartEngine runs an engine and goes through the UCI handshake
+func StartEngine(path string, args ...string) (*Client, error) {
+	cmd := exec.Command(path, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	c := &Client{Options: make(map[string]string), cmd: cmd, stdin: stdin, lines: make(chan string, 64)}
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			c.lines <- strings.TrimSpace(scanner.Text())
+		}
+		close(c.lines)
+	}()
+
+	if err := c.handshake(); err != nil {
+		c.kill()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// handshake sends "uci" and reads the engine's id and options up to "uciok"
+func (c *Client) handshake() error {
+	if err := c.send("uci"); err != nil {
+		return err
+	}
+	_, err := c.readUntil("uciok", handshakeTimeout, func(line string) {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 3 && fields[0] == "id" && fields[1] == "name":
+			c.Name = strings.Join(fields[2:], " ")
+		case len(fields) >= 3 && fields[0] == "id" && fields[1] == "author":
+			c.Author = strings.Join(fields[2:], " ")
+		case len(fields) >= 2 && fields[0] == "option":
+			name, kind := parseOptionDeclaration(fields[1:])
+			if name != "" {
+				c.Options[name] = kind
+			}
+		}
+	})
+	return err
+}
+
+// parseOptionDeclaration reads the name and type from the words after "option"
+func parseOptionDeclaration(args []string) (string, string) {
+	var name []string
+	kind := ""
+	inName := false
+	for i, arg := range args {
+		switch arg {
+		case "name":
+			inName = true
+			continue
+		case "type":
+			if i+1 < len(args) {
+				kind = args[i+1]
+			}
+			inName = false
+			continue
+		case "default", "min", "max", "var":
+			inName = false
+		}
+		if inName {
+			name = append(name, arg)
+		}
+	}
+	return strings.Join(name, " "), kind
+}
+
+// SetOption sets one of the options the engine declared. Buttons take no value
+func (c *Client) SetOption(name, value string) error {
+	kind, ok := c.Options[name]
+	if !ok {
+		return fmt.Errorf("%s has no option %q", c.Name, name)
+	}
+	if kind == "button" {
+		if err := c.send("setoption name %s", name); err != nil {
+			return err
+		}
+	} else if err := c.send("setoption name %s value %s", name, value); err != nil {
+		return err
+	}
+	return c.IsReady()
+}
+
+// IsReady waits for the engine to finish what it was told so far
+func (c *Client) IsReady() error {
+	if err := c.send("isready"); err != nil {
+		return err
+	}
+	_, err := c.readUntil("readyok", handshakeTimeout, nil)
+	return err
+}
+
+// NewGame tells the engine the next position is from a different game
+func (c *Client) NewGame() error {
+	if err := c.send("ucinewgame"); err != nil {
+		return err
+	}
+	return c.IsReady()
+}
+
+// Position sets the position to search: the moves played from a FEN, or
+// from the starting position when fen is empty
+func (c *Client) Position(fen string, moves []string) error {
+	command := "position startpos"
+	if fen != "" {
+		command = "position fen " + fen
+	}
+	if len(moves) > 0 {
+		command += " moves " + strings.Join(moves, " ")
+	}
+	return c.send("%s", command)
+}
+
+// Go searches the position and waits up to timeout for "bestmove", or
+// without a limit when timeout is 0
+func (c *Client) Go(limits Limits, timeout time.Duration) (BestMove, error) {
+	command := "go"
+	add := func(name string, value int64) {
+		if value > 0 {
+			command += " " + name + " " + strconv.FormatInt(value, 10)
+		}
+	}
+	add("wtime", limits.WTime.Milliseconds())
+	add("btime", limits.BTime.Milliseconds())
+	add("winc", limits.WInc.Milliseconds())
+	add("binc", limits.BInc.Milliseconds())
+	add("movestogo", int64(limits.MovesToGo))
+	add("depth", int64(limits.Depth))
+	add("movetime", limits.MoveTime.Milliseconds())
+	if limits.Infinite {
+		command += " infinite"
+	}
+	if err := c.send("%s", command); err != nil {
+		return BestMove{}, err
+	}
+
+	var result BestMove
+	line, err := c.readUntil("bestmove", timeout, func(line string) {
+		if info, ok := ParseInfo(line); ok && info.Depth > 0 {
+			result.Info = info
+		}
+	})
+	if err != nil {
+		return result, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return result, fmt.Errorf("bestmove without a move")
+	}
+	result.Move = fields[1]
+	if len(fields) >= 4 && fields[2] == "ponder" {
+		result.Ponder = fields[3]
+	}
+	return result, nil
+}
+
+// Stop asks the engine to end an infinite search; Go still returns its answer
+func (c *Client) Stop() error {
+	return c.send("stop")
+}
+
+// ParseInfo reads an "info" line. Fields it doesn't know are skipped
+func ParseInfo(line string) (Info, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != "info" {
+		return Info{}, false
+	}
+
+	var info Info
+	number := func(i int) int64 {
+		if i >= len(fields) {
+			return 0
+		}
+		n, _ := strconv.ParseInt(fields[i], 10, 64)
+		return n
+	}
+	for i := 1; i < len(fields); i++ {
+		switch fields[i] {
+		case "depth":
+			i++
+			info.Depth = int(number(i))
+		case "seldepth":
+			i++
+			info.SelDepth = int(number(i))
+		case "nodes":
+			i++
+			info.Nodes = number(i)
+		case "nps":
+			i++
+			info.NPS = number(i)
+		case "time":
+			i++
+			info.Time = time.Duration(number(i)) * time.Millisecond
+		case "score":
+			if i+2 < len(fields) {
+				switch fields[i+1] {
+				case "cp":
+					info.Score = int(number(i + 2))
+				case "mate":
+					info.Mate = int(number(i + 2))
+				}
+				i += 2
+			}
+		case "lowerbound", "upperbound":
+			info.Bound = fields[i]
+		case "pv":
+			info.PV = fields[i+1:]
+			i = len(fields)
+		case "string":
+			i = len(fields) // The rest of the line is free text
+		}
+	}
+	return info, true
+}
+
+// Quit asks the engine to exit, killing it if it doesn't in time
+func (c *Client) Quit() error {
+	c.send("quit")
+	c.stdin.Close()
+	go func() {
+		for range c.lines {
+			// Drain the output so the reader isn't left blocked
+		}
+	}()
+
+	exited := make(chan error, 1)
+	go func() { exited <- c.cmd.Wait() }()
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(quitTimeout):
+		c.kill()
+		return <-exited
+	}
+}
+
+// kill ends the engine process at once
+func (c *Client) kill() {
+	if c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+}
+
+// send writes a command to the engine
+func (c *Client) send(format string, args ...any) error {
+	_, err := fmt.Fprintf(c.stdin, format+"\n", args...)
+	return err
+}
+
+// readUntil reads output until a line starting with the word prefix, passing
+// the lines before it to each. A timeout of 0 waits for ever
+func (c *Client) readUntil(prefix string, timeout time.Duration, each func(line string)) (string, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		select {
+		case line, ok := <-c.lines:
+			if !ok {
+				return "", errEngineExited
+			}
+			if line == prefix || strings.HasPrefix(line, prefix+" ") {
+				return line, nil
+			}
+			if each != nil {
+				each(line)
+			}
+		case <-expired:
+			return "", fmt.Errorf("%w: no %s within %v", ErrTimeout, prefix, timeout)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package uci
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+// standInVar names the environment variable that makes the test binary run
+// as a stand-in engine rather than the tests, saying how it should behave
+const standInVar = "CHESS_STAND_IN_ENGINE"
+
+func TestMain(m *testing.M) {
+	if behaviour := os.Getenv(standInVar); behaviour != "" {
+		standInEngine(behaviour)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// standInEngine answers UCI commands on stdin with the first legal move of
+// each position. "late" holds its first answer back until told to stop
+func standInEngine(behaviour string) {
+	g := game.NewGame()
+	holding := false
+	answer := func() {
+		move := g.GenerateAllLegalMoves()[0]
+		fmt.Printf("info depth 1 score cp 10 pv %s\n", MoveString(move))
+		fmt.Printf("bestmove %s\n", MoveString(move))
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "uci":
+			fmt.Println("id name Stand In")
+			fmt.Println("id author The Tests")
+			fmt.Println("option name Skill Level type spin default 20 min 0 max 20")
+			fmt.Println("option name Clear Hash type button")
+			fmt.Println("uciok")
+		case "isready":
+			fmt.Println("readyok")
+		case "position":
+			g = game.NewGame()
+			if i := slices.Index(fields, "moves"); i >= 0 {
+				for _, text := range fields[i+1:] {
+					move, _ := g.ParseMove(text)
+					g.MakeMove(move)
+				}
+			}
+		case "go":
+			if behaviour == "late" {
+				holding, behaviour = true, ""
+				continue
+			}
+			answer()
+		case "stop":
+			if holding {
+				holding = false
+				answer()
+			}
+		case "quit":
+			return
+		}
+	}
+}
+
+// startStandIn runs the test binary as a stand-in engine
+func startStandIn(t *testing.T, behaviour string) *Client {
+	t.Helper()
+	t.Setenv(standInVar, behaviour)
+	c, err := StartEngine(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Quit() })
+	return c
+}
+
+func TestParseInfo(t *testing.T) {
+	tests := []struct {
+		line string
+		ok   bool
+		want Info
+	}{
+		{"bestmove e2e4", false, Info{}},
+		{"info depth 12 seldepth 18 score cp 35 nodes 123456 nps 987654 time 125 pv e2e4 e7e5 g1f3", true,
+			Info{Depth: 12, SelDepth: 18, Score: 35, Nodes: 123456, NPS: 987654, Time: 125 * time.Millisecond,
+				PV: []string{"e2e4", "e7e5", "g1f3"}}},
+		{"info depth 9 score mate -3 pv f7f6", true, Info{Depth: 9, Mate: -3, PV: []string{"f7f6"}}},
+		{"info depth 5 score cp 120 lowerbound nodes 4000", true, Info{Depth: 5, Score: 120, Bound: "lowerbound", Nodes: 4000}},
+		{"info depth 5 score cp -40 upperbound", true, Info{Depth: 5, Score: -40, Bound: "upperbound"}},
+		// Everything after pv is the line, even words that name other fields
+		{"info depth 3 pv e2e4 nodes", true, Info{Depth: 3, PV: []string{"e2e4", "nodes"}}},
+		{"info depth 3 pv", true, Info{Depth: 3, PV: []string{}}},
+		{"info string depth 40 is a long way off", true, Info{}},
+		{"info depth 4 string time 10", true, Info{Depth: 4}},
+		{"info currmove e2e4 currmovenumber 1 hashfull 20 depth", true, Info{}},
+	}
+	for _, test := range tests {
+		got, ok := ParseInfo(test.line)
+		if ok != test.ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseInfo(%q) = %+v, %v, want %+v, %v", test.line, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestParseOptionDeclaration(t *testing.T) {
+	tests := []struct {
+		declaration, name, kind string
+	}{
+		{"name Hash type spin default 16 min 1 max 33554432", "Hash", "spin"},
+		{"name Skill Level type spin default 20 min 0 max 20", "Skill Level", "spin"},
+		{"name Clear Hash type button", "Clear Hash", "button"},
+		{"name UCI_Variant type combo default chess var chess var giveaway", "UCI_Variant", "combo"},
+		{"type check name Use NNUE default true", "Use NNUE", "check"},
+		{"name SyzygyPath type string default <empty>", "SyzygyPath", "string"},
+		{"type spin default 1", "", "spin"},
+	}
+	for _, test := range tests {
+		name, kind := parseOptionDeclaration(strings.Fields(test.declaration))
+		if name != test.name || kind != test.kind {
+			t.Errorf("%q: got %q of type %q, want %q of type %q", test.declaration, name, kind, test.name, test.kind)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	c := startStandIn(t, "normal")
+	if c.Name != "Stand In" || c.Author != "The Tests" {
+		t.Errorf("engine %q by %q", c.Name, c.Author)
+	}
+	if c.Options["Skill Level"] != "spin" || c.Options["Clear Hash"] != "button" {
+		t.Errorf("options %v", c.Options)
+	}
+	if err := c.SetOption("Skill Level", "5"); err != nil {
+		t.Error(err)
+	}
+	if err := c.SetOption("Clear Hash", ""); err != nil {
+		t.Error(err)
+	}
+	if err := c.SetOption("Contempt", "10"); err == nil {
+		t.Error("set an option the engine doesn't have")
+	}
+
+	if err := c.NewGame(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Position("", []string{"e2e4"}); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := c.Go(Limits{Depth: 1}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := game.NewGame()
+	g.MakeMove(mustParse(t, g, "e2e4"))
+	if !g.MakeMove(mustParse(t, g, answer.Move)) {
+		t.Errorf("answer %s isn't legal after e2e4", answer.Move)
+	}
+	if answer.Info.Depth != 1 || answer.Info.Score != 10 || len(answer.Info.PV) != 1 {
+		t.Errorf("last info %+v", answer.Info)
+	}
+
+	if err := c.Quit(); err != nil {
+		t.Errorf("quit: %v", err)
+	}
+}
+
+// TestClientLateAnswer checks that after an answer doesn't come in time,
+// stop and isready leave the engine in step, so the late answer isn't taken
+// for the next one
+func TestClientLateAnswer(t *testing.T) {
+	c := startStandIn(t, "late")
+	if err := c.Position("", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Go(Limits{MoveTime: 10 * time.Millisecond}, 100*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("got error %v, want a timeout", err)
+	}
+	if err := c.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.IsReady(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next answer is black's, not the white move held back
+	if err := c.Position("", []string{"e2e4"}); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := c.Go(Limits{Depth: 1}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := game.NewGame()
+	g.MakeMove(mustParse(t, g, "e2e4"))
+	if _, ok := g.ParseMove(answer.Move); !ok {
+		t.Errorf("answer %s isn't a move for black", answer.Move)
+	}
+}
+
+// mustParse parses a move in coordinate notation
+func mustParse(t *testing.T, g *game.GameState, text string) board.Move {
+	t.Helper()
+	move, ok := g.ParseMove(text)
+	if !ok {
+		t.Fatalf("can't parse %s", text)
+	}
+	return move
+}
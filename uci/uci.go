@@ -0,0 +1,364 @@
+// Package uci runs the engine under the Universal Chess Interface protocol,
+// and drives external engines over it.
+package uci
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"chess-engine/board"
+	"chess-engine/book"
+	"chess-engine/eval"
+	"chess-engine/game"
+	"chess-engine/search"
+	"chess-engine/tablebase"
+)
+
+// uciState is what a UCI session remembers between commands
+type uciState struct {
+	game     *game.GameState
+	engine   *search.Engine
+	ownBook  bool
+	bookFile string
+	out      io.Writer
+}
+
+// Run speaks the UCI protocol until the GUI sends quit or input ends.
+// The scanner is shared with the REPL so switching modes doesn't lose input.
+// answerUCI is set when the "uci" command has already been read and needs a reply
+func Run(scanner *bufio.Scanner, out io.Writer, answerUCI bool) {
+	uci := &uciState{
+		game:   game.NewGame(),
+		engine: search.NewEngine(),
+		out:    out,
+	}
+	uci.engine.Observer = uci
+
+	if answerUCI {
+		uci.identify()
+	}
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "uci":
+			uci.identify()
+		case "isready":
+			fmt.Fprintln(out, "readyok")
+		case "setoption":
+			uci.setOption(fields[1:])
+		case "ucinewgame":
+			uci.game = game.NewGame()
+		case "position":
+			uci.position(fields[1:])
+		case "go":
+			uci.goSearch(fields[1:])
+		case "quit":
+			return
+		}
+	}
+}
+
+// identify sends the engine's name and options
+func (uci *uciState) identify() {
+	fmt.Fprintln(uci.out, "id name Chess Engine v1.0")
+	fmt.Fprintln(uci.out, "id author John2Wragg")
+	fmt.Fprintln(uci.out, "option name OwnBook type check default false")
+	fmt.Fprintln(uci.out, "option name BookFile type string default <empty>")
+	fmt.Fprintf(uci.out, "option name BookDepth type spin default %d min 0 max 100\n", uci.engine.BookDepth)
+	fmt.Fprintln(uci.out, "option name SyzygyPath type string default <empty>")
+	fmt.Fprintf(uci.out, "option name SyzygyProbeDepth type spin default %d min 1 max 100\n", uci.engine.SyzygyProbeDepth)
+	fmt.Fprintln(uci.out, "option name EvalParams type string default <empty>")
+	fmt.Fprintln(uci.out, "option name ReloadEvalParams type button")
+	fmt.Fprintln(uci.out, "option name EvalFile type string default <empty>")
+	fmt.Fprintln(uci.out, "option name Use NNUE type check default false")
+	fmt.Fprintln(uci.out, "uciok")
+}
+
+// setOption handles "setoption name <id> [value <x>]"
+func (uci *uciState) setOption(args []string) {
+	name, value := parseUCIOption(args)
+
+	switch strings.ToLower(name) {
+	case "ownbook":
+		uci.ownBook = strings.EqualFold(value, "true")
+	case "bookfile":
+		if value == "<empty>" {
+			value = ""
+		}
+		uci.bookFile = value
+	case "bookdepth":
+		if depth, err := strconv.Atoi(value); err == nil && depth >= 0 {
+			uci.engine.BookDepth = depth
+		}
+		return
+	case "syzygypath":
+		uci.setSyzygyPath(value)
+		return
+	case "syzygyprobedepth":
+		if depth, err := strconv.Atoi(value); err == nil && depth >= 1 {
+			uci.engine.SyzygyProbeDepth = depth
+		}
+		return
+	case "evalparams":
+		if value == "<empty>" {
+			value = ""
+		}
+		uci.useEvalParams(value)
+		return
+	case "reloadevalparams":
+		uci.useEvalParams(eval.EvalParamsFile)
+		return
+	case "evalfile":
+		uci.loadNetwork(value)
+		return
+	case "use nnue":
+		eval.UseNNUE = strings.EqualFold(value, "true")
+		if eval.UseNNUE && eval.CurrentNetwork == nil {
+			fmt.Fprintln(uci.out, "info string no network loaded, set EvalFile first")
+		}
+		return
+	default:
+		fmt.Fprintf(uci.out, "info string unknown option %s\n", name)
+		return
+	}
+
+	uci.engine.Book = nil
+	if uci.ownBook && uci.bookFile != "" {
+		book, err := book.OpenPolyglotBook(uci.bookFile)
+		if err != nil {
+			fmt.Fprintf(uci.out, "info string could not load book: %v\n", err)
+			return
+		}
+		uci.engine.Book = book
+	}
+}
+
+// setSyzygyPath loads the tablebases, or turns them off for an empty path
+func (uci *uciState) setSyzygyPath(path string) {
+	if uci.engine.Tablebases != nil {
+		uci.engine.Tablebases.Close()
+		uci.engine.Tablebases = nil
+	}
+	if path == "" || path == "<empty>" {
+		return
+	}
+
+	tb, err := tablebase.OpenSyzygy(path)
+	if err != nil {
+		fmt.Fprintf(uci.out, "info string could not load tablebases: %v\n", err)
+		return
+	}
+	uci.engine.Tablebases = tb
+	fmt.Fprintf(uci.out, "info string found tablebases up to %d pieces\n", tb.MaxPieces)
+}
+
+// useEvalParams loads evaluation parameters, keeping the current ones if the file is bad
+func (uci *uciState) useEvalParams(path string) {
+	if err := eval.UseEvalParams(path); err != nil {
+		fmt.Fprintf(uci.out, "info string could not load evaluation parameters: %v\n", err)
+		return
+	}
+	if path != "" {
+		fmt.Fprintf(uci.out, "info string loaded evaluation parameters from %s\n", path)
+	}
+}
+
+// loadNetwork reads the NNUE network, or drops it for an empty path
+func (uci *uciState) loadNetwork(path string) {
+	if path == "" || path == "<empty>" {
+		eval.CurrentNetwork = nil
+		return
+	}
+	net, err := eval.LoadNetwork(path)
+	if err != nil {
+		fmt.Fprintf(uci.out, "info string could not load network: %v\n", err)
+		return
+	}
+	eval.CurrentNetwork = net
+	fmt.Fprintf(uci.out, "info string loaded network %s (%d hidden)\n", path, net.Hidden)
+}
+
+// parseUCIOption splits setoption arguments into name and value, both of which may contain spaces
+func parseUCIOption(args []string) (string, string) {
+	var name, value []string
+	target := &name
+	for _, arg := range args {
+		switch arg {
+		case "name":
+			target = &name
+		case "value":
+			target = &value
+		default:
+			*target = append(*target, arg)
+		}
+	}
+	return strings.Join(name, " "), strings.Join(value, " ")
+}
+
+// position handles "position startpos|fen <fen> [moves <m1> <m2> ...]"
+func (uci *uciState) position(args []string) {
+	if len(args) == 0 {
+		return
+	}
+
+	movesAt := len(args)
+	for i, arg := range args {
+		if arg == "moves" {
+			movesAt = i
+			break
+		}
+	}
+
+	var g *game.GameState
+	switch args[0] {
+	case "startpos":
+		g = game.NewGame()
+	case "fen":
+		var err error
+		g, err = game.NewGameFromFEN(strings.Join(args[1:movesAt], " "))
+		if err != nil {
+			fmt.Fprintf(uci.out, "info string %v\n", err)
+			return
+		}
+	default:
+		return
+	}
+
+	if movesAt < len(args) {
+		for _, text := range args[movesAt+1:] {
+			move, ok := g.ParseMove(text)
+			if !ok || !g.MakeMove(move) {
+				fmt.Fprintf(uci.out, "info string illegal move %s\n", text)
+				return
+			}
+		}
+	}
+	uci.game = g
+}
+
+// goSearch handles "go" with depth, movetime or clock parameters and replies with bestmove
+func (uci *uciState) goSearch(args []string) {
+	engine := uci.engine
+	depth, timeLimit := engine.MaxDepth, engine.TimeLimit
+	defer func() { engine.MaxDepth, engine.TimeLimit = depth, timeLimit }()
+
+	var remaining [2]time.Duration
+	var increment [2]time.Duration
+	movesToGo := 0
+	haveClock := false
+
+	for i := 0; i < len(args); i++ {
+		value := 0
+		if i+1 < len(args) {
+			value, _ = strconv.Atoi(args[i+1])
+		}
+		switch args[i] {
+		case "depth":
+			if value > 0 {
+				engine.MaxDepth = value
+			}
+			i++
+		case "movetime":
+			engine.TimeLimit = time.Duration(value) * time.Millisecond
+			i++
+		case "wtime":
+			remaining[board.White] = time.Duration(value) * time.Millisecond
+			haveClock = true
+			i++
+		case "btime":
+			remaining[board.Black] = time.Duration(value) * time.Millisecond
+			haveClock = true
+			i++
+		case "winc":
+			increment[board.White] = time.Duration(value) * time.Millisecond
+			i++
+		case "binc":
+			increment[board.Black] = time.Duration(value) * time.Millisecond
+			i++
+		case "movestogo":
+			movesToGo = value
+			i++
+		}
+	}
+
+	if haveClock {
+		color := uci.game.CurrentPlayer
+		engine.TimeLimit = engine.AllocateTime(remaining[color], increment[color], movesToGo)
+	}
+
+	result := engine.SearchBestMoveOrdered(uci.game)
+	if result.BestMove.PieceType == board.Empty {
+		fmt.Fprintln(uci.out, "bestmove 0000")
+		return
+	}
+	if result.FromTablebase {
+		fmt.Fprintf(uci.out, "info depth 0 score cp %d tbhits 1 pv %s\n",
+			RelativeScore(result.Score, uci.game.CurrentPlayer), MoveString(result.BestMove))
+	}
+	fmt.Fprintf(uci.out, "bestmove %s\n", MoveString(result.BestMove))
+}
+
+// Observe sends search events to the GUI as info lines, with scores from the
+// side to move's point of view
+func (uci *uciState) Observe(event search.Event) {
+	color := uci.game.CurrentPlayer
+	switch ev := event.(type) {
+	case search.CurrentMove:
+		// Only worth showing once a search has run for a while
+		if ev.Elapsed >= time.Second {
+			fmt.Fprintf(uci.out, "info currmove %s currmovenumber %d\n", MoveString(ev.Move), ev.Number)
+		}
+	case search.NewBestMove:
+		fmt.Fprintf(uci.out, "info depth %d score cp %d nodes %d time %d pv %s\n",
+			ev.Depth, RelativeScore(ev.Score, color), ev.Nodes, ev.Elapsed.Milliseconds(), pvString(ev.PV))
+	case search.Progress:
+		fmt.Fprintf(uci.out, "info nodes %d nps %d time %d\n", ev.Nodes, ev.NPS, ev.Elapsed.Milliseconds())
+	case search.TimeLimitReached:
+		fmt.Fprintln(uci.out, "info string time limit reached")
+	case search.IterationComplete:
+		fmt.Fprintf(uci.out, "info depth %d score cp %d nodes %d nps %d time %d pv %s\n",
+			ev.Depth, RelativeScore(ev.Score, color), ev.Nodes, ev.NPS, ev.Elapsed.Milliseconds(), pvString(ev.PV))
+	}
+}
+
+// pvString formats a principal variation for an info line
+func pvString(pv []board.Move) string {
+	moves := make([]string, len(pv))
+	for i, move := range pv {
+		moves[i] = MoveString(move)
+	}
+	return strings.Join(moves, " ")
+}
+
+// RelativeScore converts a white-positive score into one from the side to move's point of view
+func RelativeScore(score, color int) int {
+	if color == board.Black {
+		return -score
+	}
+	return score
+}
+
+// MoveString formats a move in long algebraic notation, e.g. e7e8q
+func MoveString(move board.Move) string {
+	s := move.String()
+	switch move.PromotionPiece {
+	case board.Queen:
+		s += "q"
+	case board.Rook:
+		s += "r"
+	case board.Bishop:
+		s += "b"
+	case board.Knight:
+		s += "n"
+	}
+	return s
+}
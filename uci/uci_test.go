@@ -0,0 +1,60 @@
+package uci
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestOnlyProtocolOutput checks that a session writes nothing but UCI replies,
+// to the GUI or to the process's own stdout, which is the same pipe in practice
+func TestOnlyProtocolOutput(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	var out strings.Builder
+	input := "uci\nisready\nucinewgame\nposition startpos moves e2e4 e7e5\ngo depth 2\n" +
+		"position fen 8/8/8/8/8/4k3/8/4K2R w K - 0 1\ngo movetime 200\nquit\n"
+	Run(bufio.NewScanner(strings.NewReader(input)), &out, false)
+
+	writer.Close()
+	os.Stdout = stdout
+	stray, _ := io.ReadAll(reader)
+	if len(stray) > 0 {
+		t.Errorf("search wrote to stdout: %q", stray)
+	}
+
+	replies := map[string]bool{"id": true, "option": true, "uciok": true, "readyok": true, "info": true, "bestmove": true}
+	bestMoves := 0
+	var pv []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !replies[fields[0]] {
+			t.Errorf("not a UCI reply: %q", line)
+			continue
+		}
+		switch fields[0] {
+		case "info":
+			if i := slices.Index(fields, "pv"); i >= 0 {
+				pv = fields[i+1:]
+			}
+		case "bestmove":
+			bestMoves++
+			// The search to depth 2 reports its whole line last
+			if bestMoves == 1 && (len(pv) != 2 || pv[0] != fields[1]) {
+				t.Errorf("%s after pv %v, want a two move line starting with it", line, pv)
+			}
+		}
+	}
+	if bestMoves != 2 {
+		t.Errorf("got %d bestmove replies, want 2", bestMoves)
+	}
+}
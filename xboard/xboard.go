@@ -0,0 +1,422 @@
+// Package xboard runs the engine under the XBoard (CECP) protocol.
+package xboard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chess-engine/board"
+	"chess-engine/game"
+	"chess-engine/search"
+	"chess-engine/uci"
+)
+
+// noSide is the engine side in force mode, when it only records moves
+const noSide = -1
+
+// xboardState is what an XBoard (CECP) session remembers between commands
+type xboardState struct {
+	game       *game.GameState
+	engine     *search.Engine
+	engineSide int  // Color the engine plays, or noSide
+	post       bool // Send thinking output
+	analyzing  bool // Analyze mode: search every position but never move
+	out        io.Writer
+
+	// The analysis runs on a goroutine of its own, searching a copy of the
+	// game, so commands are still read while it thinks
+	stop   chan struct{}   // Closed to stop the running analysis, nil when none is running
+	done   chan struct{}   // Closed when the analysis has ended
+	status *analysisStatus // The running analysis, for "."
+
+	// Time control from level, st and time/otim
+	movesPerSession int
+	increment       time.Duration
+	moveTime        time.Duration // Fixed time per move set by st, 0 when using the clock
+	remaining       time.Duration // Engine's clock as last reported by time
+}
+
+// xboardIgnored are commands that need no reply or action
+var xboardIgnored = map[string]bool{
+	"accepted": true, "rejected": true, "random": true, "hard": true, "easy": true,
+	"computer": true, "name": true, "rating": true, "ics": true, "otim": true,
+	"?": true, "draw": true, "hint": true, "bk": true,
+}
+
+// xboardPassive are commands that leave the position and the mode alone, so a
+// running analysis goes on through them. "?" is ignored: analysis has no move
+// to make, and in play the engine only reads it once it has moved
+var xboardPassive = map[string]bool{
+	"ping": true, ".": true, "post": true, "nopost": true, "level": true, "st": true, "sd": true, "time": true,
+}
+
+// analysisDepth bounds analysis, which otherwise goes on until stopped
+const analysisDepth = 64
+
+// analysisTime is the time limit of each analysis search, long enough that
+// only exit or a new position ends it
+const analysisTime = 24 * time.Hour
+
+// Run speaks the XBoard protocol until quit or the input ends. The
+// scanner is shared with the REPL, which has already read the "xboard" command
+func Run(scanner *bufio.Scanner, out io.Writer) {
+	out = &lockedWriter{w: out} // The analysis goroutine writes too
+	xb := &xboardState{
+		game:       game.NewGame(),
+		engine:     search.NewEngine(),
+		engineSide: board.Black,
+		out:        out,
+	}
+	xb.engine.Observer = xb
+	defer xb.stopAnalysis()
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		command, args := fields[0], fields[1:]
+
+		// Any command that may change the position stops the analysis, which
+		// starts again on the new position afterwards
+		if !xboardPassive[command] && !xboardIgnored[command] {
+			xb.stopAnalysis()
+		}
+
+		switch command {
+		case "xboard":
+		case "protover":
+			fmt.Fprintln(out, `feature myname="Chess Engine v1.0" ping=1 setboard=1 playother=1 usermove=1 time=1`+
+				` draw=0 sigint=0 sigterm=0 reuse=1 analyze=1 colors=0 done=1`)
+		case "ping":
+			fmt.Fprintf(out, "pong %s\n", strings.Join(args, " "))
+		case "new":
+			xb.game = game.NewGame()
+			xb.engineSide = board.Black
+			xb.moveTime = 0
+			xb.engine.MaxDepth = search.NewEngine().MaxDepth
+		case "setboard":
+			xb.setBoard(strings.Join(args, " "))
+		case "usermove":
+			if len(args) > 0 {
+				xb.userMove(args[0])
+			}
+		case "go":
+			xb.engineSide = xb.game.CurrentPlayer
+			xb.think()
+		case "playother":
+			xb.engineSide = 1 - xb.game.CurrentPlayer
+		case "force", "result":
+			xb.engineSide = noSide
+		case "level":
+			xb.level(args)
+		case "st":
+			if seconds, err := strconv.ParseFloat(firstArg(args), 64); err == nil && seconds > 0 {
+				xb.moveTime = time.Duration(seconds * float64(time.Second))
+			}
+		case "sd":
+			if depth, err := strconv.Atoi(firstArg(args)); err == nil && depth > 0 {
+				xb.engine.MaxDepth = depth
+			}
+		case "time":
+			if centis, err := strconv.Atoi(firstArg(args)); err == nil {
+				xb.remaining = time.Duration(centis) * 10 * time.Millisecond
+			}
+		case "undo":
+			xb.undo(1)
+		case "remove":
+			xb.undo(2)
+		case "post":
+			xb.post = true
+		case "nopost":
+			xb.post = false
+		case "analyze":
+			xb.analyzing = true
+			xb.engineSide = noSide
+		case "exit":
+			xb.analyzing = false
+		case ".":
+			xb.reportStatus()
+		case "quit":
+			return
+		default:
+			// Old interfaces send moves without usermove
+			if _, ok := xb.game.ParseMove(command); ok && len(command) >= 4 {
+				xb.userMove(command)
+			} else if !xboardIgnored[command] {
+				fmt.Fprintf(out, "Error (unknown command): %s\n", command)
+			}
+		}
+		xb.startAnalysis()
+	}
+}
+
+// lockedWriter writes whole lines from several goroutines without mixing them
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Write writes p while holding the lock
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+// firstArg returns the first argument, or an empty string if there isn't one
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+// setBoard handles "setboard <fen>"
+func (xb *xboardState) setBoard(fen string) {
+	game, err := game.NewGameFromFEN(fen)
+	if err != nil {
+		fmt.Fprintf(xb.out, "tellusererror Illegal position: %v\n", err)
+		return
+	}
+	xb.game = game
+}
+
+// userMove plays the opponent's move, then replies if it is the engine's turn
+func (xb *xboardState) userMove(text string) {
+	move, ok := xb.game.ParseMove(text)
+	if !ok || !xb.game.MakeMove(move) {
+		fmt.Fprintf(xb.out, "Illegal move: %s\n", text)
+		return
+	}
+	if xb.reportResult() {
+		return
+	}
+	if xb.analyzing {
+		return
+	}
+	if xb.engineSide == xb.game.CurrentPlayer {
+		xb.think()
+	}
+}
+
+// undo takes back half-moves for undo and remove
+func (xb *xboardState) undo(plies int) {
+	for i := 0; i < plies; i++ {
+		if _, ok := xb.game.UndoMove(); !ok {
+			break
+		}
+	}
+}
+
+// level handles "level <moves per session> <base> <increment>", the base
+// being minutes or minutes:seconds
+func (xb *xboardState) level(args []string) {
+	if len(args) < 3 {
+		return
+	}
+	moves, err := strconv.Atoi(args[0])
+	if err != nil {
+		return
+	}
+	base := 0.0
+	minutes, seconds, hasSeconds := strings.Cut(args[1], ":")
+	if m, err := strconv.ParseFloat(minutes, 64); err == nil {
+		base = m * 60
+	}
+	if s, err := strconv.ParseFloat(seconds, 64); hasSeconds && err == nil {
+		base += s
+	}
+	increment, _ := strconv.ParseFloat(args[2], 64)
+
+	xb.movesPerSession = moves
+	xb.remaining = time.Duration(base * float64(time.Second))
+	xb.increment = time.Duration(increment * float64(time.Second))
+	xb.moveTime = 0
+}
+
+// search runs the engine on the current position with the time control in force
+func (xb *xboardState) search() search.SearchResult {
+	engine := xb.engine
+	timeLimit := engine.TimeLimit
+	defer func() { engine.TimeLimit = timeLimit }()
+
+	switch {
+	case xb.moveTime > 0:
+		engine.TimeLimit = xb.moveTime
+	case xb.remaining > 0:
+		movesToGo := 0
+		if xb.movesPerSession > 0 {
+			movesToGo = xb.movesPerSession - (xb.game.FullMoveNumber-1)%xb.movesPerSession
+		}
+		engine.TimeLimit = engine.AllocateTime(xb.remaining, xb.increment, movesToGo)
+	}
+
+	return engine.SearchBestMoveOrdered(xb.game)
+}
+
+// Observe sends thinking output when a search iteration completes and post is on
+func (xb *xboardState) Observe(event search.Event) {
+	if ev, ok := event.(search.IterationComplete); ok && xb.post {
+		sendThinking(xb.out, xb.game, ev, ev.Elapsed)
+	}
+}
+
+// sendThinking writes a finished iteration as ply score time nodes pv, with
+// the score for the side to move, time in centiseconds and the pv in SAN
+func sendThinking(out io.Writer, g *game.GameState, ev search.IterationComplete, elapsed time.Duration) {
+	line := make([]string, len(ev.PV))
+	after := g.DetachedCopy()
+	for i, move := range ev.PV {
+		line[i] = after.MoveToSAN(move)
+		after.MakeMove(move)
+	}
+	fmt.Fprintf(out, "%d %d %d %d %s\n", ev.Depth, uci.RelativeScore(ev.Score, g.CurrentPlayer),
+		elapsed.Milliseconds()/10, ev.Nodes, strings.Join(line, " "))
+}
+
+// think searches and plays the engine's move
+func (xb *xboardState) think() {
+	if over, _ := xb.game.IsGameOver(); over {
+		xb.reportResult()
+		return
+	}
+
+	result := xb.search()
+	if result.BestMove.PieceType == board.Empty || !xb.game.MakeMove(result.BestMove) {
+		return
+	}
+	fmt.Fprintf(xb.out, "move %s\n", uci.MoveString(result.BestMove))
+	xb.reportResult()
+}
+
+// startAnalysis starts analysing the position in analyze mode, unless an
+// analysis is already running or the game is over
+func (xb *xboardState) startAnalysis() {
+	if !xb.analyzing || xb.stop != nil {
+		return
+	}
+	if over, _ := xb.game.IsGameOver(); over {
+		return
+	}
+	xb.stop, xb.done = make(chan struct{}), make(chan struct{})
+	xb.status = &analysisStatus{out: xb.out, game: xb.game.Copy(), start: time.Now()}
+	go xb.status.analyse(xb.stop, xb.done)
+}
+
+// stopAnalysis stops the running analysis, if any, and waits for it to end
+func (xb *xboardState) stopAnalysis() {
+	if xb.stop == nil {
+		return
+	}
+	close(xb.stop)
+	<-xb.done
+	xb.stop, xb.done = nil, nil
+}
+
+// reportStatus answers "." with where the analysis has got to
+func (xb *xboardState) reportStatus() {
+	if xb.stop != nil {
+		xb.status.report()
+	}
+}
+
+// analysisStatus is one analysis: the search's own engine and game, and the
+// progress "." reports, which the analysis goroutine updates as it searches
+type analysisStatus struct {
+	out   io.Writer
+	game  *game.GameState
+	start time.Time
+
+	mu     sync.Mutex
+	nodes  int // Of the finished iterations
+	depth  int
+	number int // Root moves started at depth
+	total  int
+	move   string // Root move being searched, in SAN
+}
+
+// analyse deepens the search a ply at a time, sending each finished
+// iteration as thinking output, until stopped
+func (a *analysisStatus) analyse(stop, done chan struct{}) {
+	defer close(done)
+	engine := search.NewEngine()
+	engine.Observer = a
+	engine.Stop = stop
+	engine.TimeLimit = analysisTime
+	for depth := 1; depth <= analysisDepth; depth++ {
+		engine.MaxDepth = depth
+		result := engine.SearchBestMoveOrdered(a.game)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		a.mu.Lock()
+		a.nodes += result.NodesVisited
+		a.mu.Unlock()
+	}
+}
+
+// Observe keeps track of the search and sends each finished iteration
+func (a *analysisStatus) Observe(event search.Event) {
+	switch ev := event.(type) {
+	case search.SearchStarted:
+		a.mu.Lock()
+		a.depth, a.number, a.total = ev.Depth, 0, ev.Moves
+		a.mu.Unlock()
+	case search.CurrentMove:
+		san := a.game.MoveToSAN(ev.Move)
+		a.mu.Lock()
+		a.number, a.move = ev.Number, san
+		a.mu.Unlock()
+	case search.IterationComplete:
+		// Nodes and time count from the start of the analysis
+		a.mu.Lock()
+		ev.Nodes += a.nodes
+		a.mu.Unlock()
+		sendThinking(a.out, a.game, ev, time.Since(a.start))
+	}
+}
+
+// report sends the progress as "stat01: time nodes ply mvleft mvtot mvname"
+func (a *analysisStatus) report() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	fmt.Fprintf(a.out, "stat01: %d %d %d %d %d %s\n", time.Since(a.start).Milliseconds()/10, a.nodes,
+		a.depth, a.total-a.number, a.total, a.move)
+}
+
+// reportResult sends the result if the game has ended, and stops the engine playing
+func (xb *xboardState) reportResult() bool {
+	game := xb.game
+	result := ""
+	if over, _ := game.IsGameOver(); over {
+		switch {
+		case game.Board.IsInCheck(game.CurrentPlayer) && game.CurrentPlayer == board.White:
+			result = "0-1 {Black mates}"
+		case game.Board.IsInCheck(game.CurrentPlayer):
+			result = "1-0 {White mates}"
+		case game.HalfMoveClock >= 100:
+			result = "1/2-1/2 {50 move rule}"
+		default:
+			result = "1/2-1/2 {Stalemate}"
+		}
+	} else if !game.Board.HasMatingMaterial(board.White) && !game.Board.HasMatingMaterial(board.Black) {
+		result = "1/2-1/2 {Insufficient material}"
+	}
+
+	if result == "" {
+		return false
+	}
+	if !xb.analyzing {
+		fmt.Fprintln(xb.out, result)
+		xb.engineSide = noSide
+	}
+	return true
+}
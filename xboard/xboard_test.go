@@ -0,0 +1,216 @@
+package xboard
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"chess-engine/board"
+	"chess-engine/game"
+)
+
+// thinkingLine reads a line of thinking output, "ply score time nodes pv"
+func thinkingLine(line string) (depth int, pv []string, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return 0, nil, false
+	}
+	for _, field := range fields[:4] {
+		if _, err := strconv.Atoi(field); err != nil {
+			return 0, nil, false
+		}
+	}
+	depth, _ = strconv.Atoi(fields[0])
+	return depth, fields[4:], true
+}
+
+// checkLine plays a pv in SAN out from g, checking each move is legal
+func checkLine(t *testing.T, g *game.GameState, pv []string) {
+	t.Helper()
+	g = g.Copy()
+	for _, san := range pv {
+		move, err := g.ParseSAN(san)
+		if err != nil || !g.MakeMove(move) {
+			t.Errorf("pv %v: %s isn't legal", pv, san)
+			return
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	input := []string{
+		"xboard", "protover 2", "new", "sd 2", "post",
+		// The engine answers as black, and refuses a move that can't be played
+		"usermove e2e4", "usermove a1a5",
+		// A bad position is refused; from a good one the engine mates at once
+		"setboard not a fen", "setboard 6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", "go",
+		// In force mode moves are only recorded. e2e4 can be played again only if
+		// undo and remove took it back
+		"new", "sd 2", "force", "usermove e2e4", "undo", "usermove e2e4", "usermove e7e5", "remove", "usermove e2e4", "undo",
+		"go",
+		// After a result the engine doesn't answer moves
+		"result 1-0 {White resigns}", "usermove e7e5", "ping 1", "quit",
+	}
+	var out strings.Builder
+	Run(bufio.NewScanner(strings.NewReader(strings.Join(input, "\n"))), &out)
+
+	afterE4 := game.NewGame()
+	afterE4.MakeMove(mustParse(t, afterE4, "e2e4"))
+	replies := []struct {
+		prefix string
+		from   *game.GameState // Position a move is played from, and its thinking searched
+	}{
+		{"feature ", nil},
+		{"move ", afterE4},
+		{"Illegal move: a1a5", nil},
+		{"tellusererror Illegal position", nil},
+		{"move a1a8", nil},
+		{"1-0 {White mates}", nil},
+		{"move ", game.NewGame()},
+		{"pong 1", nil},
+	}
+
+	var depths []int
+	next := 0
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if depth, pv, ok := thinkingLine(line); ok {
+			depths = append(depths, depth)
+			if next < len(replies) && replies[next].from != nil {
+				checkLine(t, replies[next].from, pv)
+				if len(pv) != depth {
+					t.Errorf("%q: a %d move pv at depth %d", line, len(pv), depth)
+				}
+			}
+			continue
+		}
+		if next >= len(replies) || !strings.HasPrefix(line, replies[next].prefix) {
+			t.Fatalf("unexpected reply %q", line)
+		}
+		if strings.HasPrefix(line, "feature ") && !strings.HasSuffix(line, "done=1") {
+			t.Errorf("%q doesn't end the feature list", line)
+		}
+		if from := replies[next].from; from != nil {
+			if _, ok := from.ParseMove(strings.TrimPrefix(line, "move ")); !ok {
+				t.Errorf("%q isn't a legal move", line)
+			}
+		}
+		next++
+	}
+	if next != len(replies) {
+		t.Errorf("got %d of %d replies", next, len(replies))
+	}
+
+	// The engine searches to its depth at once, so each of its three moves
+	// comes after a single line of thinking output
+	if want := []int{2, 2, 2}; !slices.Equal(depths, want) {
+		t.Errorf("thinking output for depths %v, want %v", depths, want)
+	}
+}
+
+// session drives Run over pipes, so a test can wait for replies
+type session struct {
+	t     *testing.T
+	in    *io.PipeWriter
+	lines chan string
+}
+
+func startSession(t *testing.T) *session {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	s := &session{t: t, in: inWriter, lines: make(chan string, 256)}
+	go func() {
+		Run(bufio.NewScanner(inReader), outWriter)
+		outWriter.Close()
+	}()
+	go func() {
+		scanner := bufio.NewScanner(outReader)
+		for scanner.Scan() {
+			s.lines <- scanner.Text()
+		}
+		close(s.lines)
+	}()
+	t.Cleanup(func() { inWriter.Close() })
+	return s
+}
+
+func (s *session) send(command string) {
+	s.t.Helper()
+	if _, err := io.WriteString(s.in, command+"\n"); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// expect reads replies up to one starting with prefix, returning those before it
+func (s *session) expect(prefix string) []string {
+	s.t.Helper()
+	var before []string
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case line, ok := <-s.lines:
+			if !ok {
+				s.t.Fatalf("session ended waiting for %q", prefix)
+			}
+			if strings.HasPrefix(line, prefix) {
+				return before
+			}
+			before = append(before, line)
+		case <-timeout:
+			s.t.Fatalf("no %q after %q", prefix, before)
+		}
+	}
+}
+
+// TestAnalyze checks that analysis runs while commands are still read, and
+// that exit and new positions stop it
+func TestAnalyze(t *testing.T) {
+	s := startSession(t)
+	s.send("xboard")
+	s.send("analyze")
+
+	// Thinking output arrives without any further command, and "." is answered
+	// in the middle of the analysis
+	s.expect("3 ")
+	s.send(".")
+	s.expect("stat01: ")
+
+	// A move restarts the analysis on the new position
+	s.send("usermove e2e4")
+	s.send("ping 1")
+	s.expect("pong 1")
+	afterE4 := game.NewGame()
+	afterE4.MakeMove(mustParse(t, afterE4, "e2e4"))
+	for _, line := range s.expect("2 ") {
+		if _, pv, ok := thinkingLine(line); ok {
+			checkLine(t, afterE4, pv)
+		}
+	}
+
+	// After exit nothing more is sent
+	s.send("exit")
+	s.send("ping 2")
+	for _, line := range s.expect("pong 2") {
+		if _, _, ok := thinkingLine(line); !ok {
+			t.Errorf("unexpected reply %q", line)
+		}
+	}
+	s.send("ping 3")
+	if before := s.expect("pong 3"); len(before) > 0 {
+		t.Errorf("got %q after exit", before)
+	}
+	s.send("quit")
+}
+
+// mustParse parses a move in coordinate notation
+func mustParse(t *testing.T, g *game.GameState, text string) board.Move {
+	t.Helper()
+	move, ok := g.ParseMove(text)
+	if !ok {
+		t.Fatalf("can't parse %s", text)
+	}
+	return move
+}